@@ -0,0 +1,54 @@
+/**
+ * Citation formatter for streamed text
+ *
+ * With web search, Lumo cites its sources with markdown links and reference
+ * markers, e.g. "Rain is expected [1](https://example.com) tomorrow [2]." Voice
+ * assistants read these out literally. This formatter rewrites links to their
+ * label and drops reference markers, leaving "Rain is expected tomorrow."
+ *
+ * A citation can be split across chunks, so a trailing "[..." that may still
+ * become one is held back until it's complete (or clearly isn't one).
+ */
+
+/** [[1]](url) and [1](url): links whose label is just a reference number */
+const REFERENCE_LINK = /\s*\[\[\^?\d+\]\]\([^)\s]*\)|\s*\[\^?\d+\]\([^)\s]*\)/g;
+/** [label](url) */
+const MARKDOWN_LINK = /\[([^\]\n]+)\]\([^)\s]*\)/g;
+/** [1], [1, 2], [1-3], [^1] and 【1†source】 */
+const REFERENCE_MARKER = /\s*(?:\[\^?\d+(?:\s*[,-]\s*\d+)*\]|【[^】\n]*】)/g;
+
+/** A trailing link or marker that may still be completed by the next chunk */
+const INCOMPLETE = /\s*(?:\[[^\]\n]*(?:\](?:\([^)\s]*)?)?|\[\[[^\]\n]*\](?:\](?:\([^)\s]*)?)?|【[^】\n]*)$/;
+
+/** Longest text held back waiting for a citation to complete */
+const MAX_PENDING = 300;
+
+export function formatCitations(text: string): string {
+  return text
+    .replace(REFERENCE_LINK, '')
+    .replace(MARKDOWN_LINK, '$1')
+    .replace(REFERENCE_MARKER, '');
+}
+
+export class CitationFormatter {
+  private pending = '';
+
+  /** Add a chunk. Returns the formatted text that is safe to emit, which may be empty. */
+  push(chunk: string): string {
+    const text = this.pending + chunk;
+    const match = INCOMPLETE.exec(text);
+    if (!match || text.length - match.index > MAX_PENDING) {
+      this.pending = '';
+      return formatCitations(text);
+    }
+    this.pending = text.slice(match.index);
+    return formatCitations(text.slice(0, match.index));
+  }
+
+  /** Emit whatever is left at end of stream. */
+  flush(): string {
+    const rest = this.pending;
+    this.pending = '';
+    return formatCitations(rest);
+  }
+}
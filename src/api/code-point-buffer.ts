@@ -0,0 +1,41 @@
+/**
+ * Code point buffer for streamed text
+ *
+ * Lumo can split an emoji (a UTF-16 surrogate pair) across two chunks. Emitting
+ * the halves separately produces deltas like "\ud83c" + "\udf89", which strict
+ * JSON clients (e.g. Home Assistant) fail to serialize. This buffer holds back
+ * a trailing high surrogate until its low surrogate arrives, so every emitted
+ * string contains whole code points only.
+ *
+ * Incomplete UTF-8 sequences never reach this point: LumoClient decodes the
+ * response body with a streaming TextDecoder, which already buffers them.
+ */
+
+import { REPLACEMENT_CHARACTER, isHighSurrogate, toWellFormed } from './well-formed.js';
+
+export class CodePointBuffer {
+  private pending = '';
+
+  /**
+   * Add a chunk. Returns the text that is safe to emit, which may be empty.
+   * Unpaired surrogates are replaced with U+FFFD.
+   */
+  push(chunk: string): string {
+    const text = this.pending + chunk;
+    this.pending = '';
+
+    let end = text.length;
+    if (end > 0 && isHighSurrogate(text.charCodeAt(end - 1))) {
+      this.pending = text[end - 1];
+      end--;
+    }
+    return toWellFormed(text.slice(0, end));
+  }
+
+  /** Emit whatever is left at end of stream. A dangling high surrogate becomes U+FFFD. */
+  flush(): string {
+    const rest = this.pending;
+    this.pending = '';
+    return rest ? REPLACEMENT_CHARACTER : '';
+  }
+}
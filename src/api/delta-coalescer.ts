@@ -0,0 +1,67 @@
+/**
+ * Delta coalescing for streamed text
+ *
+ * Lumo can stream a token or even a character at a time, and every delta becomes
+ * an SSE event, e.g. a Home Assistant PipelineEvent. This collects deltas and
+ * releases them in larger pieces: right away at the end of a sentence, else at
+ * the last word boundary once the flush interval passed since the last release.
+ * Like CodePointBuffer, half a surrogate pair is never released on its own.
+ */
+
+import { isHighSurrogate } from './well-formed.js';
+
+/** Sentence punctuation followed by whitespace, e.g. "on. " or "done!\n" */
+const SENTENCE_END = /[.!?…]\s+$/;
+
+export class DeltaCoalescer {
+  private pending = '';
+  private lastRelease: number;
+
+  constructor(private readonly intervalMs: number, now = Date.now()) {
+    this.lastRelease = now;
+  }
+
+  /** Whether text is held back */
+  get holding(): boolean {
+    return this.pending.length > 0;
+  }
+
+  /** Add a chunk. Returns the text to emit now, which may be empty. */
+  push(chunk: string, now = Date.now()): string {
+    this.pending += chunk;
+    if (SENTENCE_END.test(this.pending)) {
+      return this.release(this.pending.length, now);
+    }
+    if (now - this.lastRelease < this.intervalMs) return '';
+    return this.release(lastWordBoundary(this.pending), now);
+  }
+
+  /** Release all held text, e.g. when the flush interval passed without new chunks */
+  take(now = Date.now()): string {
+    let end = this.pending.length;
+    if (end > 0 && isHighSurrogate(this.pending.charCodeAt(end - 1))) end--;
+    return this.release(end, now);
+  }
+
+  /** Emit whatever is left at end of stream. */
+  flush(): string {
+    const rest = this.pending;
+    this.pending = '';
+    return rest;
+  }
+
+  private release(end: number, now: number): string {
+    const text = this.pending.slice(0, end);
+    this.pending = this.pending.slice(end);
+    if (text) this.lastRelease = now;
+    return text;
+  }
+}
+
+/** Index right after the last whitespace in text, 0 if there is none */
+function lastWordBoundary(text: string): number {
+  for (let i = text.length; i > 0; i--) {
+    if (/\s/.test(text[i - 1])) return i;
+  }
+  return 0;
+}
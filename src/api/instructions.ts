@@ -4,12 +4,16 @@
  * - Template validation
  * - Replace patterns for cleaning client instructions
  * - Instruction building with tool prefixing
+ * - System prompt override, reloaded when config.yaml changes
  */
 
+import { watchFile } from 'fs';
 import { logger } from '../app/logger.js';
-import { getServerInstructionsConfig, getCustomToolsConfig } from '../app/config.js';
+import { getServerInstructionsConfig, getCustomToolsConfig, reloadServerInstructionsConfig } from '../app/config.js';
+import { getConfigPath } from '../app/config-file.js';
 import { interpolateTemplate } from '../app/template.js';
 import { applyToolPrefix, applyToolNamePrefix } from './tools/prefix.js';
+import { toFunctionDefinitions } from './tools/schema.js';
 import type { OpenAITool } from './types.js';
 
 // ── Template validation ───────────────────────────────────────────────
@@ -106,24 +110,59 @@ function extractToolNames(tools?: OpenAITool[]): string[] {
     .filter((n): n is string => Boolean(n));
 }
 
+/** Request details available to the system prompt. */
+export interface InstructionsContext {
+  /** The request's `user` field */
+  user?: string;
+  /** Language tag to reply in, e.g. "nl" (see resolveLanguage) */
+  language?: string;
+}
+
+/** English name of a language tag, e.g. "Dutch" for "nl", else the tag itself */
+export function languageName(tag: string): string {
+  try {
+    return new Intl.DisplayNames(['en'], { type: 'language' }).of(tag) ?? tag;
+  } catch {
+    return tag;
+  }
+}
+
+/**
+ * Interpolate the configured system prompt with request details and the current time.
+ * Returns undefined if no system prompt is configured.
+ */
+function buildSystemPrompt(systemPrompt: string, context: InstructionsContext, now = new Date()): string | undefined {
+  if (!systemPrompt.trim()) return undefined;
+  const pad = (n: number) => String(n).padStart(2, '0');
+  return interpolateTemplate(systemPrompt, {
+    user: context.user,
+    language: context.language && languageName(context.language),
+    date: `${now.getFullYear()}-${pad(now.getMonth() + 1)}-${pad(now.getDate())}`,
+    time: `${pad(now.getHours())}:${pad(now.getMinutes())}`,
+  });
+}
+
 /**
  * Build instructions using the template system.
  * Uses conditionals in the template to handle all cases:
  * - With/without tools
  * - With/without client instructions (falls back to fallback)
+ * The system prompt, if configured, is prepended to the result, the reply language
+ * hint (forLanguage) appended when context has a language.
  *
  * @param tools - Optional array of OpenAI tool definitions
  * @param clientInstructions - Optional instructions from client (system/developer message)
+ * @param context - Request details for system prompt variables
  * @returns Formatted instruction string
  */
-export function buildInstructions(tools?: OpenAITool[], clientInstructions?: string): string {
+export function buildInstructions(tools?: OpenAITool[], clientInstructions?: string, context: InstructionsContext = {}): string {
   const instructionsConfig = getServerInstructionsConfig();
   const toolsConfig = getCustomToolsConfig();
   const { prefix } = toolsConfig;
   const { replacePatterns } = instructionsConfig;
 
-  // Determine if we should include tools
-  const includeTools = toolsConfig.enabled && tools && tools.length > 0;
+  // Determine if we should include tools (built-in tools like web_search have no function to show)
+  const includeTools = toolsConfig.enabled && tools && toFunctionDefinitions(tools).length > 0;
 
   // Pre-interpolate forTools block (it can use {{prefix}})
   const forTools = interpolateTemplate(instructionsConfig.forTools, { prefix });
@@ -132,7 +171,7 @@ export function buildInstructions(tools?: OpenAITool[], clientInstructions?: str
   let toolsJson: string | undefined;
   if (includeTools) {
     const prefixedTools = applyToolPrefix(tools, prefix);
-    toolsJson = JSON.stringify(prefixedTools, null, 2);
+    toolsJson = JSON.stringify(toFunctionDefinitions(prefixedTools), null, 2);
   }
 
   // Clean and prefix client instructions
@@ -154,5 +193,32 @@ export function buildInstructions(tools?: OpenAITool[], clientInstructions?: str
     fallback: instructionsConfig.fallback,
   });
 
-  return result;
+  const systemPrompt = buildSystemPrompt(instructionsConfig.systemPrompt, context);
+  const instructions = systemPrompt ? `${systemPrompt}\n\n${result}`.trim() : result;
+
+  // Lumo tends to follow the language of the instructions, ask for the reply language explicitly
+  const forLanguage = context.language
+    ? interpolateTemplate(instructionsConfig.forLanguage, { language: languageName(context.language) }).trim()
+    : '';
+  return forLanguage ? `${instructions}\n\n${forLanguage}`.trim() : instructions;
+}
+
+// ── Reloading ────────────────────────────────────────────────────────
+
+/**
+ * Reload server.instructions whenever config.yaml changes.
+ * Polls the file, so it also works for editors replacing it and for bind mounts.
+ * An invalid config is logged and ignored, keeping the current instructions.
+ */
+export function watchInstructionsConfig(intervalMs = 2000): void {
+  const path = getConfigPath();
+  watchFile(path, { interval: intervalMs }, (curr, prev) => {
+    if (curr.mtimeMs === prev.mtimeMs) return;
+    try {
+      reloadServerInstructionsConfig();
+      logger.info('Reloaded server.instructions from config.yaml');
+    } catch (e) {
+      logger.warn({ error: String(e) }, 'Failed to reload config.yaml, keeping current instructions');
+    }
+  }).unref();
 }
@@ -8,6 +8,7 @@
 import type { OpenAIChatMessage, OpenAIResponseMessage, OpenAIToolCall } from './types.js';
 import { Role } from '../lumo-client/index.js';
 import { addToolNameToFunctionOutput } from './tools/call-id.js';
+import { normalizeToolOutput } from './tools/tool-output.js';
 import { type MessageForStore } from 'src/conversations/types.js';
 
 /**
@@ -54,6 +55,7 @@ function extractTextContent(content: unknown): string {
  *
  * Tool-related items are converted to user/assistant roles with JSON content,
  * since Lumo's tool_call/tool_result roles are reserved for SSE tools.
+ * Tool outputs are normalized first (see normalizeToolOutput).
  *
  * @returns Converted message(s), or null if item is not a tool message
  */
@@ -66,7 +68,7 @@ export function convertToolMessage(item: unknown): MessageForStore | MessageForS
     const json = JSON.stringify({
       type: 'function_call_output',
       call_id: obj.tool_call_id,
-      output: obj.content,
+      output: normalizeToolOutput(obj.content),
     });
     return {
       role: Role.User,
@@ -120,7 +122,7 @@ export function convertToolMessage(item: unknown): MessageForStore | MessageForS
     const json = JSON.stringify({
       type: 'function_call_output',
       call_id: obj.call_id,
-      output: obj.output,
+      output: normalizeToolOutput(obj.output),
     });
     return {
       role: Role.User,
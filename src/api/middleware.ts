@@ -5,7 +5,7 @@ import { getMetrics, type MetricsService } from '../app/metrics.js';
 export function setupAuthMiddleware(apiKey: string): RequestHandler {
   return (req, res, next) => {
     // Skip auth for health and metrics endpoints
-    if (req.path === '/health' || req.path === '/metrics') {
+    if (req.path === '/health' || req.path === '/healthz' || req.path === '/metrics') {
       return next();
     }
 
@@ -39,6 +39,10 @@ export function setupMetricsMiddleware(metrics: MetricsService): RequestHandler
   return (req, res, next) => {
     const startTime = process.hrtime.bigint();
 
+    // 'close' also fires for aborted requests, which never 'finish'
+    metrics.activeRequests.inc();
+    res.once('close', () => metrics.activeRequests.dec());
+
     res.on('finish', () => {
       const endpoint = normalizeEndpoint(req.path);
       const duration = Number(process.hrtime.bigint() - startTime) / 1e9;
@@ -8,8 +8,21 @@ export class RequestQueue {
     this.queue = new PQueue({ concurrency });
   }
 
-  async add<T>(fn: () => Promise<T>): Promise<T> {
-    return this.queue.add(fn) as Promise<T>;
+  /**
+   * Run fn once earlier requests are done. When signal aborts, this rejects right
+   * away instead of waiting for its turn, and fn is skipped if it didn't start yet.
+   */
+  async add<T>(fn: () => Promise<T>, signal?: AbortSignal): Promise<T> {
+    const task = this.queue.add(fn, { signal }) as Promise<T>;
+    if (!signal) return task;
+
+    signal.throwIfAborted();
+    task.catch(() => { }); // Rejects again when a skipped task's turn comes
+    return new Promise<T>((resolve, reject) => {
+      const onAbort = () => reject(signal.reason);
+      signal.addEventListener('abort', onAbort, { once: true });
+      task.then(resolve, reject).finally(() => signal.removeEventListener('abort', onAbort));
+    });
   }
 
   getSize(): number {
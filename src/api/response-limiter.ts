@@ -0,0 +1,70 @@
+/**
+ * Response length limiter for streamed text
+ *
+ * Enforces max_tokens on Lumo's output server-side, in case Lumo exceeds it.
+ * Text is emitted a sentence at a time, so the response can be cut off at a
+ * sentence boundary: the first sentence that doesn't fit ends the response,
+ * followed by a note. Tokens are estimated at ~4 characters each.
+ *
+ * Holding back the current sentence delays text a little, so the limiter is
+ * only used when a limit applies.
+ */
+
+import { isHighSurrogate } from './well-formed.js';
+
+const CHARS_PER_TOKEN = 4;
+
+/** End of a sentence: punctuation with optional closing quotes/brackets and whitespace, or a line break */
+const SENTENCE_END = /[.!?…]+["')\]]*\s+|\n+/;
+
+export class ResponseLimiter {
+  private pending = '';
+  private used = 0;
+  private readonly maxChars: number;
+  /** Whether the response was cut off */
+  truncated = false;
+
+  constructor(maxTokens: number, private readonly note: string) {
+    this.maxChars = maxTokens * CHARS_PER_TOKEN;
+  }
+
+  /** Add a chunk. Returns the text that may be emitted, which may be empty. */
+  push(chunk: string): string {
+    if (this.truncated) return '';
+    this.pending += chunk;
+
+    let out = '';
+    let match: RegExpExecArray | null;
+    while ((match = SENTENCE_END.exec(this.pending))) {
+      const end = match.index + match[0].length;
+      if (this.used + end > this.maxChars) return out + this.cutOff();
+      out += this.pending.slice(0, end);
+      this.used += end;
+      this.pending = this.pending.slice(end);
+    }
+    if (this.used + this.pending.length > this.maxChars) return out + this.cutOff();
+    return out;
+  }
+
+  /** Emit whatever is left at end of stream. */
+  flush(): string {
+    const rest = this.pending;
+    this.pending = '';
+    return rest;
+  }
+
+  private cutOff(): string {
+    // Without a complete sentence to show, cut the first one at a word boundary
+    let text = '';
+    if (this.used === 0) {
+      let head = this.pending.slice(0, this.maxChars);
+      // Don't leave half an emoji
+      if (isHighSurrogate(head.charCodeAt(head.length - 1))) head = head.slice(0, -1);
+      const space = head.lastIndexOf(' ');
+      text = space > 0 ? head.slice(0, space) : head;
+    }
+    this.pending = '';
+    this.truncated = true;
+    return text.trimEnd() + this.note;
+  }
+}
@@ -1,5 +1,6 @@
 import { Response } from 'express';
 import { OpenAIStreamChunk, OpenAIToolCall } from '../../types.js';
+import { stringifyWellFormed } from '../../well-formed.js';
 
 export class ChatCompletionEventEmitter {
   private res: Response;
@@ -24,7 +25,19 @@ export class ChatCompletionEventEmitter {
       model: this.model,
       choices: [{ index: 0, delta: { content }, finish_reason: null }],
     };
-    this.res.write(`data: ${JSON.stringify(chunk)}\n\n`);
+    this.res.write(`data: ${stringifyWellFormed(chunk)}\n\n`);
+  }
+
+  emitReasoningDelta(reasoning: string): void {
+    if (!reasoning) return;
+    const chunk: OpenAIStreamChunk = {
+      id: this.id,
+      object: 'chat.completion.chunk',
+      created: this.created,
+      model: this.model,
+      choices: [{ index: 0, delta: { reasoning_content: reasoning }, finish_reason: null }],
+    };
+    this.res.write(`data: ${stringifyWellFormed(chunk)}\n\n`);
   }
 
   emitToolCallDelta(callId: string, name: string, args: Record<string, unknown>): void {
@@ -40,13 +53,13 @@ export class ChatCompletionEventEmitter {
             index: this.toolCallIndex++,
             id: callId,
             type: 'function',
-            function: { name, arguments: JSON.stringify(args) },
+            function: { name, arguments: stringifyWellFormed(args) },
           }],
         },
         finish_reason: null,
       }],
     };
-    this.res.write(`data: ${JSON.stringify(chunk)}\n\n`);
+    this.res.write(`data: ${stringifyWellFormed(chunk)}\n\n`);
   }
 
   emitDone(toolCalls: OpenAIToolCall[] | undefined): void {
@@ -57,14 +70,14 @@ export class ChatCompletionEventEmitter {
       model: this.model,
       choices: [{ index: 0, delta: {}, finish_reason: toolCalls ? 'tool_calls' : 'stop' }],
     };
-    this.res.write(`data: ${JSON.stringify(finalChunk)}\n\n`);
+    this.res.write(`data: ${stringifyWellFormed(finalChunk)}\n\n`);
     this.res.write('data: [DONE]\n\n');
     this.res.end();
   }
 
   emitError(error: Error): void {
     const errorChunk = { error: { message: String(error), type: 'server_error' } };
-    this.res.write(`data: ${JSON.stringify(errorChunk)}\n\n`);
+    this.res.write(`data: ${stringifyWellFormed(errorChunk)}\n\n`);
     this.res.end();
   }
 }
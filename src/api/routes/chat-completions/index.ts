@@ -1,6 +1,6 @@
 import { Router, Request, Response } from 'express';
 import { EndpointDependencies, OpenAIChatRequest, OpenAIChatResponse } from '../../types.js';
-import { getServerConfig, getConversationsConfig, getLogConfig, getServerInstructionsConfig } from '../../../app/config.js';
+import { getConversationsConfig, getLogConfig, getServerConfig, getServerInstructionsConfig } from '../../../app/config.js';
 import { logger } from '../../../app/logger.js';
 import { convertOpenAIChatMessages, extractSystemMessage } from '../../message-converter.js';
 import { buildInstructions } from '../../instructions.js';
@@ -13,14 +13,33 @@ import { createStreamingToolProcessor } from '../../tools/streaming-processor.js
 import {
   buildRequestContext,
   persistTitle,
+  recordUsage,
   persistAssistantTurn,
   generateChatCompletionId,
   mapToolCallsForPersistence,
   tryExecuteCommand,
   setSSEHeaders,
+  startSSEKeepAlive,
+  abortOnClientClose,
+  waitForDrain,
+  getStreamTimeoutOptions,
+  observeLumoRequest,
+  resolveWebSearch,
+  resolveLanguage,
+  resolveMaxTokens,
+  resolveStop,
+  withCitationFormatting,
+  withDeltaCoalescing,
+  withReasoning,
+  withResponseLimit,
+  withStopSequences,
+  resolveModel,
+  resolveSampling,
+  persistAndBuildTurns,
 } from '../shared.js';
 import { sendInvalidRequest, sendServerError } from '../../error-handler.js';
 import { deterministicUUID } from '../../../app/id-generator.js';
+import { sendWellFormedJson } from '../../well-formed.js';
 
 /** Extract tool_call_id from a role: 'tool' message. */
 function extractToolCallId(msg: unknown): string | undefined {
@@ -89,6 +108,10 @@ export function createChatCompletionsRouter(deps: EndpointDependencies): Router
         conversationId = generateConversationIdFromUser(request.user);
       }
       // No else - leave undefined for stateless requests
+      if (!getConversationsConfig().persist) {
+        // Persistence disabled: every request is stateless
+        conversationId = undefined;
+      }
 
       // ===== Track tool completions (all requests) =====
       // Set-based dedup in trackCustomToolCompletion prevents double-counting
@@ -104,20 +127,20 @@ export function createChatCompletionsRouter(deps: EndpointDependencies): Router
 
       // ===== Build instructions (injected in LumoClient, not persisted) =====
       const systemContent = extractSystemMessage(request.messages);
-      const instructions = buildInstructions(request.tools, systemContent);
+      const language = resolveLanguage(request, req.get('accept-language'), conversationId);
+      const instructions = buildInstructions(request.tools, systemContent, { user: request.user, language });
       const { injectInto } = getServerInstructionsConfig();
 
-      // ===== Persist incoming messages (stateful only) =====
-      if (conversationId && deps.conversationStore && turns.length > 0) {
-        deps.conversationStore.appendMessages(conversationId, turns);
-        logger.debug({ conversationId, messageCount: turns.length }, 'Persisted conversation messages');
-      } else if (!conversationId) {
+      // ===== Persist incoming messages and build history =====
+      // Stateful requests re-send stored history if the client only sent new messages
+      const history = persistAndBuildTurns(deps, conversationId, turns);
+      if (!conversationId) {
         // Stateless request - track +1 user message (not deduplicated)
         getMetrics()?.messagesTotal.inc({ role: 'user' });
       }
 
       // Add to queue and process
-      await handleChatRequest(res, deps, request, turns, conversationId, request.stream ?? false, instructions, injectInto);
+      await handleChatRequest(res, deps, request, history, conversationId, request.stream ?? false, instructions, injectInto);
     } catch (error) {
       logger.error('Error processing chat completion:');
       logger.error(error);
@@ -140,57 +163,105 @@ async function handleChatRequest(
 ): Promise<void> {
   const id = generateChatCompletionId();
   const created = Math.floor(Date.now() / 1000);
-  const model = request.model || getServerConfig().apiModelName;
+  const model = resolveModel(request.model);
+  const sampling = resolveSampling(request);
+  const webSearch = resolveWebSearch(request, conversationId);
+  const maxTokens = resolveMaxTokens(request.max_tokens);
+  const stop = resolveStop(request.stop);
   const ctx = buildRequestContext(deps, conversationId, request.tools);
+  const signal = abortOnClientClose(res);
 
   // Streaming setup
-  const emitter = streaming ? new ChatCompletionEventEmitter(res, id, created, model) : null;
+  const emitter = streaming ? new ChatCompletionEventEmitter(res, id, created, model.name) : null;
+  let stopKeepAlive = () => {};
   if (emitter) {
     setSSEHeaders(res);
+    stopKeepAlive = startSSEKeepAlive(res);
   }
 
   let accumulatedText = '';
+  let reasoningText = '';
   let toolCalls: typeof processor.toolCallsEmitted | undefined;
 
+  const coalesced = withDeltaCoalescing(emitter ? getServerConfig().flushIntervalMs : 0, signal, (delta) => {
+    emitter?.emitContentDelta(delta);
+  });
+  const limited = withResponseLimit(maxTokens, (delta) => {
+    stopKeepAlive();
+    accumulatedText += delta;
+    coalesced.emit(delta);
+  });
+  const text = withCitationFormatting(webSearch, limited.emit);
+  const stopped = withStopSequences(stop, text.emit);
+
+  const reasoning = withReasoning((delta) => {
+    stopKeepAlive();
+    coalesced.flush();
+    reasoningText += delta;
+    emitter?.emitReasoningDelta(delta);
+  });
+
   const processor = createStreamingToolProcessor(ctx.hasCustomTools, {
-    emitTextDelta(text) {
-      accumulatedText += text;
-      emitter?.emitContentDelta(text);
-    },
+    emitTextDelta: stopped.emit,
     emitToolCall(callId, tc) {
+      stopKeepAlive();
+      coalesced.flush();
       emitter?.emitToolCallDelta(callId, tc.name, tc.arguments);
     },
-  });
+  }, request.tools);
 
   // Check for command before calling Lumo
   const commandResult = await tryExecuteCommand(turns, ctx.commandContext);
   if (commandResult) {
     accumulatedText = commandResult.response;
+    stopKeepAlive();
     emitter?.emitContentDelta(accumulatedText);
   } else {
     // Normal flow: call Lumo
     try {
       const result = await deps.queue.add(async () =>
-        deps.lumoClient.chatWithHistory(turns, processor.onChunk, {
+        observeLumoRequest(signal, () => deps.lumoClient.chatWithHistory(turns, processor.onChunk, {
           requestTitle: ctx.requestTitle,
           instructions,
           injectInstructionsInto,
-        })
+          model: model.upstream,
+          ...sampling,
+          enableWebSearch: webSearch,
+          maxTokens,
+          stop,
+          signal,
+          ...getStreamTimeoutOptions(),
+          ...(emitter ? { waitForConsumer: waitForDrain(res, signal) } : {}),
+          ...reasoning.options,
+        })),
+        signal
       );
 
       logger.debug('[Server] Stream completed');
       processor.finalize();
+      stopped.flush();
+      text.flush();
+      limited.flush();
+      coalesced.flush();
+      reasoning.flush();
       persistTitle(result, deps, conversationId);
+      recordUsage(result, conversationId);
       toolCalls = processor.toolCallsEmitted.length > 0 ? processor.toolCallsEmitted : undefined;
 
+      // Persist what the client got, after max_tokens and stop cut it short
       persistAssistantTurn(
         deps,
         conversationId,
-        result.message,
+        { ...result.message, content: accumulatedText },
         mapToolCallsForPersistence(processor.toolCallsEmitted)
       );
     } catch (error) {
+      if (signal.aborted) {
+        logger.info({ conversationId }, '[Server] Client disconnected, cancelled Lumo request');
+        return;
+      }
       logger.error({ error: String(error) }, 'Chat completion error');
+      coalesced.flush();
       if (emitter) {
         emitter.emitError(error as Error);
       } else {
@@ -209,18 +280,19 @@ async function handleChatRequest(
         id,
         object: 'chat.completion',
         created,
-        model,
+        model: model.name,
         choices: [{
           index: 0,
           message: {
             role: 'assistant',
             content: accumulatedText,
+            ...(reasoningText ? { reasoning_content: reasoningText } : {}),
             ...(toolCalls ? { tool_calls: toolCalls } : {}),
           },
           finish_reason: toolCalls ? 'tool_calls' : 'stop',
         }],
       };
-      res.json(response);
+      sendWellFormedJson(res, response);
     }
   } catch (error) {
     logger.error({ error: String(error) }, 'Error sending chat completion response');
@@ -1,5 +1,7 @@
 import { Router, Request, Response } from 'express';
 import { EndpointDependencies } from '../types.js';
+import { getServerConfig } from '../../app/config.js';
+import { logger } from '../../app/logger.js';
 
 export function createHealthRouter(deps: EndpointDependencies): Router {
   const router = Router();
@@ -14,5 +16,20 @@ export function createHealthRouter(deps: EndpointDependencies): Router {
     });
   });
 
+  // Readiness: 200 only when Proton requests can be made, 503 with a reason otherwise.
+  // Without an auth manager (mock mode) there's nothing to check.
+  router.get('/healthz', async (req: Request, res: Response) => {
+    const readiness = deps.authManager
+      ? await deps.authManager.checkReadiness(getServerConfig().healthzPing)
+      : { ready: true };
+
+    if (!readiness.ready) {
+      logger.debug({ reason: readiness.reason }, '[Server] Readiness check failed');
+      res.status(503).json({ status: 'unavailable', reason: readiness.reason });
+      return;
+    }
+    res.json({ status: 'ok' });
+  });
+
   return router;
 }
@@ -6,16 +6,16 @@ export function createModelsRouter(): Router {
   const serverConfig = getServerConfig();
 
   router.get('/v1/models', (req: Request, res: Response) => {
+    const created = Date.now();
+    const ids = [serverConfig.apiModelName, ...serverConfig.models.filter(m => m !== serverConfig.apiModelName)];
     res.json({
       object: 'list',
-      data: [
-        {
-          id: serverConfig.apiModelName,
-          object: 'model',
-          created: Date.now(),
-          owned_by: 'proton',
-        },
-      ],
+      data: ids.map(id => ({
+        id,
+        object: 'model',
+        created,
+        owned_by: 'proton',
+      })),
     });
   });
 
@@ -1,6 +1,7 @@
 import { Response } from 'express';
 import { randomUUID } from 'crypto';
 import { ResponseStreamEvent, OpenAIResponse } from '../../types.js';
+import { stringifyWellFormed } from '../../well-formed.js';
 
 export class ResponseEventEmitter {
   private res: Response;
@@ -12,7 +13,7 @@ export class ResponseEventEmitter {
 
   private emit(event: ResponseStreamEvent): void {
     this.res.write(`event: ${event.type}\n`);
-    this.res.write(`data: ${JSON.stringify(event)}\n\n`);
+    this.res.write(`data: ${stringifyWellFormed(event)}\n\n`);
   }
 
   private baseResponseObject(responseId: string, createdAt: number, model: string): Partial<OpenAIResponse> {
@@ -121,6 +122,67 @@ export class ResponseEventEmitter {
     });
   }
 
+  emitReasoningStart(itemId: string, outputIndex: number): void {
+    // Event: response.output_item.added (for reasoning)
+    this.emit({
+      type: 'response.output_item.added',
+      item: { type: 'reasoning', id: itemId, status: 'in_progress', summary: [] },
+      output_index: outputIndex,
+      sequence_number: this.sequenceNumber++,
+    });
+
+    // Event: response.reasoning_summary_part.added
+    this.emit({
+      type: 'response.reasoning_summary_part.added',
+      item_id: itemId,
+      output_index: outputIndex,
+      summary_index: 0,
+      part: { type: 'summary_text', text: '' },
+      sequence_number: this.sequenceNumber++,
+    });
+  }
+
+  emitReasoningDelta(itemId: string, outputIndex: number, delta: string): void {
+    this.emit({
+      type: 'response.reasoning_summary_text.delta',
+      item_id: itemId,
+      output_index: outputIndex,
+      summary_index: 0,
+      delta,
+      sequence_number: this.sequenceNumber++,
+    });
+  }
+
+  emitReasoningDone(itemId: string, outputIndex: number, text: string): void {
+    // Event: response.reasoning_summary_text.done
+    this.emit({
+      type: 'response.reasoning_summary_text.done',
+      item_id: itemId,
+      output_index: outputIndex,
+      summary_index: 0,
+      text,
+      sequence_number: this.sequenceNumber++,
+    });
+
+    // Event: response.reasoning_summary_part.done
+    this.emit({
+      type: 'response.reasoning_summary_part.done',
+      item_id: itemId,
+      output_index: outputIndex,
+      summary_index: 0,
+      part: { type: 'summary_text', text },
+      sequence_number: this.sequenceNumber++,
+    });
+
+    // Event: response.output_item.done
+    this.emit({
+      type: 'response.output_item.done',
+      item: { type: 'reasoning', id: itemId, status: 'completed', summary: [{ type: 'summary_text', text }] },
+      output_index: outputIndex,
+      sequence_number: this.sequenceNumber++,
+    });
+  }
+
   emitFunctionCallEvents(fcId: string, callId: string, name: string, args: string, outputIndex: number): void {
     // Event: response.output_item.added (for function call)
     this.emit({
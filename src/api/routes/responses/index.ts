@@ -7,6 +7,7 @@ import { buildInstructions } from '../../instructions.js';
 import { getConversationsConfig, getServerInstructionsConfig } from '../../../app/config.js';
 import { getMetrics } from '../../../app/metrics.js';
 import { trackCustomToolCompletion } from '../../tools/call-id.js';
+import { persistAndBuildTurns, resolveLanguage } from '../shared.js';
 import { sendInvalidRequest, sendServerError } from '../../error-handler.js';
 import { deterministicUUID } from '../../../app/id-generator.js';
 
@@ -82,6 +83,10 @@ export function createResponsesRouter(deps: EndpointDependencies): Router {
         conversationId = generateConversationIdFromUser(request.user);
       }
       // No else - leave undefined for stateless requests
+      if (!getConversationsConfig().persist) {
+        // Persistence disabled: every request is stateless
+        conversationId = undefined;
+      }
 
       // ===== STEP 2: Validate input =====
       if (request.input === undefined || request.input === null) {
@@ -101,7 +106,8 @@ export function createResponsesRouter(deps: EndpointDependencies): Router {
       const turns = convertOpenAIResponseMessages(request.input, request.instructions);
 
       // ===== Build instructions (injected in LumoClient, not persisted) =====
-      const instructions = buildInstructions(request.tools, request.instructions);
+      const language = resolveLanguage(request, req.get('accept-language'), conversationId);
+      const instructions = buildInstructions(request.tools, request.instructions, { user: request.user, language });
       const { injectInto } = getServerInstructionsConfig();
 
       // ===== STEP 4: Track tool completions =====
@@ -114,17 +120,16 @@ export function createResponsesRouter(deps: EndpointDependencies): Router {
         }
       }
 
-      // ===== STEP 5: Persist incoming messages (stateful only) =====
-      if (conversationId && deps.conversationStore && turns.length > 0) {
-        deps.conversationStore.appendMessages(conversationId, turns);
-        logger.debug({ conversationId, messageCount: turns.length }, 'Persisted conversation messages');
-      } else if (!conversationId) {
+      // ===== STEP 5: Persist incoming messages and build history =====
+      // Stateful requests re-send stored history if the client only sent new messages
+      const history = persistAndBuildTurns(deps, conversationId, turns);
+      if (!conversationId) {
         // Stateless request - track +1 user message (not deduplicated)
         getMetrics()?.messagesTotal.inc({ role: 'user' });
       }
 
       // ===== STEP 6: Add to queue and process =====
-      await handleRequest(res, deps, request, turns, conversationId, request.stream ?? false, instructions, injectInto);
+      await handleRequest(res, deps, request, history, conversationId, request.stream ?? false, instructions, injectInto);
     } catch (error) {
       logger.error('Error processing response:');
       logger.error(error);
@@ -6,6 +6,7 @@ import {
   OutputItem,
   MessageOutputItem,
   FunctionCallOutputItem,
+  ReasoningOutputItem,
 } from '../../types.js';
 import { getServerConfig } from '../../../app/config.js';
 import { logger } from '../../../app/logger.js';
@@ -17,16 +18,34 @@ import { createStreamingToolProcessor } from '../../tools/streaming-processor.js
 import {
   buildRequestContext,
   persistTitle,
+  recordUsage,
   persistAssistantTurn,
   generateResponseId,
   generateItemId,
   generateFunctionCallId,
+  generateReasoningItemId,
   mapToolCallsForPersistence,
   tryExecuteCommand,
   setSSEHeaders,
+  startSSEKeepAlive,
+  abortOnClientClose,
+  waitForDrain,
+  getStreamTimeoutOptions,
+  observeLumoRequest,
+  resolveWebSearch,
+  resolveMaxTokens,
+  resolveStop,
+  withCitationFormatting,
+  withDeltaCoalescing,
+  withReasoning,
+  withResponseLimit,
+  withStopSequences,
+  resolveModel,
+  resolveSampling,
   type ToolCallForPersistence,
 } from '../shared.js';
 import { sendServerError } from '../../error-handler.js';
+import { sendWellFormedJson, stringifyWellFormed } from '../../well-formed.js';
 
 // ── Output building ────────────────────────────────────────────────
 
@@ -39,10 +58,12 @@ interface BuildOutputOptions {
   text: string;
   toolCalls?: ToolCall[] | null;
   itemId?: string;
+  /** Lumo's reasoning, only with server.reasoning: emit */
+  reasoning?: { id: string; text: string };
 }
 
 function buildOutputItems(options: BuildOutputOptions): OutputItem[] {
-  const { text, toolCalls, itemId } = options;
+  const { text, toolCalls, itemId, reasoning } = options;
 
   const messageItem: MessageOutputItem = {
     type: 'message',
@@ -60,11 +81,20 @@ function buildOutputItems(options: BuildOutputOptions): OutputItem[] {
 
   const output: OutputItem[] = [messageItem];
 
+  if (reasoning) {
+    output.push({
+      type: 'reasoning',
+      id: reasoning.id,
+      status: 'completed',
+      summary: [{ type: 'summary_text', text: reasoning.text }],
+    } satisfies ReasoningOutputItem);
+  }
+
   if (toolCalls && toolCalls.length > 0) {
     for (const toolCall of toolCalls) {
       const argumentsJson = typeof toolCall.arguments === 'string'
         ? toolCall.arguments
-        : JSON.stringify(toolCall.arguments);
+        : stringifyWellFormed(toolCall.arguments);
 
       // Use pre-generated call_id if available, otherwise generate new one
       const callId = 'call_id' in toolCall ? (toolCall as ToolCallForPersistence).call_id : generateCallId(toolCall.name);
@@ -89,6 +119,7 @@ function createCompletedResponse(
   responseId: string,
   createdAt: number,
   request: OpenAIResponseRequest,
+  model: string,
   output: OutputItem[]
 ): OpenAIResponse {
   return {
@@ -101,7 +132,7 @@ function createCompletedResponse(
     incomplete_details: null,
     instructions: request.instructions ?? null,
     max_output_tokens: request.max_output_tokens ?? request.max_tokens ?? null,
-    model: request.model || getServerConfig().apiModelName,
+    model,
     output,
     parallel_tool_calls: false,
     previous_response_id: request.previous_response_id ?? null,
@@ -118,7 +149,7 @@ function createCompletedResponse(
     },
     tool_choice: request.tools && request.tools.length > 0 ? 'auto' : 'none',
     tools: request.tools ?? [],
-    top_p: 1.0,
+    top_p: request.top_p ?? 1.0,
     truncation: 'auto',
     usage: null,
     user: request.user ?? null,
@@ -141,15 +172,22 @@ export async function handleRequest(
   const id = generateResponseId();
   const itemId = generateItemId();
   const createdAt = Math.floor(Date.now() / 1000);
-  const model = request.model || getServerConfig().apiModelName;
+  const model = resolveModel(request.model);
+  const sampling = resolveSampling(request);
+  const webSearch = resolveWebSearch(request, conversationId);
+  const maxTokens = resolveMaxTokens(request.max_output_tokens ?? request.max_tokens);
+  const stop = resolveStop(request.stop);
   const ctx = buildRequestContext(deps, conversationId, request.tools);
+  const signal = abortOnClientClose(res);
 
   // Streaming setup
   const emitter = streaming ? new ResponseEventEmitter(res) : null;
+  let stopKeepAlive = () => {};
   if (emitter) {
     setSSEHeaders(res);
-    emitter.emitResponseCreated(id, createdAt, model);
-    emitter.emitResponseInProgress(id, createdAt, model);
+    stopKeepAlive = startSSEKeepAlive(res);
+    emitter.emitResponseCreated(id, createdAt, model.name);
+    emitter.emitResponseInProgress(id, createdAt, model.name);
     emitter.emitOutputItemAdded(
       { id: itemId, type: 'message', role: 'assistant', status: 'in_progress', content: [] },
       0
@@ -160,43 +198,90 @@ export async function handleRequest(
   logger.debug({ hasCustomTools: ctx.hasCustomTools, toolCount: request.tools?.length }, '[Server] Tool detector state');
 
   let accumulatedText = '';
+  // Output index is assigned at the first reasoning delta, empty text means there was none
+  const reasoningItem = { id: generateReasoningItemId(), outputIndex: 0, text: '' };
   let toolCallsForPersist: ToolCallForPersistence[] | undefined;
 
   // Check for command before calling Lumo
   const commandResult = await tryExecuteCommand(turns, ctx.commandContext);
   if (commandResult) {
     accumulatedText = commandResult.response;
+    stopKeepAlive();
     emitter?.emitOutputTextDelta(itemId, 0, 0, accumulatedText);
   } else {
     // Normal flow: call Lumo
     let nextOutputIndex = 1;
+    const coalesced = withDeltaCoalescing(emitter ? getServerConfig().flushIntervalMs : 0, signal, (delta) => {
+      emitter?.emitOutputTextDelta(itemId, 0, 0, delta);
+    });
+    const limited = withResponseLimit(maxTokens, (delta) => {
+      stopKeepAlive();
+      accumulatedText += delta;
+      coalesced.emit(delta);
+    });
+    const text = withCitationFormatting(webSearch, limited.emit);
+    const stopped = withStopSequences(stop, text.emit);
+    const reasoning = withReasoning((delta) => {
+      stopKeepAlive();
+      coalesced.flush();
+      if (!reasoningItem.text) {
+        reasoningItem.outputIndex = nextOutputIndex++;
+        emitter?.emitReasoningStart(reasoningItem.id, reasoningItem.outputIndex);
+      }
+      reasoningItem.text += delta;
+      emitter?.emitReasoningDelta(reasoningItem.id, reasoningItem.outputIndex, delta);
+    });
     const processor = createStreamingToolProcessor(ctx.hasCustomTools, {
-      emitTextDelta(text) {
-        accumulatedText += text;
-        emitter?.emitOutputTextDelta(itemId, 0, 0, text);
-      },
+      emitTextDelta: stopped.emit,
       emitToolCall(callId, tc) {
-        emitter?.emitFunctionCallEvents(id, callId, tc.name, JSON.stringify(tc.arguments), nextOutputIndex++);
+        stopKeepAlive();
+        coalesced.flush();
+        emitter?.emitFunctionCallEvents(id, callId, tc.name, stringifyWellFormed(tc.arguments), nextOutputIndex++);
       },
-    });
+    }, request.tools);
 
     try {
       const result = await deps.queue.add(async () =>
-        deps.lumoClient.chatWithHistory(turns, processor.onChunk, {
+        observeLumoRequest(signal, () => deps.lumoClient.chatWithHistory(turns, processor.onChunk, {
           requestTitle: ctx.requestTitle,
           instructions,
           injectInstructionsInto,
-        })
+          model: model.upstream,
+          ...sampling,
+          enableWebSearch: webSearch,
+          maxTokens,
+          stop,
+          signal,
+          ...getStreamTimeoutOptions(),
+          ...(emitter ? { waitForConsumer: waitForDrain(res, signal) } : {}),
+          ...reasoning.options,
+        })),
+        signal
       );
 
       logger.debug('[Server] Stream completed');
       processor.finalize();
+      stopped.flush();
+      text.flush();
+      limited.flush();
+      coalesced.flush();
+      reasoning.flush();
+      if (reasoningItem.text) {
+        emitter?.emitReasoningDone(reasoningItem.id, reasoningItem.outputIndex, reasoningItem.text);
+      }
       persistTitle(result, deps, conversationId);
+      recordUsage(result, conversationId);
       toolCallsForPersist = mapToolCallsForPersistence(processor.toolCallsEmitted);
 
-      persistAssistantTurn(deps, conversationId, result.message, toolCallsForPersist);
+      // Persist what the client got, after max_tokens and stop cut it short
+      persistAssistantTurn(deps, conversationId, { ...result.message, content: accumulatedText }, toolCallsForPersist);
     } catch (error) {
+      if (signal.aborted) {
+        logger.info({ conversationId }, '[Server] Client disconnected, cancelled Lumo request');
+        return;
+      }
       logger.error({ error: String(error) }, 'Response error');
+      coalesced.flush();
       if (emitter) {
         emitter.emitError(error as Error);
         res.end();
@@ -209,8 +294,8 @@ export async function handleRequest(
 
   // Build and send response (shared for both command and normal flow)
   try {
-    const output = buildOutputItems({ text: accumulatedText, itemId, toolCalls: toolCallsForPersist });
-    const response = createCompletedResponse(id, createdAt, request, output);
+    const output = buildOutputItems({ text: accumulatedText, itemId, toolCalls: toolCallsForPersist, reasoning: reasoningItem.text ? reasoningItem : undefined });
+    const response = createCompletedResponse(id, createdAt, request, model.name, output);
 
     if (emitter) {
       emitter.emitOutputTextDone(itemId, 0, 0, accumulatedText);
@@ -228,7 +313,7 @@ export async function handleRequest(
       emitter.emitResponseCompleted(response);
       res.end();
     } else {
-      res.json(response);
+      sendWellFormedJson(res, response);
     }
   } catch (error) {
     logger.error({ error: String(error) }, 'Error sending response');
@@ -1,11 +1,20 @@
 import { randomUUID } from 'crypto';
+import bytes from 'bytes';
 import type { Response } from 'express';
-import { getCustomToolsConfig } from '../../app/config.js';
+import { getConversationsConfig, getCustomToolsConfig, getEnableWebSearch, getServerConfig } from '../../app/config.js';
+import { logger } from '../../app/logger.js';
 import { getMetrics } from '../../app/metrics';
+import { getUsageTracker } from '../../app/usage.js';
 import type { CommandContext } from '../../app/commands.js';
+import { CitationFormatter } from '../citation-formatter.js';
+import { CodePointBuffer } from '../code-point-buffer.js';
+import { DeltaCoalescer } from '../delta-coalescer.js';
+import { ResponseLimiter } from '../response-limiter.js';
+import { StopSequenceMatcher } from '../stop-sequences.js';
+import { toFunctionDefinitions } from '../tools/schema.js';
 import type { EndpointDependencies, OpenAITool, OpenAIToolCall } from '../types.js';
 import type { ConversationId } from '../../conversations/types.js';
-import type { ChatResult, AssistantMessageData } from '../../lumo-client/index.js';
+import { Role, type ChatResult, type AssistantMessageData, type Turn, type LumoClientOptions } from '../../lumo-client/index.js';
 
 // Re-export for convenience
 export { tryExecuteCommand, type CommandResult } from '../../app/commands.js';
@@ -53,7 +62,7 @@ export function buildRequestContext(
 ): RequestContext {
   const serverToolsConfig = getCustomToolsConfig();
   return {
-    hasCustomTools: serverToolsConfig.enabled && !!tools && tools.length > 0,
+    hasCustomTools: serverToolsConfig.enabled && !!tools && toFunctionDefinitions(tools).length > 0,
     commandContext: {
       syncInitialized: deps.syncInitialized ?? false,
       conversationId,
@@ -66,6 +75,463 @@ export function buildRequestContext(
   };
 }
 
+// ── Model selection ────────────────────────────────────────────────
+
+export interface ResolvedModel {
+  /** Model name reported back to the client */
+  name: string;
+  /** Model to request from Lumo, undefined to leave the choice to Lumo */
+  upstream: string | undefined;
+}
+
+/**
+ * Resolve the model requested by the client against the configured models.
+ * Unknown models fall back to server.apiModelName with a warning.
+ */
+export function resolveModel(requested: string | undefined): ResolvedModel {
+  const { apiModelName, models } = getServerConfig();
+  let name = requested || apiModelName;
+  if (name !== apiModelName && !models.includes(name)) {
+    logger.warn({ requested: name, fallback: apiModelName }, '[Server] Unknown model requested, using default');
+    name = apiModelName;
+  }
+  const upstream = name === apiModelName ? undefined : name;
+  logger.debug({ model: name, upstream: upstream ?? '(Lumo default)' }, '[Server] Resolved model');
+  return { name, upstream };
+}
+
+// ── Sampling ───────────────────────────────────────────────────────
+
+export interface SamplingOptions {
+  temperature?: number;
+  topP?: number;
+}
+
+const SAMPLING_RANGES = {
+  temperature: { min: 0, max: 2 },
+  topP: { min: 0, max: 1 },
+} as const;
+
+/**
+ * Resolve the sampling parameters to send to Lumo: the request's, else server.sampling.
+ * Out-of-range values are clamped, invalid ones ignored, both with a warning.
+ */
+export function resolveSampling(request: { temperature?: unknown; top_p?: unknown }): SamplingOptions {
+  const { sampling } = getServerConfig();
+  const temperature = clampSamplingParameter('temperature', request.temperature ?? sampling.temperature);
+  const topP = clampSamplingParameter('topP', request.top_p ?? sampling.topP);
+  logger.debug({
+    temperature: temperature ?? '(Lumo default)',
+    topP: topP ?? '(Lumo default)',
+  }, '[Server] Resolved sampling parameters');
+  return { temperature, topP };
+}
+
+function clampSamplingParameter(name: keyof typeof SAMPLING_RANGES, value: unknown): number | undefined {
+  if (value === undefined || value === null) return undefined;
+  if (typeof value !== 'number' || !Number.isFinite(value)) {
+    logger.warn({ parameter: name, value }, '[Server] Ignoring invalid sampling parameter');
+    return undefined;
+  }
+  const { min, max } = SAMPLING_RANGES[name];
+  const clamped = Math.min(max, Math.max(min, value));
+  if (clamped !== value) {
+    logger.warn({ parameter: name, requested: value, clamped }, '[Server] Sampling parameter out of range, clamped');
+  }
+  return clamped;
+}
+
+// ── Response length ────────────────────────────────────────────────
+
+/**
+ * Resolve the response length limit: the request's max tokens, else server.maxTokens.default,
+ * bounded by server.maxTokens.cap. Undefined for no limit.
+ */
+export function resolveMaxTokens(requested: unknown): number | undefined {
+  const { default: defaultMaxTokens, cap } = getServerConfig().maxTokens;
+  let maxTokens = defaultMaxTokens ?? undefined;
+  if (requested !== undefined && requested !== null) {
+    if (typeof requested === 'number' && Number.isInteger(requested) && requested > 0) {
+      maxTokens = requested;
+    } else {
+      logger.warn({ requested }, '[Server] Ignoring invalid max tokens');
+    }
+  }
+  if (cap !== null && (maxTokens === undefined || maxTokens > cap)) {
+    maxTokens = cap;
+  }
+  logger.debug({ maxTokens: maxTokens ?? '(no limit)' }, '[Server] Resolved max tokens');
+  return maxTokens;
+}
+
+/**
+ * Wrap a text callback to cut the response off at maxTokens (see ResponseLimiter).
+ * Call flush() at end of stream for held-back text. The response keeps its normal
+ * finish status: Home Assistant fails on incomplete responses, the note tells the rest.
+ */
+export function withResponseLimit(
+  maxTokens: number | undefined,
+  emit: (text: string) => void
+): { emit: (text: string) => void; flush: () => void } {
+  if (maxTokens === undefined) {
+    return { emit, flush: () => {} };
+  }
+  const limiter = new ResponseLimiter(maxTokens, getServerConfig().maxTokens.note);
+  return {
+    emit: (text) => {
+      const wasTruncated = limiter.truncated;
+      const limited = limiter.push(text);
+      if (limited) emit(limited);
+      if (!wasTruncated && limiter.truncated) {
+        logger.info({ maxTokens }, '[Server] Response exceeded max tokens, cut off');
+      }
+    },
+    flush: () => {
+      const rest = limiter.flush();
+      if (rest) emit(rest);
+    },
+  };
+}
+
+// ── Stop sequences ─────────────────────────────────────────────────
+
+/** OpenAI's limit on stop sequences per request */
+const MAX_STOP_SEQUENCES = 4;
+
+/**
+ * Resolve the stop sequences: the request's `stop` (a string or a list), else server.stop.
+ * Invalid values are ignored with a warning, empty strings dropped, at most 4 kept.
+ */
+export function resolveStop(requested: unknown): string[] {
+  let stop = getServerConfig().stop;
+  if (requested !== undefined && requested !== null) {
+    const list = typeof requested === 'string' ? [requested] : requested;
+    if (Array.isArray(list) && list.every(s => typeof s === 'string')) {
+      stop = list.filter(s => s.length > 0);
+      if (stop.length > MAX_STOP_SEQUENCES) {
+        logger.warn({ count: stop.length }, `[Server] More than ${MAX_STOP_SEQUENCES} stop sequences, ignoring the rest`);
+        stop = stop.slice(0, MAX_STOP_SEQUENCES);
+      }
+    } else {
+      logger.warn({ requested }, '[Server] Ignoring invalid stop sequences');
+    }
+  }
+  logger.debug({ stop }, '[Server] Resolved stop sequences');
+  return stop;
+}
+
+/**
+ * Wrap a text callback to end the response right before the first stop sequence
+ * (see StopSequenceMatcher). Call flush() at end of stream for held-back text.
+ */
+export function withStopSequences(
+  stop: string[],
+  emit: (text: string) => void
+): { emit: (text: string) => void; flush: () => void } {
+  if (stop.length === 0) {
+    return { emit, flush: () => {} };
+  }
+  const matcher = new StopSequenceMatcher(stop);
+  return {
+    emit: (text) => {
+      const wasStopped = matcher.stopped;
+      const trimmed = matcher.push(text);
+      if (trimmed) emit(trimmed);
+      if (!wasStopped && matcher.stopped) {
+        logger.info('[Server] Stop sequence reached, ending response');
+      }
+    },
+    flush: () => {
+      const rest = matcher.flush();
+      if (rest) emit(rest);
+    },
+  };
+}
+
+// ── Delta coalescing ───────────────────────────────────────────────
+
+/**
+ * Wrap a text callback to send fewer, larger deltas (see DeltaCoalescer). Held text
+ * goes out intervalMs after it arrived at the latest, 0 disables coalescing.
+ * Call flush() at end of stream, and before emitting anything else to keep the order.
+ */
+export function withDeltaCoalescing(
+  intervalMs: number,
+  signal: AbortSignal,
+  emit: (text: string) => void
+): { emit: (text: string) => void; flush: () => void } {
+  if (intervalMs <= 0) {
+    return { emit, flush: () => {} };
+  }
+  const coalescer = new DeltaCoalescer(intervalMs);
+  let timer: NodeJS.Timeout | undefined;
+  const clearTimer = () => {
+    clearTimeout(timer);
+    timer = undefined;
+  };
+  signal.addEventListener('abort', clearTimer, { once: true });
+
+  return {
+    emit: (text) => {
+      const released = coalescer.push(text);
+      if (released) emit(released);
+      if (coalescer.holding && !timer && !signal.aborted) {
+        timer = setTimeout(() => {
+          timer = undefined;
+          const held = coalescer.take();
+          if (held) emit(held);
+        }, intervalMs);
+      }
+    },
+    flush: () => {
+      clearTimer();
+      const rest = coalescer.flush();
+      if (rest) emit(rest);
+    },
+  };
+}
+
+// ── Reasoning ──────────────────────────────────────────────────────
+
+/**
+ * Handle Lumo's reasoning per server.reasoning, keeping it out of the answer:
+ * dropped ('discard'), logged at debug by flush() ('log'), or passed to emit ('emit')
+ * in whole code points. Call flush() at end of stream.
+ * Also reasoning inside server.reasoningMarkers in the answer itself.
+ * Returns the LumoClient options for it.
+ */
+export function withReasoning(
+  emit: (text: string) => void
+): { options: Pick<LumoClientOptions, 'onReasoning' | 'reasoningMarkers'>; flush: () => void } {
+  const { reasoning: mode, reasoningMarkers } = getServerConfig();
+  if (mode === 'discard') {
+    return { options: { reasoningMarkers }, flush: () => {} };
+  }
+  if (mode === 'emit') {
+    // Unlike answer text, reasoning skips the tool processor and its CodePointBuffer
+    const codePoints = new CodePointBuffer();
+    return {
+      options: {
+        onReasoning: (delta) => {
+          const text = codePoints.push(delta);
+          if (text) emit(text);
+        },
+        reasoningMarkers,
+      },
+      flush: () => {
+        const rest = codePoints.flush();
+        if (rest) emit(rest);
+      },
+    };
+  }
+  let text = '';
+  return {
+    options: { onReasoning: (delta) => { text += delta; }, reasoningMarkers },
+    flush: () => {
+      if (text) logger.debug({ reasoning: text }, 'Lumo reasoning');
+    },
+  };
+}
+
+// ── Conversation history ───────────────────────────────────────────
+
+/** Rough token estimate, ~4 characters per token */
+function estimateTokens(turn: Turn): number {
+  return Math.ceil((turn.content?.length ?? 0) / 4);
+}
+
+/**
+ * Drop the oldest turns until the estimated token count fits maxTokens (0 = no limit).
+ * The last turn is always kept, and the kept history starts with a user turn.
+ */
+export function trimTurnsToTokenBudget(turns: Turn[], maxTokens: number): Turn[] {
+  if (maxTokens <= 0 || turns.length === 0) return turns;
+
+  let start = turns.length - 1;
+  let total = estimateTokens(turns[start]);
+  while (start > 0 && total + estimateTokens(turns[start - 1]) <= maxTokens) {
+    start--;
+    total += estimateTokens(turns[start]);
+  }
+  // Don't start with an answer to a dropped question
+  while (start > 0 && start < turns.length - 1 && turns[start].role !== Role.User) start++;
+
+  if (start > 0) {
+    logger.debug({ dropped: start, kept: turns.length - start, maxTokens }, '[Server] Trimmed conversation history to token budget');
+  }
+  return turns.slice(start);
+}
+
+/**
+ * Persist incoming turns and build the turns to send to Lumo.
+ *
+ * Clients that send their full chat log get it back as is, also when they edited it.
+ * For clients that send fewer messages than stored (only new messages, e.g. with
+ * previous_response_id, or a truncated log), the stored history is re-sent.
+ * Either way, history is trimmed to conversations.maxHistoryTokens.
+ */
+export function persistAndBuildTurns(
+  deps: EndpointDependencies,
+  conversationId: ConversationId | undefined,
+  turns: Turn[]
+): Turn[] {
+  const { maxHistoryTokens } = getConversationsConfig();
+  const store = deps.conversationStore;
+  if (!conversationId || !store || turns.length === 0) {
+    return trimTurnsToTokenBudget(turns, maxHistoryTokens);
+  }
+
+  const sendsFullLog = turns.length >= store.getMessages(conversationId).length;
+  store.appendMessages(conversationId, turns);
+  logger.debug({ conversationId, messageCount: turns.length }, 'Persisted conversation messages');
+
+  const history = sendsFullLog ? turns : store.toTurns(conversationId);
+  if (!sendsFullLog) {
+    logger.debug({ conversationId, storedCount: history.length }, '[Server] Re-sending stored conversation history');
+  }
+  return trimTurnsToTokenBudget(history, maxHistoryTokens);
+}
+
+// ── Usage ──────────────────────────────────────────────────────────
+
+/** Count the tokens of a Lumo request, for /v1/usage and metrics. */
+export function recordUsage(result: ChatResult, conversationId: ConversationId | undefined): void {
+  if (!result.usage) return;
+  const { promptTokens, completionTokens, estimated } = result.usage;
+  logger.debug({ conversationId, promptTokens, completionTokens, estimated }, '[Server] Token usage');
+
+  getUsageTracker().record(result.usage, conversationId);
+  const metrics = getMetrics();
+  metrics?.tokensTotal.inc({ type: 'prompt', estimated: String(estimated) }, promptTokens);
+  metrics?.tokensTotal.inc({ type: 'completion', estimated: String(estimated) }, completionTokens);
+}
+
+/**
+ * Run a Lumo request, recording its outcome and duration for metrics. Outcome is
+ * "cancelled" when the client disconnected, "truncated" when the stream stalled.
+ */
+export async function observeLumoRequest(
+  signal: AbortSignal,
+  request: () => Promise<ChatResult>
+): Promise<ChatResult> {
+  const metrics = getMetrics();
+  const startTime = process.hrtime.bigint();
+  const record = (outcome: string) => {
+    metrics?.upstreamRequestsTotal.inc({ outcome });
+    metrics?.upstreamRequestDuration.observe({ outcome }, Number(process.hrtime.bigint() - startTime) / 1e9);
+  };
+
+  try {
+    const result = await request();
+    record(result.truncated ? 'truncated' : 'success');
+    return result;
+  } catch (error) {
+    record(signal.aborted ? 'cancelled' : 'error');
+    throw error;
+  }
+}
+
+// ── Web search ─────────────────────────────────────────────────────
+
+/** OpenAI's built-in web search tools, e.g. sent by Home Assistant's web search option */
+const WEB_SEARCH_TOOL_TYPES = new Set(['web_search', 'web_search_preview']);
+
+export interface WebSearchRequest {
+  tools?: unknown[];
+  metadata?: Record<string, string>;
+  web_search_options?: unknown;
+}
+
+/**
+ * Whether Lumo's web search is enabled for a request. `metadata.web_search` ("true" or
+ * "false") wins, then a built-in web search tool or `web_search_options` enables it,
+ * else config enableWebSearch applies.
+ */
+export function resolveWebSearch(request: WebSearchRequest, conversationId: ConversationId | undefined): boolean {
+  let enabled = getEnableWebSearch();
+  let source = 'config';
+
+  const flag = String(request.metadata?.web_search ?? '').toLowerCase();
+  if (flag === 'true' || flag === 'false') {
+    enabled = flag === 'true';
+    source = 'metadata';
+  } else if (request.tools?.some(t => WEB_SEARCH_TOOL_TYPES.has((t as { type?: string })?.type ?? ''))) {
+    enabled = true;
+    source = 'tool';
+  } else if (request.web_search_options) {
+    enabled = true;
+    source = 'web_search_options';
+  }
+
+  logger.info({ conversationId, webSearch: enabled, source }, `[Server] Web search ${enabled ? 'enabled' : 'disabled'}`);
+  return enabled;
+}
+
+/**
+ * Wrap a text callback to format web search citations for TTS and plain text
+ * (server.formatCitations). Call flush() at end of stream for held-back text.
+ */
+export function withCitationFormatting(
+  webSearch: boolean,
+  emit: (text: string) => void
+): { emit: (text: string) => void; flush: () => void } {
+  if (!webSearch || !getServerConfig().formatCitations) {
+    return { emit, flush: () => {} };
+  }
+  const formatter = new CitationFormatter();
+  return {
+    emit: (text) => {
+      const formatted = formatter.push(text);
+      if (formatted) emit(formatted);
+    },
+    flush: () => {
+      const rest = formatter.flush();
+      if (rest) emit(rest);
+    },
+  };
+}
+
+// ── Language ───────────────────────────────────────────────────────
+
+/** Canonical form of a language tag, e.g. "nl-NL" for "nl-nl", undefined if it isn't one */
+function canonicalLanguage(tag: string): string | undefined {
+  try {
+    return Intl.getCanonicalLocales(tag)[0];
+  } catch {
+    return undefined;
+  }
+}
+
+/**
+ * The language Lumo should reply in: `metadata.language`, else the first language of the
+ * Accept-Language header, else config server.language. Undefined to let Lumo pick.
+ * Invalid language tags are ignored with a warning.
+ */
+export function resolveLanguage(
+  request: { metadata?: Record<string, string> },
+  acceptLanguage: string | undefined,
+  conversationId: ConversationId | undefined
+): string | undefined {
+  const candidates: Array<[source: string, tag: string | undefined]> = [
+    ['metadata', request.metadata?.language],
+    ['header', acceptLanguage?.split(',')[0].split(';')[0]],
+    ['config', getServerConfig().language],
+  ];
+  for (const [source, tag] of candidates) {
+    const trimmed = String(tag ?? '').trim();
+    if (!trimmed || trimmed === '*') continue;
+    const language = canonicalLanguage(trimmed);
+    if (!language) {
+      logger.warn({ conversationId, language: trimmed, source }, '[Server] Ignoring invalid language');
+      continue;
+    }
+    logger.info({ conversationId, language, source }, `[Server] Replying in ${language}`);
+    return language;
+  }
+  logger.debug({ conversationId }, '[Server] No reply language set');
+  return undefined;
+}
+
 // ── Persistence helpers ────────────────────────────────────────────
 
 /** Persist title if Lumo generated one. No-op for stateless requests. */
@@ -118,6 +584,11 @@ export function generateItemId(): string {
   return `item-${randomUUID()}`;
 }
 
+/** Generate a reasoning item ID (`rs-xxx`). */
+export function generateReasoningItemId(): string {
+  return `rs-${randomUUID()}`;
+}
+
 /** Generate a function call item ID (`fc-xxx`). */
 export function generateFunctionCallId(): string {
   return `fc-${randomUUID()}`;
@@ -136,3 +607,75 @@ export function setSSEHeaders(res: Response): void {
   res.setHeader('Cache-Control', 'no-cache');
   res.setHeader('Connection', 'keep-alive');
 }
+
+/** LumoClient options for server.streamTimeout */
+export function getStreamTimeoutOptions(): Pick<LumoClientOptions, 'idleTimeoutMs' | 'truncationNote'> {
+  const { idleSeconds, note } = getServerConfig().streamTimeout;
+  return { idleTimeoutMs: idleSeconds * 1000, truncationNote: note };
+}
+
+/**
+ * Abort signal for the Lumo request of a response. Fires when the client closes the
+ * connection before the response was sent, e.g. when Home Assistant cancels a pipeline.
+ */
+export function abortOnClientClose(res: Response): AbortSignal {
+  const controller = new AbortController();
+  res.on('close', () => {
+    if (!res.writableFinished) controller.abort(new Error('Client closed the connection'));
+  });
+  return controller.signal;
+}
+
+/**
+ * Backpressure for a streamed response: resolves right away while less than
+ * server.streamBufferSize is waiting to be sent, else once the client has read it
+ * all (the response's 'drain'), or the response closed or signal aborted.
+ * Pass as LumoClientOptions.waitForConsumer, so a slow client pauses Lumo's stream.
+ */
+export function waitForDrain(
+  res: Response,
+  signal: AbortSignal,
+  limit = bytes.parse(getServerConfig().streamBufferSize) ?? 0
+): () => Promise<void> {
+  return () => {
+    if (!res.writableNeedDrain || res.writableLength < limit || res.writableEnded || signal.aborted) {
+      return Promise.resolve();
+    }
+    logger.debug({ buffered: res.writableLength, limit }, '[Server] Client reads slowly, pausing Lumo stream');
+    return new Promise(resolve => {
+      const done = () => {
+        res.off('drain', done);
+        res.off('close', done);
+        signal.removeEventListener('abort', done);
+        resolve();
+      };
+      res.on('drain', done);
+      res.on('close', done);
+      signal.addEventListener('abort', done, { once: true });
+    });
+  };
+}
+
+/**
+ * Write an SSE comment every server.sseKeepAliveSeconds until stopped, so clients
+ * don't time out while Lumo is thinking. SSE clients ignore comment lines.
+ * Stops by itself when the response ends; call the returned function once content flows.
+ */
+export function startSSEKeepAlive(
+  res: Response,
+  intervalMs = getServerConfig().sseKeepAliveSeconds * 1000
+): () => void {
+  if (intervalMs <= 0) return () => {};
+
+  const timer = setInterval(() => {
+    if (!res.writableEnded) res.write(': ping\n\n');
+  }, intervalMs);
+  const stop = () => {
+    clearInterval(timer);
+    res.off('finish', stop);
+    res.off('close', stop);
+  };
+  res.on('finish', stop);
+  res.on('close', stop);
+  return stop;
+}
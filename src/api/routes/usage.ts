@@ -0,0 +1,12 @@
+import { Router, Request, Response } from 'express';
+import { getUsageTracker } from '../../app/usage.js';
+
+export function createUsageRouter(): Router {
+  const router = Router();
+
+  router.get('/v1/usage', (req: Request, res: Response) => {
+    res.json(getUsageTracker().getSummary());
+  });
+
+  return router;
+}
@@ -6,6 +6,7 @@ import { setupAuthMiddleware, setupLoggingMiddleware, setupMetricsMiddleware } f
 import { setupApiErrorHandler } from './error-handler.js';
 import { createHealthRouter } from './routes/health.js';
 import { createModelsRouter } from './routes/models.js';
+import { createUsageRouter } from './routes/usage.js';
 import { createChatCompletionsRouter } from './routes/chat-completions/index.js';
 import { createResponsesRouter } from './routes/responses/index.js';
 import { createAuthRouter } from './routes/auth.js';
@@ -18,8 +19,9 @@ import type { Application } from '../app/index.js';
 export class APIServer {
   private expressApp: express.Application;
   private serverConfig = getServerConfig();
-  private queue = new RequestQueue(1); // Process one request at a time
+  private queue = new RequestQueue(this.serverConfig.concurrency);
   private metrics: MetricsService | null = null;
+  private metricsListen = getMetricsConfig().listen;
 
   constructor(private app: Application) {
     this.expressApp = express();
@@ -43,13 +45,14 @@ export class APIServer {
   private setupRoutes(): void {
     const deps = this.getDependencies();
 
-    // Metrics endpoint (no auth required, like /health)
-    if (this.metrics) {
+    // Metrics endpoint (no auth required, like /health), unless served on its own address
+    if (this.metrics && !this.metricsListen) {
       this.expressApp.use(createMetricsRouter(this.metrics));
     }
 
     this.expressApp.use(createHealthRouter(deps));
     this.expressApp.use(createModelsRouter());
+    this.expressApp.use(createUsageRouter());
     this.expressApp.use(createChatCompletionsRouter(deps));
     this.expressApp.use(createResponsesRouter(deps));
     this.expressApp.use(createAuthRouter(deps));
@@ -72,9 +75,13 @@ export class APIServer {
   }
 
   async start(): Promise<void> {
-    const { validateTemplateOnce } = await import('./instructions.js');
+    const { validateTemplateOnce, watchInstructionsConfig } = await import('./instructions.js');
     validateTemplateOnce(this.serverConfig.instructions.template);
+    watchInstructionsConfig();
 
+    if (this.metrics && this.metricsListen) {
+      await this.startMetricsListener(this.metrics, this.metricsListen);
+    }
     return new Promise((resolve) => {
       this.expressApp.listen(this.serverConfig.port, () => {
         logger.info('========================================');
@@ -86,4 +93,24 @@ export class APIServer {
       });
     });
   }
+
+  /** Serve /metrics on a separate address: "port" or "host:port" */
+  private startMetricsListener(metrics: MetricsService, listen: string): Promise<void> {
+    const separator = listen.lastIndexOf(':');
+    // Strip brackets of IPv6 hosts, e.g. [::1]:9464
+    const host = separator > 0 ? listen.slice(0, separator).replace(/^\[|\]$/g, '') : undefined;
+    const port = Number(separator >= 0 ? listen.slice(separator + 1) : listen);
+
+    const metricsApp = express();
+    metricsApp.use(createMetricsRouter(metrics));
+
+    return new Promise((resolve, reject) => {
+      const onListening = () => {
+        logger.info(`  metrics:  http://${host ?? 'localhost'}:${port}/metrics`);
+        resolve();
+      };
+      const server = host ? metricsApp.listen(port, host, onListening) : metricsApp.listen(port, onListening);
+      server.once('error', reject);
+    });
+  }
 }
@@ -0,0 +1,58 @@
+/**
+ * Stop sequence enforcement for streamed text
+ *
+ * Stop sequences are sent to Lumo, but in case it ignores them the response is
+ * also trimmed here, right before the first stop sequence. Like CodePointBuffer,
+ * a chunk ending in what may be the start of a stop sequence is held back until
+ * the next chunk tells, so sequences split across chunks are still caught.
+ */
+
+export class StopSequenceMatcher {
+  private pending = '';
+  /** Whether a stop sequence was reached */
+  stopped = false;
+
+  constructor(private readonly sequences: string[]) {}
+
+  /** Add a chunk. Returns the text that may be emitted, which may be empty. */
+  push(chunk: string): string {
+    if (this.stopped) return '';
+    const text = this.pending + chunk;
+    this.pending = '';
+
+    let stopAt = -1;
+    for (const sequence of this.sequences) {
+      const index = text.indexOf(sequence);
+      if (index !== -1 && (stopAt === -1 || index < stopAt)) stopAt = index;
+    }
+    if (stopAt !== -1) {
+      this.stopped = true;
+      return text.slice(0, stopAt);
+    }
+
+    const end = text.length - this.partialMatchLength(text);
+    this.pending = text.slice(end);
+    return text.slice(0, end);
+  }
+
+  /** Emit whatever is left at end of stream. A held back partial match wasn't a stop sequence. */
+  flush(): string {
+    const rest = this.pending;
+    this.pending = '';
+    return rest;
+  }
+
+  /** Length of the longest end of text that is the start of a stop sequence */
+  private partialMatchLength(text: string): number {
+    let longest = 0;
+    for (const sequence of this.sequences) {
+      for (let length = Math.min(sequence.length - 1, text.length); length > longest; length--) {
+        if (text.endsWith(sequence.slice(0, length))) {
+          longest = length;
+          break;
+        }
+      }
+    }
+    return longest;
+  }
+}
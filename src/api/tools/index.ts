@@ -11,6 +11,14 @@ export {
   applyToolNamePrefix,
 } from './prefix.js';
 
+// Tool schema translation
+export {
+  toFunctionDefinitions,
+  coerceValue,
+  ToolSchemas,
+  type FunctionDefinition,
+} from './schema.js';
+
 // Tool call types
 export { isToolCallJson, type ParsedToolCall } from './types.js';
 
@@ -20,6 +28,13 @@ export { StreamingToolDetector, type ProcessResult } from './streaming-tool-dete
 // JSON brace tracking
 export { JsonBraceTracker } from './json-brace-tracker.js';
 
+// Streamed tool call arguments
+export {
+  ToolCallAccumulator,
+  isToolCallDelta,
+  type ToolCallDelta,
+} from './tool-call-accumulator.js';
+
 // Call ID utilities
 export {
   generateCallId,
@@ -8,11 +8,13 @@
  *
  * This processor:
  * - Parses streaming JSON via JsonBraceTracker
+ * - Accumulates tool calls streamed as argument deltas via ToolCallAccumulator
  * - Detects misrouted custom tools (custom tools Lumo mistakenly routed through native pipeline)
  * - Tracks success/failure metrics
  */
 
 import { JsonBraceTracker } from './json-brace-tracker.js';
+import { ToolCallAccumulator, isToolCallDelta } from './tool-call-accumulator.js';
 import { stripToolPrefix } from './prefix.js';
 import { getCustomToolsConfig } from '../../app/config.js';
 import { getMetrics } from '../../app/metrics.js';
@@ -26,22 +28,24 @@ const KNOWN_NATIVE_TOOLS = new Set([
 // ── Internal helpers ─────────────────────────────────────────────────
 
 /**
- * Parse a single complete JSON string as a native tool call.
- * Normalizes Lumo's `parameters` key to `arguments` for consistency with ParsedToolCall.
- * Returns null if JSON is invalid or doesn't contain a tool name.
+ * Normalize a parsed tool_call JSON object to a native tool call.
+ * Normalizes Lumo's `parameters` key to `arguments` for consistency with ParsedToolCall,
+ * parsing stringified arguments. Returns null if it doesn't contain a tool name.
  *
  * Handles Lumo's internal format quirk where `arguments` may be an object containing
  * `{arguments, name, parameters}` - in that case, extract `parameters` from the nested structure.
  */
-function parseToolCallJson(json: string): ParsedToolCall | null {
+function parseToolCallJson(parsed: any): ParsedToolCall | null {
   try {
-    const parsed = JSON.parse(json);
     if (typeof parsed !== 'object' || parsed === null || typeof parsed.name !== 'string') {
       return null;
     }
 
     // Lumo uses 'parameters', our ParsedToolCall uses 'arguments'
     let args = parsed.arguments ?? parsed.parameters ?? {};
+    if (typeof args === 'string') {
+      args = JSON.parse(args);
+    }
 
     // Handle Lumo's internal format quirk: if arguments contains nested {arguments, name, parameters},
     // extract the actual parameters from that nested structure
@@ -89,6 +93,7 @@ export interface NativeToolCallResult {
 export class NativeToolCallProcessor {
   private toolCallTracker = new JsonBraceTracker();
   private toolResultTracker = new JsonBraceTracker();
+  private deltaAccumulator = new ToolCallAccumulator();
   private firstToolCall: ParsedToolCall | null = null;
   private firstToolResult: string | null = null;
   private failed = false;
@@ -102,7 +107,7 @@ export class NativeToolCallProcessor {
   /** Feed tool_call SSE content. Returns true if should abort early. */
   feedToolCall(content: string): boolean {
     for (const json of this.toolCallTracker.feed(content)) {
-      const toolCall = parseToolCallJson(json);
+      const toolCall = this.parseToolCall(json);
       if (!toolCall) continue;
 
       // Save first for result (used by bounce logic)
@@ -152,6 +157,9 @@ export class NativeToolCallProcessor {
   /** Finalize processing. Call after stream ends. */
   finalize(): void {
     // Metrics tracked per tool call in feedToolCall()
+    for (const call of this.deltaAccumulator.getIncomplete()) {
+      logger.warn({ tool: call.name, arguments: call.arguments }, 'Streamed tool call ended with incomplete arguments');
+    }
   }
 
   /** Get the result after stream completes. */
@@ -164,6 +172,20 @@ export class NativeToolCallProcessor {
     };
   }
 
+  /**
+   * Parse a complete tool_call JSON object. Argument deltas are accumulated,
+   * returning the tool call once its arguments are complete.
+   */
+  private parseToolCall(json: string): ParsedToolCall | null {
+    let parsed: unknown;
+    try {
+      parsed = JSON.parse(json);
+    } catch {
+      return null;
+    }
+    return isToolCallDelta(parsed) ? this.deltaAccumulator.feed(parsed) : parseToolCallJson(parsed);
+  }
+
   private isMisrouted(toolCall: ParsedToolCall): boolean {
     return !KNOWN_NATIVE_TOOLS.has(toolCall.name);
   }
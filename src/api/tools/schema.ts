@@ -0,0 +1,199 @@
+/**
+ * Tool schema translation
+ *
+ * Converts client tool specs (e.g. Home Assistant's) into plain function
+ * definitions for Lumo, and maps Lumo's tool calls back onto them:
+ * - Tool names are matched case-insensitively to the declared name
+ * - Arguments are coerced to the declared JSON schema types (numbers, booleans, enums)
+ * - Empty optional arguments are dropped
+ */
+
+import { logger } from '../../app/logger.js';
+import type { OpenAITool } from '../types.js';
+import type { ParsedToolCall } from './types.js';
+
+type JsonSchema = Record<string, unknown>;
+
+/** Function definition as shown to Lumo. */
+export interface FunctionDefinition {
+  name: string;
+  description?: string;
+  parameters?: JsonSchema;
+}
+
+// ── Request time ──────────────────────────────────────────────────────
+
+/**
+ * Normalize tool specs to function definitions.
+ * Accepts the nested Chat Completions format ({ type, function: {...} })
+ * and the flat Responses format ({ type, name, ... }) used by Home Assistant.
+ * Client-specific fields like `strict` are dropped.
+ */
+export function toFunctionDefinitions(tools: OpenAITool[]): FunctionDefinition[] {
+  const definitions: FunctionDefinition[] = [];
+  for (const tool of tools) {
+    const spec = (tool?.function ?? tool) as Partial<FunctionDefinition> | undefined;
+    if (!spec?.name) continue;
+    definitions.push({
+      name: spec.name,
+      ...(spec.description ? { description: spec.description } : {}),
+      ...(spec.parameters ? { parameters: spec.parameters } : {}),
+    });
+  }
+  return definitions;
+}
+
+// ── Response time ─────────────────────────────────────────────────────
+
+/**
+ * Maps tool calls emitted by Lumo onto the tools of a request.
+ */
+export class ToolSchemas {
+  private definitions = new Map<string, FunctionDefinition>();
+
+  constructor(tools: OpenAITool[] = []) {
+    for (const definition of toFunctionDefinitions(tools)) {
+      this.definitions.set(definition.name.toLowerCase(), definition);
+    }
+  }
+
+  /**
+   * Return the tool call with its declared name and schema-coerced arguments.
+   * Calls to unknown tools are returned unchanged.
+   */
+  map(toolCall: ParsedToolCall): ParsedToolCall {
+    const definition = this.definitions.get(toolCall.name.toLowerCase());
+    if (!definition) {
+      logger.debug({ tool: toolCall.name }, '[Server] Tool call for undeclared tool, passing through');
+      return toolCall;
+    }
+    const args = definition.parameters
+      ? coerceValue(toolCall.arguments, definition.parameters)
+      : toolCall.arguments;
+    return {
+      name: definition.name,
+      arguments: isPlainObject(args) ? args : toolCall.arguments,
+    };
+  }
+}
+
+/**
+ * Coerce a value to a JSON schema, leaving it unchanged where it can't be coerced.
+ * Only handles what models commonly get wrong: stringified numbers and booleans,
+ * enum casing, single values for arrays and nulls for optional properties.
+ */
+export function coerceValue(value: unknown, schema: JsonSchema): unknown {
+  const variants = (schema.anyOf ?? schema.oneOf) as JsonSchema[] | undefined;
+  if (Array.isArray(variants)) {
+    for (const variant of variants) {
+      const coerced = coerceValue(value, variant);
+      if (matchesType(coerced, variant)) return coerced;
+    }
+    return value;
+  }
+
+  const types = schemaTypes(schema);
+  if (value === null && types.includes('null')) return value;
+
+  let result = value;
+  for (const type of types) {
+    const coerced = coerceToType(value, type, schema);
+    if (matchesType(coerced, { type })) {
+      result = coerced;
+      break;
+    }
+  }
+
+  if (Array.isArray(schema.enum)) {
+    result = matchEnum(result, schema.enum);
+  }
+  return result;
+}
+
+function coerceToType(value: unknown, type: string, schema: JsonSchema): unknown {
+  switch (type) {
+    case 'integer':
+    case 'number':
+      if (typeof value === 'string' && value.trim() !== '' && !isNaN(Number(value))) {
+        return Number(value);
+      }
+      return value;
+    case 'boolean':
+      if (typeof value === 'string') {
+        const lower = value.trim().toLowerCase();
+        if (lower === 'true') return true;
+        if (lower === 'false') return false;
+      }
+      return value;
+    case 'string':
+      return typeof value === 'number' || typeof value === 'boolean' ? String(value) : value;
+    case 'array': {
+      const items = isPlainObject(schema.items) ? schema.items : undefined;
+      const array = Array.isArray(value) ? value : [value];
+      return items ? array.map(item => coerceValue(item, items)) : array;
+    }
+    case 'object':
+      return isPlainObject(value) ? coerceObject(value, schema) : value;
+    default:
+      return value;
+  }
+}
+
+function coerceObject(value: Record<string, unknown>, schema: JsonSchema): Record<string, unknown> {
+  const properties = isPlainObject(schema.properties) ? schema.properties : {};
+  const required = Array.isArray(schema.required) ? schema.required : [];
+  const result: Record<string, unknown> = {};
+
+  for (const [key, propValue] of Object.entries(value)) {
+    const propSchema = properties[key];
+    // Models fill optional parameters with null or "" instead of leaving them out
+    const isEmpty = propValue === '' || (propValue === null && !allowsNull(propSchema));
+    if (isEmpty && !required.includes(key)) continue;
+    result[key] = isPlainObject(propSchema) ? coerceValue(propValue, propSchema) : propValue;
+  }
+  return result;
+}
+
+/** Whether the schema explicitly accepts null. */
+function allowsNull(schema: unknown): boolean {
+  if (!isPlainObject(schema)) return false;
+  const variants = (schema.anyOf ?? schema.oneOf) as JsonSchema[] | undefined;
+  if (Array.isArray(variants)) return variants.some(allowsNull);
+  return schemaTypes(schema).includes('null');
+}
+
+/** Match a value to an enum, ignoring case and surrounding whitespace. */
+function matchEnum(value: unknown, options: unknown[]): unknown {
+  if (options.includes(value)) return value;
+  const normalized = String(value).trim().toLowerCase();
+  const match = options.find(option => String(option).toLowerCase() === normalized);
+  return match !== undefined ? match : value;
+}
+
+function schemaTypes(schema: JsonSchema): string[] {
+  if (Array.isArray(schema.type)) return schema.type as string[];
+  if (typeof schema.type === 'string') return [schema.type];
+  if (isPlainObject(schema.properties)) return ['object'];
+  return [];
+}
+
+function matchesType(value: unknown, schema: JsonSchema): boolean {
+  const types = schemaTypes(schema);
+  if (types.length === 0) return true;
+  return types.some(type => {
+    switch (type) {
+      case 'integer': return Number.isInteger(value);
+      case 'number': return typeof value === 'number';
+      case 'boolean': return typeof value === 'boolean';
+      case 'string': return typeof value === 'string';
+      case 'array': return Array.isArray(value);
+      case 'object': return isPlainObject(value);
+      case 'null': return value === null;
+      default: return true;
+    }
+  });
+}
+
+function isPlainObject(value: unknown): value is Record<string, unknown> {
+  return typeof value === 'object' && value !== null && !Array.isArray(value);
+}
@@ -3,13 +3,23 @@
  *
  * Separates tool call JSON from normal text during streaming.
  * Uses StreamingToolDetector for detection and generateCallId for ID generation.
+ * Chunks pass through a CodePointBuffer first, so emitted text never ends
+ * in half a surrogate pair. Detected tool calls are mapped onto the request's
+ * tool schemas before they're emitted. Identical tool calls (same name and arguments)
+ * repeated within customTools.dedupWindowSeconds are emitted once, so the client
+ * doesn't run an action twice (e.g. adding "milk" to the shopping list twice).
  */
 
+import stableStringify from 'json-stable-stringify';
+import { getCustomToolsConfig } from '../../app/config.js';
 import { logger } from '../../app/logger.js';
 import { StreamingToolDetector } from './streaming-tool-detector.js';
 import { generateCallId } from './call-id.js';
+import { ToolSchemas } from './schema.js';
+import { CodePointBuffer } from '../code-point-buffer.js';
+import { stringifyWellFormed } from '../well-formed.js';
 import type { ParsedToolCall } from './types.js';
-import type { OpenAIToolCall } from '../types.js';
+import type { OpenAITool, OpenAIToolCall } from '../types.js';
 
 // ── Streaming tool processor ───────────────────────────────────────
 
@@ -34,39 +44,67 @@ export interface StreamingToolProcessor {
  * from normal text during streaming.
  *
  * Handlers provide format-specific emitter callbacks.
+ * Tools are the request's tool specs, used to fix up names and arguments of tool calls.
  */
 export function createStreamingToolProcessor(
   hasCustomTools: boolean,
-  emitter: StreamingToolEmitter
+  emitter: StreamingToolEmitter,
+  tools?: OpenAITool[]
 ): StreamingToolProcessor {
   const detector = hasCustomTools ? new StreamingToolDetector() : null;
+  const schemas = new ToolSchemas(tools);
+  const codePoints = new CodePointBuffer();
   const toolCallsEmitted: OpenAIToolCall[] = [];
+  const dedupWindowMs = getCustomToolsConfig().dedupWindowSeconds * 1000;
+  const emittedAt = new Map<string, number>();
+
+  /** Whether an identical tool call was already emitted within the dedup window */
+  function isDuplicate(tc: ParsedToolCall): boolean {
+    if (dedupWindowMs <= 0) return false;
+    const key = `${tc.name}:${stableStringify(tc.arguments)}`;
+    const now = Date.now();
+    const previous = emittedAt.get(key);
+    if (previous !== undefined && now - previous <= dedupWindowMs) {
+      logger.info({ tool: tc.name }, '[Server] Duplicate tool call suppressed');
+      return true;
+    }
+    emittedAt.set(key, now);
+    return false;
+  }
 
   function processToolCalls(completedToolCalls: ParsedToolCall[]): void {
-    for (const tc of completedToolCalls) {
+    for (const detected of completedToolCalls) {
+      const tc = schemas.map(detected);
+      if (isDuplicate(tc)) continue;
       const callId = generateCallId(tc.name);
       toolCallsEmitted.push({
         id: callId,
         type: 'function',
-        function: { name: tc.name, arguments: JSON.stringify(tc.arguments) },
+        function: { name: tc.name, arguments: stringifyWellFormed(tc.arguments) },
       });
       emitter.emitToolCall(callId, tc);
       logger.debug({ tool: tc.name }, '[Server] Tool call emitted in stream');
     }
   }
 
+  function processText(text: string): void {
+    if (!text) return;
+    if (detector) {
+      const { textToEmit, completedToolCalls } = detector.processChunk(text);
+      if (textToEmit) emitter.emitTextDelta(textToEmit);
+      processToolCalls(completedToolCalls);
+    } else {
+      emitter.emitTextDelta(text);
+    }
+  }
+
   return {
     toolCallsEmitted,
     onChunk(chunk: string): void {
-      if (detector) {
-        const { textToEmit, completedToolCalls } = detector.processChunk(chunk);
-        if (textToEmit) emitter.emitTextDelta(textToEmit);
-        processToolCalls(completedToolCalls);
-      } else {
-        emitter.emitTextDelta(chunk);
-      }
+      processText(codePoints.push(chunk));
     },
     finalize(): void {
+      processText(codePoints.flush());
       if (detector) {
         const { textToEmit, completedToolCalls } = detector.finalize();
         if (textToEmit) emitter.emitTextDelta(textToEmit);
@@ -0,0 +1,86 @@
+/**
+ * Accumulates tool calls whose arguments are streamed in fragments.
+ *
+ * Besides complete tool call objects, Lumo's tool_call target may send OpenAI-style
+ * deltas, where `arguments` is a JSON string split over several messages:
+ *   {"index":0,"id":"call_1","name":"web_search","arguments":"{\"query\":\"wea"}
+ *   {"index":0,"arguments":"ther\"}"}
+ * Fragments are concatenated per tool call, keyed on index (else id), so several
+ * calls can stream interleaved. Arguments are only parsed once they form a complete
+ * JSON object, tracked with JsonBraceTracker.
+ */
+
+import { JsonBraceTracker } from './json-brace-tracker.js';
+import { logger } from '../../app/logger.js';
+import type { ParsedToolCall } from './types.js';
+
+/** A tool call fragment, flat or nested in `function` as OpenAI streams them */
+export interface ToolCallDelta {
+  index?: number;
+  id?: string;
+  name?: string;
+  arguments?: string;
+  function?: { name?: string; arguments?: string };
+}
+
+interface PendingToolCall {
+  name?: string;
+  arguments: string;
+  tracker: JsonBraceTracker;
+}
+
+/**
+ * Whether parsed JSON is a tool call delta: arguments as a string, with an index or id
+ * to tell concurrent calls apart. Complete tool calls have an arguments object.
+ */
+export function isToolCallDelta(json: unknown): json is ToolCallDelta {
+  if (typeof json !== 'object' || json === null) return false;
+  const obj = json as ToolCallDelta;
+  const args = obj.function?.arguments ?? obj.arguments;
+  return typeof args === 'string' && (typeof obj.index === 'number' || typeof obj.id === 'string');
+}
+
+export class ToolCallAccumulator {
+  private pending = new Map<number | string, PendingToolCall>();
+  private lastKey: number | string | undefined;
+
+  /**
+   * Feed a delta. Returns the tool call once its arguments are complete, null until then.
+   * Deltas without index and id continue the last tool call.
+   */
+  feed(delta: ToolCallDelta): ParsedToolCall | null {
+    const key = delta.index ?? delta.id ?? this.lastKey ?? 0;
+    this.lastKey = key;
+
+    let call = this.pending.get(key);
+    if (!call) {
+      call = { arguments: '', tracker: new JsonBraceTracker() };
+      this.pending.set(key, call);
+    }
+
+    call.name ??= delta.function?.name ?? delta.name;
+    const fragment = delta.function?.arguments ?? delta.arguments ?? '';
+    call.arguments += fragment;
+
+    const [complete] = call.tracker.feed(fragment);
+    if (complete === undefined) return null;
+
+    this.pending.delete(key);
+    try {
+      const args = JSON.parse(complete);
+      if (!call.name) {
+        logger.debug({ key, arguments: complete }, 'Streamed tool call without a name, ignoring');
+        return null;
+      }
+      return { name: call.name, arguments: typeof args === 'object' && args !== null ? args : {} };
+    } catch {
+      logger.debug({ key, arguments: complete }, 'Streamed tool call arguments are not valid JSON');
+      return null;
+    }
+  }
+
+  /** Tool calls whose arguments never completed, e.g. when the stream was cut off. */
+  getIncomplete(): Array<{ name?: string; arguments: string }> {
+    return [...this.pending.values()].map(({ name, arguments: args }) => ({ name, arguments: args }));
+  }
+}
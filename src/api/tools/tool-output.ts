@@ -0,0 +1,87 @@
+/**
+ * Tool output normalization
+ *
+ * Home Assistant's intent tools return an intent response, e.g.
+ * {"speech": {}, "response_type": "action_done", "data": {"targets": [], "success": [...], "failed": []}}.
+ * The speech is usually empty (HA only fills it in for its own agent), `data` may be
+ * missing and its lists empty. Such outputs get a short `summary`, so Lumo can still
+ * confirm the action instead of guessing what happened.
+ */
+
+/** Shape of a Home Assistant intent response, as far as we read it. */
+interface IntentResponse {
+  speech?: unknown;
+  response_type?: unknown;
+  data?: unknown;
+  [key: string]: unknown;
+}
+
+/**
+ * Add a summary to intent responses without speech.
+ * Other outputs, and intent responses with speech, are returned unchanged.
+ * String outputs stay strings.
+ */
+export function normalizeToolOutput(output: unknown): unknown {
+  const parsed = typeof output === 'string' ? parseJson(output) : output;
+  if (!isIntentResponse(parsed) || speechText(parsed.speech)) return output;
+
+  const summary = summarizeIntentResponse(parsed);
+  if (!summary) return output;
+
+  const normalized = { ...parsed, summary };
+  return typeof output === 'string' ? JSON.stringify(normalized) : normalized;
+}
+
+/** Summarize an intent response, or undefined if there's nothing to confirm. */
+export function summarizeIntentResponse(response: IntentResponse): string | undefined {
+  const data = isPlainObject(response.data) ? response.data : {};
+
+  switch (response.response_type) {
+    case 'error':
+      return typeof data.code === 'string' ? `Failed (${data.code}).` : 'Failed.';
+    case 'query_answer':
+      // The answer is in data; a generic confirmation would only mislead
+      return undefined;
+    default: {
+      const success = targetNames(data.success);
+      const failed = targetNames(data.failed);
+      if (failed.length > 0) {
+        return success.length > 0
+          ? `Done for ${success.join(', ')}. Failed for ${failed.join(', ')}.`
+          : `Failed for ${failed.join(', ')}.`;
+      }
+      return success.length > 0 ? `Done: ${success.join(', ')}.` : 'Done.';
+    }
+  }
+}
+
+/** Names of intent response targets, skipping malformed entries. */
+function targetNames(targets: unknown): string[] {
+  if (!Array.isArray(targets)) return [];
+  return targets
+    .map(t => (isPlainObject(t) && typeof t.name === 'string' ? t.name.trim() : ''))
+    .filter(name => name !== '');
+}
+
+/** Plain speech text of an intent response ({"plain": {"speech": "..."}}), if any. */
+function speechText(speech: unknown): string | undefined {
+  if (!isPlainObject(speech) || !isPlainObject(speech.plain)) return undefined;
+  const text = speech.plain.speech;
+  return typeof text === 'string' && text.trim() !== '' ? text : undefined;
+}
+
+function isIntentResponse(value: unknown): value is IntentResponse {
+  return isPlainObject(value) && ('response_type' in value || 'speech' in value);
+}
+
+function parseJson(text: string): unknown {
+  try {
+    return JSON.parse(text);
+  } catch {
+    return undefined;
+  }
+}
+
+function isPlainObject(value: unknown): value is Record<string, unknown> {
+  return typeof value === 'object' && value !== null && !Array.isArray(value);
+}
@@ -65,9 +65,13 @@ export interface OpenAIChatRequest {
   messages: OpenAIChatMessage[];
   stream?: boolean;
   temperature?: number;
+  top_p?: number;
   max_tokens?: number;
+  stop?: string | string[];
   tools?: OpenAITool[];
   user?: string;
+  metadata?: Record<string, string>;
+  web_search_options?: Record<string, unknown>;
   // Custom extension for conversation persistence
   conversation_id?: string;
 }
@@ -76,6 +80,8 @@ export interface OpenAIChatRequest {
 export interface ChatMessageWithTools {
   role: 'assistant';
   content: string | null;
+  /** Lumo's reasoning, only with server.reasoning: emit */
+  reasoning_content?: string;
   tool_calls?: OpenAIToolCall[];
 }
 
@@ -111,6 +117,7 @@ export interface StreamingToolCallDelta {
 export interface StreamingDelta {
   role?: 'assistant';
   content?: string;
+  reasoning_content?: string;
   tool_calls?: StreamingToolCallDelta[];
 }
 
@@ -143,9 +150,11 @@ export interface OpenAIResponseRequest {
   instructions?: string;
   stream?: boolean;
   temperature?: number;
+  top_p?: number;
   max_output_tokens?: number;
   // Compatibility alias accepted by some OpenAI-style clients.
   max_tokens?: number;
+  stop?: string | string[];
   store?: boolean;
   metadata?: Record<string, string>;
   tools?: any[];
@@ -158,7 +167,7 @@ export interface OpenAIResponseRequest {
 }
 
 // Output item types for OpenAI Response
-export type OutputItem = MessageOutputItem | FunctionCallOutputItem;
+export type OutputItem = MessageOutputItem | ReasoningOutputItem | FunctionCallOutputItem;
 
 export interface MessageOutputItem {
   type: 'message';
@@ -172,6 +181,16 @@ export interface MessageOutputItem {
   }>;
 }
 
+export interface ReasoningOutputItem {
+  type: 'reasoning';
+  id: string;
+  status: 'completed' | 'in_progress';
+  summary: Array<{
+    type: 'summary_text';
+    text: string;
+  }>;
+}
+
 export interface FunctionCallOutputItem {
   type: 'function_call';
   id: string;
@@ -237,6 +256,10 @@ export type ResponseStreamEvent =
   | { type: 'response.content_part.done'; item_id: string; output_index: number; content_index: number; part: any; sequence_number: number }
   | { type: 'response.output_text.delta'; item_id: string; output_index: number; content_index: number; delta: string; sequence_number: number }
   | { type: 'response.output_text.done'; item_id: string; output_index: number; content_index: number; text: string; sequence_number: number }
+  | { type: 'response.reasoning_summary_part.added'; item_id: string; output_index: number; summary_index: number; part: any; sequence_number: number }
+  | { type: 'response.reasoning_summary_part.done'; item_id: string; output_index: number; summary_index: number; part: any; sequence_number: number }
+  | { type: 'response.reasoning_summary_text.delta'; item_id: string; output_index: number; summary_index: number; delta: string; sequence_number: number }
+  | { type: 'response.reasoning_summary_text.done'; item_id: string; output_index: number; summary_index: number; text: string; sequence_number: number }
   | { type: 'response.function_call_arguments.delta'; item_id: string; output_index: number; delta: string; sequence_number: number }
   | { type: 'response.function_call_arguments.done'; item_id: string; output_index: number; arguments: string; name: string; sequence_number: number }
   | { type: 'error'; code: string; message: string; param: string | null; sequence_number: number };
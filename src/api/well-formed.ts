@@ -0,0 +1,61 @@
+/**
+ * Well-formed output for API clients
+ *
+ * JavaScript strings can hold lone UTF-16 surrogates (e.g. half an emoji from a
+ * split chunk). JSON.stringify escapes them as "\ud83c", which is valid JSON but
+ * not valid Unicode: strict clients like Home Assistant then fail with
+ * "Unable to serialize to JSON". Everything the server sends goes through
+ * stringifyWellFormed, which replaces them with U+FFFD.
+ */
+
+import type { Response } from 'express';
+
+export const REPLACEMENT_CHARACTER = '�';
+
+export function isHighSurrogate(code: number): boolean {
+  return code >= 0xd800 && code <= 0xdbff;
+}
+
+export function isLowSurrogate(code: number): boolean {
+  return code >= 0xdc00 && code <= 0xdfff;
+}
+
+/** Replace unpaired surrogates with U+FFFD, leaving valid pairs intact. */
+export function toWellFormed(text: string): string {
+  let result = '';
+  let start = 0;
+  for (let i = 0; i < text.length; i++) {
+    const code = text.charCodeAt(i);
+    if (isHighSurrogate(code) && i + 1 < text.length && isLowSurrogate(text.charCodeAt(i + 1))) {
+      i++;
+      continue;
+    }
+    if (isHighSurrogate(code) || isLowSurrogate(code)) {
+      result += text.slice(start, i) + REPLACEMENT_CHARACTER;
+      start = i + 1;
+    }
+  }
+  return start === 0 ? text : result + text.slice(start);
+}
+
+/**
+ * JSON.stringify with every string value and key made well-formed. A replacer only
+ * sees values, so objects with a key that isn't are copied with their keys replaced
+ * (tool call arguments come from Lumo, keys included).
+ */
+export function stringifyWellFormed(value: unknown): string {
+  return JSON.stringify(value, (_key, v: unknown) => {
+    if (typeof v === 'string') return toWellFormed(v);
+    if (v && typeof v === 'object' && !Array.isArray(v)) {
+      if (Object.keys(v).some(key => toWellFormed(key) !== key)) {
+        return Object.fromEntries(Object.entries(v).map(([key, item]) => [toWellFormed(key), item]));
+      }
+    }
+    return v;
+  });
+}
+
+/** res.json() for completion responses, with well-formed strings. */
+export function sendWellFormedJson(res: Response, body: unknown): void {
+  res.type('application/json').send(stringifyWellFormed(body));
+}
@@ -89,13 +89,25 @@ export function checkConfigFile(): ConfigFileStatus {
  * Exits on fatal errors (directory instead of file, invalid YAML).
  */
 export function loadConfigYaml(): Record<string, unknown> {
+  try {
+    return readConfigYaml();
+  } catch (e) {
+    fatalExit(e instanceof Error ? e.message : String(e));
+  }
+}
+
+/**
+ * Read config.yaml as plain object, like loadConfigYaml, but throw on errors
+ * instead of exiting. Used to reload config while running.
+ */
+export function readConfigYaml(): Record<string, unknown> {
   const status = checkConfigFile();
-  if (status.error) fatalExit(status.error);
+  if (status.error) throw new Error(status.error);
   if (!status.exists || status.isEmpty) return {};
 
   const doc = parseDocument(readFileSync(status.path, 'utf8'));
   if (doc.errors.length > 0) {
-    fatalExit(`Invalid YAML in config.yaml: ${doc.errors[0].message}`);
+    throw new Error(`Invalid YAML in config.yaml: ${doc.errors[0].message}`);
   }
   return (doc.toJS() ?? {}) as Record<string, unknown>;
 }
@@ -1,7 +1,7 @@
 import { z } from 'zod';
 import merge from 'lodash/merge.js';
 import bytes from 'bytes';
-import { fatalExit, loadConfigYaml, loadDefaultsYaml } from './config-file.js';
+import { fatalExit, loadConfigYaml, loadDefaultsYaml, readConfigYaml } from './config-file.js';
 
 // Load defaults from YAML (single source of truth)
 const configDefaults = loadDefaultsYaml();
@@ -10,7 +10,7 @@ const configDefaults = loadDefaultsYaml();
 export type ConfigMode = 'server' | 'cli';
 
 // Shared keys that can be overridden per mode
-const SHARED_KEYS = ['log', 'conversations', 'commands'] as const;
+const SHARED_KEYS = ['log', 'conversations', 'retry', 'commands'] as const;
 
 // ============================================
 // Schemas (validation only, no defaults)
@@ -25,6 +25,9 @@ const logConfigSchema = z.object({
 
 
 const conversationsConfigSchema = z.object({
+  persist: z.boolean(),
+  maxHistoryTokens: z.number().int().min(0),
+  idleTtlMinutes: z.number().min(0),
   deriveIdFromUser: z.boolean(),
   databasePath: z.string(),
   useFallbackStore: z.boolean(),
@@ -32,6 +35,12 @@ const conversationsConfigSchema = z.object({
   projectName: z.string().min(1),
 });
 
+const retryConfigSchema = z.object({
+  maxRetries: z.number().int().min(0),
+  baseDelayMs: z.number().int().min(0),
+  streamFallback: z.boolean(),
+});
+
 // Replace pattern entry schema
 const replacePatternSchema = z.object({
   pattern: z.string(),
@@ -42,6 +51,7 @@ const replacePatternSchema = z.object({
 const customToolsConfigSchema = z.object({
   enabled: z.boolean(),
   prefix: z.string(),
+  dedupWindowSeconds: z.number().min(0),
 });
 
 // Metrics config
@@ -49,6 +59,7 @@ const metricsConfigSchema = z.object({
   enabled: z.boolean(),
   collectDefaultMetrics: z.boolean(),
   prefix: z.string(),
+  listen: z.string().regex(/^$|^(.+:)?\d+$/, 'Expected "port" or "host:port"'),
 });
 
 // Validates size strings using the bytes library (same parser Express uses)
@@ -69,10 +80,12 @@ const cliInstructionsConfigSchema = z.object({
 });
 const serverInstructionsConfigSchema = z.object({
   injectInto: injectIntoSchema,
+  systemPrompt: z.string(),
   template: z.string(),
   forTools: z.string(),
   fallback: z.string(),
   forToolBounce: z.string(),
+  forLanguage: z.string(),
   replacePatterns: z.array(replacePatternSchema),
 });
 
@@ -118,15 +131,40 @@ const serverMergedConfigSchema = z.object({
   auth: authConfigSchema,
   log: logConfigSchema,
   conversations: conversationsConfigSchema,
+  retry: retryConfigSchema,
   commands: z.object({ enabled: z.boolean(), wakeword: z.string() }),
   enableWebSearch: z.boolean(),
+  formatCitations: z.boolean(),
+  reasoning: z.enum(['discard', 'log', 'emit']),
+  reasoningMarkers: z.array(z.tuple([z.string().min(1), z.string().min(1)])),
+  language: z.string(),
   customTools: customToolsConfigSchema,
   instructions: serverInstructionsConfigSchema,
   metrics: metricsConfigSchema,
   bodyLimit: byteSizeSchema,
+  sseKeepAliveSeconds: z.number().min(0),
+  streamBufferSize: byteSizeSchema,
+  flushIntervalMs: z.number().int().min(0),
+  concurrency: z.number().int().positive(),
+  healthzPing: z.boolean(),
+  streamTimeout: z.object({
+    idleSeconds: z.number().min(0),
+    note: z.string(),
+  }),
   port: z.number().int().positive(),
   apiKey: z.string().min(1, 'server.apiKey is required'),
   apiModelName: z.string().min(1),
+  models: z.array(z.string().min(1)),
+  sampling: z.object({
+    temperature: z.number().min(0).max(2).nullable(),
+    topP: z.number().min(0).max(1).nullable(),
+  }),
+  maxTokens: z.object({
+    default: z.number().int().positive().nullable(),
+    cap: z.number().int().positive().nullable(),
+    note: z.string(),
+  }),
+  stop: z.array(z.string().min(1)).max(4),
 });
 
 // CLI merged config schema
@@ -134,6 +172,7 @@ const cliMergedConfigSchema = z.object({
   auth: authConfigSchema,
   log: logConfigSchema,
   conversations: conversationsConfigSchema,
+  retry: retryConfigSchema,
   commands: z.object({ enabled: z.boolean(), wakeword: z.string() }),
   enableWebSearch: z.boolean(),
   localActions: localActionsConfigSchema,
@@ -223,6 +262,7 @@ function getConfig(): MergedConfig {
 
 export const getLogConfig = () => getConfig().log;
 export const getConversationsConfig = () => getConfig().conversations;
+export const getRetryConfig = () => getConfig().retry;
 export const getCommandsConfig = () => getConfig().commands;
 export const getEnableWebSearch = () => getConfig().enableWebSearch;
 
@@ -242,6 +282,33 @@ export function getServerInstructionsConfig() {
   return cfg.instructions;
 }
 
+/**
+ * Re-read config.yaml and apply its server.instructions section.
+ * Other settings still need a restart. Throws on invalid config, keeping the current instructions.
+ */
+export function reloadServerInstructionsConfig(): void {
+  const cfg = getServerConfig();
+  const userConfig = readConfigYaml();
+  const userInstructions = (userConfig.server as Record<string, unknown> | undefined)?.instructions;
+  const defaultInstructions = (configDefaults.server as Record<string, unknown>).instructions;
+  cfg.instructions = serverInstructionsConfigSchema.parse(merge({}, defaultInstructions, userInstructions));
+  userConfigCache = userConfig;
+}
+
+/**
+ * Override server.flushIntervalMs, e.g. from `tamer server --flush-interval`.
+ * Exits like invalid config.yaml values on a negative or fractional value.
+ */
+export function setFlushIntervalMs(ms: number): void {
+  const cfg = getServerConfig();
+  try {
+    cfg.flushIntervalMs = serverMergedConfigSchema.shape.flushIntervalMs.parse(ms);
+  } catch (error) {
+    catchZodErrors(error, 'server.flushIntervalMs (--flush-interval)');
+    throw error;
+  }
+}
+
 export function getMetricsConfig() {
   const cfg = getServerConfig();
   return cfg.metrics;
@@ -2,6 +2,14 @@ import pino from 'pino';
 import type { LogConfig } from './config.js';
 import { resolveProjectPath } from './paths.js';
 import { installConsoleShim } from '../shims/console.js';
+import { redact } from './redact.js';
+
+export interface LoggerOptions {
+  /** Redirect console methods to the logger (default: true) */
+  consoleShim?: boolean;
+  /** Redact secrets and personal data from log records (default: true, see redact.ts) */
+  redact?: boolean;
+}
 
 // Determine transport based on config
 function getTransport(config: LogConfig): pino.TransportSingleOptions | pino.TransportMultiOptions {
@@ -43,10 +51,19 @@ function getTransport(config: LogConfig): pino.TransportSingleOptions | pino.Tra
 }
 
 // Create logger instance with given config
-export function createLogger(config: LogConfig): pino.Logger {
+export function createLogger(config: LogConfig, options: LoggerOptions = {}): pino.Logger {
+  const redactOptions = { messageContent: config.messageContent };
   return pino({
     level: config.level,
     transport: getTransport(config),
+    // Redact every argument before pino formats it, so it applies to all levels,
+    // child loggers and the console shim
+    hooks: options.redact === false ? undefined : {
+      logMethod(inputArgs, method) {
+        const args = inputArgs.map(arg => redact(arg, redactOptions)) as Parameters<pino.LogFn>;
+        return method.apply(this, args);
+      },
+    },
     // The codebase uses `error` (not `err`) for error objects in log calls.
     // Pino only auto-serializes the `err` key, so we register the same
     // serializer for `error` to get proper message/stack extraction.
@@ -60,9 +77,14 @@ let _logger: pino.Logger;
 
 // Initialize the global logger with mode-specific config
 // Must be called early in entry point, before other modules use logger
-export function initLogger(config: LogConfig): void {
-  _logger = createLogger(config);
-  installConsoleShim(_logger);
+export function initLogger(config: LogConfig, options: LoggerOptions = {}): void {
+  _logger = createLogger(config, options);
+  if (options.consoleShim !== false) {
+    installConsoleShim(_logger);
+  }
+  if (options.redact === false) {
+    _logger.warn('Log redaction disabled (--log-unredacted), logs may contain tokens and personal data');
+  }
 }
 
 // Get the global logger instance
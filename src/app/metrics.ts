@@ -6,6 +6,8 @@ export interface MetricsConfig {
   enabled: boolean;
   collectDefaultMetrics: boolean;
   prefix: string;
+  /** Separate listen address for /metrics ("" serves it on the API port) */
+  listen?: string;
 }
 
 export class MetricsService {
@@ -14,6 +16,11 @@ export class MetricsService {
   // HTTP metrics
   readonly httpRequestsTotal: Counter;
   readonly httpRequestDuration: Histogram;
+  readonly activeRequests: Gauge;
+
+  // Upstream (Lumo chat) request metrics
+  readonly upstreamRequestsTotal: Counter;
+  readonly upstreamRequestDuration: Histogram;
 
   // Message metrics
   readonly messagesTotal: Counter;
@@ -24,6 +31,9 @@ export class MetricsService {
   // Tool call metrics
   readonly toolCallsTotal: Counter;
 
+  // Token usage metrics
+  readonly tokensTotal: Counter;
+
   // Error/warning metrics
   readonly errorsTotal: Counter;
   readonly warningsTotal: Counter;
@@ -40,6 +50,7 @@ export class MetricsService {
 
   // Auth metrics
   readonly authFailuresTotal: Counter;
+  readonly tokenRefreshesTotal: Counter;
 
   // Proton API metrics
   readonly protonApiRequestsTotal: Counter;
@@ -69,6 +80,28 @@ export class MetricsService {
       registers: [this.registry],
     });
 
+    this.activeRequests = new Gauge({
+      name: `${prefix}active_requests`,
+      help: 'HTTP requests currently being served (including open streams)',
+      registers: [this.registry],
+    });
+
+    // Upstream (Lumo chat) request metrics
+    this.upstreamRequestsTotal = new Counter({
+      name: `${prefix}upstream_requests_total`,
+      help: 'Lumo chat requests by outcome (success, truncated, cancelled, error)',
+      labelNames: ['outcome'],
+      registers: [this.registry],
+    });
+
+    this.upstreamRequestDuration = new Histogram({
+      name: `${prefix}upstream_request_duration_seconds`,
+      help: 'Lumo chat request duration in seconds, from sending to the end of the stream',
+      labelNames: ['outcome'],
+      buckets: [0.5, 1, 2, 5, 10, 20, 30, 60, 120],
+      registers: [this.registry],
+    });
+
     // Message metrics
     this.messagesTotal = new Counter({
       name: `${prefix}messages_total`,
@@ -92,6 +125,14 @@ export class MetricsService {
       registers: [this.registry],
     });
 
+    // Token usage metrics
+    this.tokensTotal = new Counter({
+      name: `${prefix}tokens_total`,
+      help: 'Total Lumo tokens (estimated from content length when Lumo reports none)',
+      labelNames: ['type', 'estimated'],
+      registers: [this.registry],
+    });
+
     // Error/warning metrics
     this.errorsTotal = new Counter({
       name: `${prefix}errors_total`,
@@ -143,6 +184,13 @@ export class MetricsService {
       registers: [this.registry],
     });
 
+    this.tokenRefreshesTotal = new Counter({
+      name: `${prefix}token_refreshes_total`,
+      help: 'Proton token refreshes by status',
+      labelNames: ['status'],
+      registers: [this.registry],
+    });
+
     // Proton API metrics
     this.protonApiRequestsTotal = new Counter({
       name: `${prefix}proton_api_requests_total`,
@@ -0,0 +1,90 @@
+/**
+ * Log redaction
+ *
+ * Strips secrets and personal data from everything passed to the logger, at all
+ * levels, so logs are safe to paste into bug reports:
+ * - values of secret keys (tokens, UIDs, passwords, cookies) are replaced entirely
+ * - values of content keys (tool outputs, arguments, raw payloads) are replaced,
+ *   unless log.messageContent is enabled
+ * - tokens, Proton auth cookies and email addresses are masked inside any string
+ *
+ * Disabled with `--log-unredacted`, for local debugging only.
+ */
+
+export const REDACTED = '[redacted]';
+
+// Matched against keys lowercased with `_` and `-` removed
+const SECRET_KEY = /token|password|passphrase|secret|cookie|authorization|apikey|mnemonic|clientkey|uid$/;
+const CONTENT_KEYS = new Set(['output', 'content', 'arguments', 'raw', 'body', 'preview', 'toolresult', 'text']);
+
+const STRING_PATTERNS: Array<[RegExp, string]> = [
+  // Authorization headers
+  [/\b(Bearer|Basic)\s+[\w~+/.=-]+/gi, `$1 ${REDACTED}`],
+  // Proton auth cookies, named after the session UID: AUTH-<uid>=<token>, REFRESH-<uid>=<json>
+  [/\b(AUTH|REFRESH)-[\w-]+(=[^;\s]*)?/g, `$1-${REDACTED}`],
+  // Access/refresh tokens and UIDs (32+ char alphanumeric strings)
+  [/\b[a-zA-Z0-9]{32,}\b/g, REDACTED],
+  [/[\w.+-]+@[\w-]+(\.[\w-]+)+/g, '[email]'],
+];
+
+const MAX_DEPTH = 10;
+
+export interface RedactOptions {
+  /** Keep content keys (log.messageContent) */
+  messageContent?: boolean;
+}
+
+export function redactString(text: string): string {
+  return STRING_PATTERNS.reduce((result, [pattern, replacement]) => result.replace(pattern, replacement), text);
+}
+
+/** Return a redacted copy of a log argument. Errors stay Errors so pino serializes them as usual. */
+export function redact(value: unknown, options: RedactOptions = {}): unknown {
+  return redactValue(value, options, new WeakMap(), 0);
+}
+
+function redactValue(value: unknown, options: RedactOptions, seen: WeakMap<object, unknown>, depth: number): unknown {
+  if (typeof value === 'string') return redactString(value);
+  if (typeof value !== 'object' || value === null) return value;
+  if (seen.has(value)) return seen.get(value);
+  if (depth >= MAX_DEPTH) return '[truncated]';
+
+  if (Array.isArray(value)) {
+    const copy: unknown[] = [];
+    seen.set(value, copy);
+    for (const item of value) copy.push(redactValue(item, options, seen, depth + 1));
+    return copy;
+  }
+
+  // Only walk plain objects and errors, leave Buffers, Dates etc. as they are
+  const proto = Object.getPrototypeOf(value);
+  const isError = value instanceof Error;
+  if (!isError && proto !== Object.prototype && proto !== null) return value;
+
+  const copy: Record<string, unknown> = isError ? Object.create(proto) : {};
+  seen.set(value, copy);
+  // getOwnPropertyNames includes an error's non-enumerable message and stack
+  const keys = isError ? Object.getOwnPropertyNames(value) : Object.keys(value);
+  for (const key of keys) {
+    copy[key] = redactEntry(key, (value as Record<string, unknown>)[key], options, seen, depth);
+  }
+  return copy;
+}
+
+function redactEntry(
+  key: string,
+  value: unknown,
+  options: RedactOptions,
+  seen: WeakMap<object, unknown>,
+  depth: number,
+): unknown {
+  // Numbers and flags are never secret (e.g. promptTokens), only their strings and objects
+  if (typeof value !== 'string' && (typeof value !== 'object' || value === null)) return value;
+  if (value === '') return value;
+  const normalized = key.toLowerCase().replace(/[_-]/g, '');
+  if (SECRET_KEY.test(normalized)) return REDACTED;
+  if (!options.messageContent && CONTENT_KEYS.has(normalized)) {
+    return typeof value === 'string' ? `[redacted ${value.length} chars]` : REDACTED;
+  }
+  return redactValue(value, options, seen, depth + 1);
+}
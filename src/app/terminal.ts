@@ -27,7 +27,8 @@ Commands:
   server                     Start OpenAI-compatible API server
 
 Options:
-  -h, --help    Show help
+  -h, --help         Show help
+  --log-unredacted   Don't redact tokens and personal data from logs (local debugging only)
 
 `);
 }
@@ -58,6 +59,10 @@ Usage:
   tamer server               Start the API server
   tamer server --help        Show this help
 
+Options:
+  --flush-interval <ms>      Collect streamed deltas for up to <ms> before sending them
+                             (overrides server.flushIntervalMs, 0 sends every delta at once)
+
 The server listens on the port configured in config.yaml (default: 3003).
 `);
 }
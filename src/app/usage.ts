@@ -0,0 +1,87 @@
+/**
+ * Token usage tracking
+ *
+ * Keeps in-memory token totals per conversation and per day (server local time),
+ * reported by the /v1/usage endpoint. Totals are lost on restart.
+ */
+
+import type { TokenUsage } from '../lumo-client/index.js';
+
+/** Days of daily totals to keep */
+const MAX_DAYS = 31;
+/** Conversations to keep totals for, least recently active are dropped first */
+const MAX_CONVERSATIONS = 1000;
+
+export interface UsageTotals {
+  requests: number;
+  promptTokens: number;
+  completionTokens: number;
+  totalTokens: number;
+  /** True when any of the counted requests was estimated */
+  estimated: boolean;
+}
+
+export interface UsageSummary {
+  today: UsageTotals & { date: string };
+  days: Array<UsageTotals & { date: string }>;
+  conversations: Array<UsageTotals & { conversationId: string }>;
+}
+
+export class UsageTracker {
+  private days = new Map<string, UsageTotals>();
+  private conversations = new Map<string, UsageTotals>();
+
+  record(usage: TokenUsage, conversationId?: string, now = new Date()): void {
+    const date = formatDate(now);
+    this.days.set(date, addToTotals(this.days.get(date), usage));
+    if (this.days.size > MAX_DAYS) {
+      this.days.delete(this.days.keys().next().value!);
+    }
+
+    if (!conversationId) return;
+    const totals = addToTotals(this.conversations.get(conversationId), usage);
+    // Re-insert to keep the map ordered by last activity
+    this.conversations.delete(conversationId);
+    this.conversations.set(conversationId, totals);
+    if (this.conversations.size > MAX_CONVERSATIONS) {
+      this.conversations.delete(this.conversations.keys().next().value!);
+    }
+  }
+
+  getSummary(now = new Date()): UsageSummary {
+    const date = formatDate(now);
+    return {
+      today: { date, ...(this.days.get(date) ?? emptyTotals()) },
+      days: [...this.days].map(([date, totals]) => ({ date, ...totals })),
+      conversations: [...this.conversations].map(([conversationId, totals]) => ({ conversationId, ...totals })),
+    };
+  }
+}
+
+function emptyTotals(): UsageTotals {
+  return { requests: 0, promptTokens: 0, completionTokens: 0, totalTokens: 0, estimated: false };
+}
+
+function addToTotals(totals: UsageTotals = emptyTotals(), usage: TokenUsage): UsageTotals {
+  return {
+    requests: totals.requests + 1,
+    promptTokens: totals.promptTokens + usage.promptTokens,
+    completionTokens: totals.completionTokens + usage.completionTokens,
+    totalTokens: totals.totalTokens + usage.promptTokens + usage.completionTokens,
+    estimated: totals.estimated || usage.estimated,
+  };
+}
+
+/** YYYY-MM-DD in server local time */
+function formatDate(date: Date): string {
+  const pad = (n: number) => String(n).padStart(2, '0');
+  return `${date.getFullYear()}-${pad(date.getMonth() + 1)}-${pad(date.getDate())}`;
+}
+
+// Singleton instance
+let usageTracker: UsageTracker | null = null;
+
+export function getUsageTracker(): UsageTracker {
+  usageTracker ??= new UsageTracker();
+  return usageTracker;
+}
@@ -98,7 +98,9 @@ export function createProtonApi(options: ApiFactoryOptions): ProtonApi {
 
         let response = await makeRequest(uid, accessToken);
 
-        // Handle 401 with retry if onAuthError callback is provided
+        // Handle 401 with retry if onAuthError callback is provided.
+        // Only retried once, a second 401 means the refreshed session is unusable too.
+        let refreshFailed = false;
         if (response.status === 401 && onAuthError) {
             logger.info({ url }, 'Got 401, attempting token refresh...');
 
@@ -113,12 +115,15 @@ export function createProtonApi(options: ApiFactoryOptions): ProtonApi {
                     // Retry the request with new credentials
                     logger.info({ url }, 'Retrying request with refreshed tokens');
                     response = await makeRequest(uid, accessToken);
+                    refreshFailed = response.status === 401;
                 } else {
                     logger.warn('Token refresh returned null - not retrying');
+                    refreshFailed = true;
                 }
             } catch (refreshError) {
                 logger.error({ error: refreshError }, 'Token refresh failed');
                 // Fall through to throw the original 401 error
+                refreshFailed = true;
             }
         }
 
@@ -137,8 +142,9 @@ export function createProtonApi(options: ApiFactoryOptions): ProtonApi {
             } catch { /* not JSON */ }
 
             const error = new Error(
-                protonError
-                    || `API error: ${response.status} ${response.statusText}`
+                refreshFailed
+                    ? 'Proton session expired and could not be refreshed. Run `tamer auth` to log in again.'
+                    : protonError || `API error: ${response.status} ${response.statusText}`
             );
             (error as any).status = response.status;
             (error as any).Code = protonCode;
@@ -16,7 +16,7 @@ export type { ApiFactoryOptions, ProtonApiWithRefresh } from './api-factory.js';
 
 // Re-export AuthManager
 export { AuthManager } from './manager.js';
-export type { AuthManagerOptions } from './manager.js';
+export type { AuthManagerOptions, Readiness } from './manager.js';
 
 // Re-export token refresh utilities
 export { canRefreshWithToken, refreshWithRefreshToken } from './token-refresh.js';
@@ -23,6 +23,9 @@ export async function runLoginAuthentication(): Promise<void> {
 
     // Run the Go binary (interactive prompts for credentials)
     const result = await runProtonAuth(binaryPath);
+    if (result.nearExpiry) {
+        logger.warn({ expiresAt: result.expiresAt }, 'Proton issued short-lived tokens, they will need a refresh soon');
+    }
 
     const vaultPath = resolveProjectPath(authConfig.vault.path);
     const keyConfig: VaultKeyConfig = {
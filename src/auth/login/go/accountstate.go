@@ -0,0 +1,30 @@
+package main
+
+// Delinquent values of the Proton user. Below delinquentBlocked the account
+// has an unpaid or overdue invoice but still works.
+const (
+	delinquentOverdue = 2
+	delinquentBlocked = 3
+)
+
+// passwordResetResult fails the login if Proton flagged the password as
+// temporary. Checked right after SRP, before spending a 2FA code.
+func passwordResetResult(observer *responseObserver) (AuthResult, bool) {
+	if temporaryPassword, _ := observer.accountState(); temporaryPassword {
+		return errorResult(ErrPasswordResetRequired, "Proton requires a password reset for this account: sign in at https://account.proton.me, set a new password, then log in again"), true
+	}
+	return AuthResult{}, false
+}
+
+// delinquentResult fails the login if the account is blocked for billing,
+// before any key derivation. Overdue accounts only get a warning.
+func delinquentResult(observer *responseObserver) (AuthResult, bool) {
+	_, delinquent := observer.accountState()
+	switch {
+	case delinquent >= delinquentBlocked:
+		return errorResult(ErrAccountDelinquent, "Account is blocked for unpaid invoices (delinquent state %d): pay them at https://account.proton.me/dashboard, then log in again", delinquent), true
+	case delinquent >= delinquentOverdue:
+		logger.Warn("Account has an overdue invoice and may be blocked soon", "delinquent", delinquent)
+	}
+	return AuthResult{}, false
+}
@@ -0,0 +1,34 @@
+package main
+
+import "context"
+
+// AuthProvider is the credential backend behind logins, refreshes and the
+// token server. protonProvider talks to Proton, fakeProvider never leaves the
+// machine (--dry-run).
+type AuthProvider interface {
+	// Login authenticates with the credentials from the options
+	Login(ctx context.Context) AuthResult
+	// Refresh exchanges the refresh token of stored for new tokens
+	Refresh(ctx context.Context, stored AuthResult) AuthResult
+}
+
+// newAuthProvider returns the provider for the options
+func newAuthProvider(opts options) AuthProvider {
+	if opts.dryRun {
+		return &fakeProvider{opts: opts}
+	}
+	return protonProvider{opts: opts}
+}
+
+// protonProvider authenticates with Proton's SRP flow via go-proton-api
+type protonProvider struct {
+	opts options
+}
+
+func (p protonProvider) Login(ctx context.Context) AuthResult {
+	return authenticate(ctx, p.opts)
+}
+
+func (p protonProvider) Refresh(ctx context.Context, stored AuthResult) AuthResult {
+	return refreshTokens(ctx, stored, p.opts)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dryRunOptions returns valid --dry-run options, with credentials in the
+// environment cleared so only the options count
+func dryRunOptions(t *testing.T) options {
+	t.Helper()
+	for _, name := range []string{envUsername, envPassword, envTOTP, envTOTPSecret, envKeyPassword, envUID, envRefreshToken} {
+		t.Setenv(name, "")
+	}
+	return options{dryRun: true, noPrompt: true, username: "user@proton.me", password: "hunter2"}
+}
+
+func TestNewAuthProvider(t *testing.T) {
+	if _, ok := newAuthProvider(options{dryRun: true}).(*fakeProvider); !ok {
+		t.Error("--dry-run should use fakeProvider")
+	}
+	if _, ok := newAuthProvider(options{}).(protonProvider); !ok {
+		t.Error("without --dry-run, protonProvider should be used")
+	}
+}
+
+func TestFakeProviderLogin(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(*options)
+		code     ErrorCode
+		exitCode int
+	}{
+		{"valid", func(*options) {}, 0, 0},
+		{"valid TOTP", func(o *options) { o.totp = "123456" }, 0, 0},
+		{"username not an email", func(o *options) { o.username = "user" }, ErrInvalidCredentials, exitBadInput},
+		{"no password", func(o *options) { o.password = "" }, ErrPasswordRequired, exitBadInput},
+		{"TOTP not digits", func(o *options) { o.totp = "12ab56" }, ErrInvalidCredentials, exitBadInput},
+		{"TOTP secret not base32", func(o *options) { o.totpSecret = "not base32!" }, ErrInvalidCredentials, exitBadInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := dryRunOptions(t)
+			tt.modify(&opts)
+
+			result := newAuthProvider(opts).Login(context.Background())
+			if result.ErrorCode != tt.code {
+				t.Fatalf("ErrorCode = %d (%s), want %d", result.ErrorCode, result.Error, tt.code)
+			}
+			if tt.code == 0 {
+				if !result.DryRun {
+					t.Error("DryRun not set")
+				}
+				return
+			}
+			if result.ErrorType != tt.code.String() {
+				t.Errorf("ErrorType = %q, want %q", result.ErrorType, tt.code.String())
+			}
+			if got := result.ErrorCode.exitCode(); got != tt.exitCode {
+				t.Errorf("exit code = %d, want %d", got, tt.exitCode)
+			}
+		})
+	}
+}
+
+func TestFakeProviderLoginOutputHasNoCredentials(t *testing.T) {
+	opts := dryRunOptions(t)
+	result := newAuthProvider(opts).Login(context.Background())
+
+	for _, format := range []string{formatJSON, formatDotenv, formatExport} {
+		out := string(formatResult(result, format))
+		if strings.Contains(out, opts.password) || strings.Contains(out, opts.username) {
+			t.Errorf("%s output contains credentials: %s", format, out)
+		}
+	}
+}
+
+func TestFakeProviderRefresh(t *testing.T) {
+	provider := newAuthProvider(dryRunOptions(t))
+	stored := AuthResult{KeyPassword: "kept"}
+
+	first := provider.Refresh(context.Background(), stored)
+	second := provider.Refresh(context.Background(), first)
+
+	if first.AccessToken == second.AccessToken || first.RefreshToken == second.RefreshToken {
+		t.Errorf("refreshes should issue new tokens, got %q twice", first.AccessToken)
+	}
+	if second.AccessToken != "dry-run-access-2" {
+		t.Errorf("AccessToken = %q, want dry-run-access-2", second.AccessToken)
+	}
+	if second.KeyPassword != "kept" || !second.DryRun {
+		t.Errorf("KeyPassword = %q, DryRun = %v, want kept and true", second.KeyPassword, second.DryRun)
+	}
+	expiresAt, err := time.Parse(time.RFC3339, second.ExpiresAt)
+	if err != nil {
+		t.Fatalf("ExpiresAt %q: %v", second.ExpiresAt, err)
+	}
+	if ttl := time.Until(expiresAt); ttl <= fakeTokenTTL-time.Minute || ttl > fakeTokenTTL {
+		t.Errorf("token TTL = %v, want about %v", ttl, fakeTokenTTL)
+	}
+}
+
+func TestAuthenticateAccountDryRun(t *testing.T) {
+	opts := dryRunOptions(t)
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(passwordFile, []byte("hunter2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// A real login would fail here: the account has no host to reach
+	opts.host = "http://127.0.0.1:1"
+	result := authenticateAccount(context.Background(), opts, batchAccount{Username: "batch@proton.me", PasswordFile: passwordFile})
+	if result.Error != "" || !result.DryRun {
+		t.Errorf("got error %q, DryRun = %v; want a dry run without error", result.Error, result.DryRun)
+	}
+	if result.Username != "batch@proton.me" {
+		t.Errorf("Username = %q", result.Username)
+	}
+}
+
+// writeStoredTokens writes an expired session to a temp token file. The options
+// point at an unreachable host, so a real refresh of it would fail.
+func writeStoredTokens(t *testing.T, opts *options) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	stored := `{"uid": "stored-uid", "refreshToken": "stored-refresh", "expiresAt": "2000-01-01T00:00:00Z"}`
+	if err := os.WriteFile(path, []byte(stored), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	opts.host = "http://127.0.0.1:1"
+	opts.retries = 0
+	return path
+}
+
+func TestRefreshDryRun(t *testing.T) {
+	opts := dryRunOptions(t)
+	path := writeStoredTokens(t, &opts)
+
+	result := refresh(context.Background(), path, opts)
+	if result.Error != "" || !result.DryRun {
+		t.Fatalf("got error %q, DryRun = %v; want a dry run without error", result.Error, result.DryRun)
+	}
+	if !strings.HasPrefix(result.RefreshToken, "dry-run-refresh-") {
+		t.Errorf("RefreshToken = %q, want one from fakeProvider", result.RefreshToken)
+	}
+}
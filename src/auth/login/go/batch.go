@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// batchAccount is one entry of the --accounts-file list (YAML or JSON)
+type batchAccount struct {
+	Username     string `yaml:"username"`
+	PasswordFile string `yaml:"passwordFile"`
+	TOTP         string `yaml:"totp"`
+	TOTPSecret   string `yaml:"totpSecret"`
+}
+
+// accountResult is the AuthResult of one batch account, tagged with its username
+type accountResult struct {
+	Username string `json:"username"`
+	AuthResult
+}
+
+// readAccounts loads and checks the --accounts-file list
+func readAccounts(path string) ([]batchAccount, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// YAML is a superset of JSON, so this handles both
+	var accounts []batchAccount
+	if err := yaml.Unmarshal(data, &accounts); err != nil {
+		return nil, fmt.Errorf("invalid accounts file: %w", err)
+	}
+	if len(accounts) == 0 {
+		return nil, errors.New("accounts file has no entries")
+	}
+	for i, account := range accounts {
+		if account.Username == "" || account.PasswordFile == "" {
+			return nil, fmt.Errorf("entry %d: username and passwordFile are required", i+1)
+		}
+	}
+	return accounts, nil
+}
+
+// authenticateBatch logs in every account with a pool of concurrency workers.
+// A failed account doesn't abort the others, each result carries its own error.
+// Results keep the order of accounts. Rate limiting seen by one worker pauses
+// all of them, see rateLimitGate.
+func authenticateBatch(ctx context.Context, opts options, accounts []batchAccount, concurrency int) []accountResult {
+	results := make([]accountResult, len(accounts))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for range min(concurrency, len(accounts)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				// Each worker writes only its own index, so results needs no lock
+				results[i] = authenticateAccount(ctx, opts, accounts[i])
+			}
+		}()
+	}
+	for i := range accounts {
+		jobs <- i
+	}
+	close(jobs)
+
+	wg.Wait()
+	return results
+}
+
+// authenticateAccount logs in one batch account with its own context,
+// bounded by the batch's --timeout
+func authenticateAccount(ctx context.Context, opts options, account batchAccount) accountResult {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	accountOpts := opts
+	accountOpts.username = account.Username
+	accountOpts.passwordFile = account.PasswordFile
+	accountOpts.totp = account.TOTP
+	accountOpts.totpSecret = account.TOTPSecret
+	accountOpts.noPrompt = true
+
+	result := runSafely(func() AuthResult { return timed(ctx, accountOpts, newAuthProvider(accountOpts).Login) })
+	logResult(result, "username", account.Username)
+	return accountResult{Username: account.Username, AuthResult: result}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// clockSkewWarning is the skew at which TOTP codes typed from an authenticator
+// on this machine start landing in the wrong 30s window
+const clockSkewWarning = totpPeriod
+
+// recordServerDate records the offset of Proton's clock from the Date header.
+// Warns once if it exceeds clockSkewWarning.
+func (o *responseObserver) recordServerDate(header http.Header, now time.Time) {
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		return
+	}
+	skew := date.Sub(now).Round(time.Second)
+
+	o.mu.Lock()
+	o.clockSkew, o.clockSkewSeen = skew, true
+	warn := !o.clockSkewWarned && skew.Abs() >= clockSkewWarning
+	o.clockSkewWarned = o.clockSkewWarned || warn
+	o.mu.Unlock()
+
+	if warn {
+		logger.Warn("Local clock differs from Proton's, TOTP codes and token expiry may be off: sync the clock (e.g. timedatectl set-ntp true)", "skew", skew)
+	}
+}
+
+// serverNow returns the current time on Proton's clock,
+// or the local time if no response carried a Date header yet
+func (o *responseObserver) serverNow() time.Time {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return time.Now().Add(o.clockSkew)
+}
+
+// withClockSkew reports the recorded skew on result, in seconds.
+// Positive means the local clock is behind.
+func withClockSkew(result AuthResult, observer *responseObserver) AuthResult {
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	if observer.clockSkewSeen {
+		result.ClockSkew = int64(observer.clockSkew / time.Second)
+	}
+	return result
+}
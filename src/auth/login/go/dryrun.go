@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+// emailPattern is a loose check that a username looks like an email address
+var emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+// totpPattern matches a 6-8 digit TOTP code
+var totpPattern = regexp.MustCompile(`^\d{6,8}$`)
+
+// dryRun reads the credentials the same way a login would and checks their
+// format without contacting Proton. The output never contains credentials.
+func dryRun(opts options) AuthResult {
+	reader := bufio.NewReader(os.Stdin)
+	interactive := term.IsTerminal(int(syscall.Stdin)) && !opts.noPrompt
+
+	username, password, failure := readCredentials(opts, interactive, reader)
+	if failure != nil {
+		return *failure
+	}
+	defer wipe(password)
+
+	if !emailPattern.MatchString(username) {
+		return errorResult(ErrInvalidCredentials, "Username does not look like an email address")
+	}
+	if len(password) == 0 {
+		return errorResult(ErrInvalidCredentials, "Password is empty")
+	}
+	if totp, ok := providedTOTP(opts, interactive); ok && !totpPattern.MatchString(strings.TrimSpace(totp)) {
+		return errorResult(ErrInvalidCredentials, "TOTP code must be 6-8 digits")
+	}
+	if secret, ok := totpSecret(opts, interactive); ok {
+		if _, err := decodeTOTPSecret(secret); err != nil {
+			return errorResult(ErrInvalidCredentials, "%v", err)
+		}
+	}
+	return AuthResult{DryRun: true}
+}
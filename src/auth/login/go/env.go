@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/henrybear327/go-proton-api"
+)
+
+// envHosts maps the --env names to their Proton API base URLs
+var envHosts = map[string]string{
+	"production": proton.DefaultHostURL,
+	"staging":    "https://mail.proton.black/api",
+}
+
+// resolveEnv sets opts.host from --env, unless --host is given
+func resolveEnv(opts *options) error {
+	host, ok := envHosts[opts.env]
+	if !ok {
+		names := make([]string, 0, len(envHosts))
+		for name := range envHosts {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return fmt.Errorf("invalid --env %q: expected %s", opts.env, strings.Join(names, " or "))
+	}
+	if opts.host == "" && opts.env != "production" {
+		opts.host = host
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorCode identifies why a run failed. The numeric values are part of the
+// JSON output contract and never change meaning.
+type ErrorCode int
+
+const (
+	// ErrReadInput means a credential, file or JSON input couldn't be read
+	ErrReadInput ErrorCode = 1000
+	// ErrAuthFailed means SRP login was rejected (usually wrong username or password)
+	ErrAuthFailed ErrorCode = 1001
+	// ErrTOTPRequired means a TOTP code was needed but not given or unreadable
+	ErrTOTPRequired ErrorCode = 1002
+	// ErrTwoFactorFailed means the 2FA code or assertion was rejected
+	ErrTwoFactorFailed ErrorCode = 1003
+	// ErrGetUser means fetching the user and its keys failed
+	ErrGetUser ErrorCode = 1006
+	// ErrKeySalts means fetching key salts or deriving the key password failed
+	ErrKeySalts ErrorCode = 1007
+	// ErrPasswordRequired means no password was given in non-interactive mode
+	ErrPasswordRequired ErrorCode = 1008
+	// ErrReadStoredTokens means a stored token file couldn't be read or parsed
+	ErrReadStoredTokens ErrorCode = 1009
+	// ErrRefreshTokenInvalid means the refresh token is expired or revoked, a full login is needed
+	ErrRefreshTokenInvalid ErrorCode = 1010
+	// ErrRefreshFailed means refreshing failed for any other reason, retrying may help
+	ErrRefreshFailed ErrorCode = 1011
+	// ErrInvalidOptions means the command line flags are invalid
+	ErrInvalidOptions ErrorCode = 1012
+	// ErrFIDO2 means a security key was needed but the assertion was missing or invalid
+	ErrFIDO2 ErrorCode = 1013
+	// ErrKeyUnlock means the key password doesn't unlock the primary key
+	ErrKeyUnlock ErrorCode = 1014
+	// ErrTimeout means the run exceeded --timeout
+	ErrTimeout ErrorCode = 1015
+	// ErrInternal means the helper hit an unexpected internal error
+	ErrInternal ErrorCode = 1016
+	// ErrPinMismatch means the server certificate didn't match --pin-sha256
+	ErrPinMismatch ErrorCode = 1017
+	// ErrHumanVerification means Proton requires a CAPTCHA or other verification
+	ErrHumanVerification ErrorCode = 1018
+	// ErrForkFailed means login succeeded but forking a child session failed
+	ErrForkFailed ErrorCode = 1019
+	// ErrUsernameRequired means no username was given in non-interactive mode
+	ErrUsernameRequired ErrorCode = 1020
+	// ErrRateLimited means Proton kept rate limiting (429) after --retries, or asked
+	// to wait longer than the remaining --timeout
+	ErrRateLimited ErrorCode = 1021
+	// ErrRevokeFailed means Proton didn't confirm the session was logged out
+	ErrRevokeFailed ErrorCode = 1022
+	// ErrInterrupted means the run was cancelled by SIGINT or SIGTERM
+	ErrInterrupted ErrorCode = 1023
+	// ErrInvalidCredentials means --dry-run found a malformed username, password or TOTP
+	ErrInvalidCredentials ErrorCode = 1024
+	// ErrServeFailed means the --serve endpoint couldn't listen or stopped unexpectedly
+	ErrServeFailed ErrorCode = 1025
+	// ErrRecoveryCodeRejected means the --recovery-code was not accepted as a second factor
+	ErrRecoveryCodeRejected ErrorCode = 1026
+	// ErrRecoveryCodeUsed means the --recovery-code was valid but already consumed
+	ErrRecoveryCodeUsed ErrorCode = 1027
+	// ErrPasswordResetRequired means Proton requires a new password before the account can be used
+	ErrPasswordResetRequired ErrorCode = 1028
+	// ErrAccountDelinquent means the account is blocked for unpaid invoices
+	ErrAccountDelinquent ErrorCode = 1029
+	// ErrSRPVersionUnsupported means Proton asked for an SRP version this build can't compute
+	ErrSRPVersionUnsupported ErrorCode = 1030
+	// ErrNetwork means Proton couldn't be reached (DNS, connection, TLS), retrying later may help
+	ErrNetwork ErrorCode = 1031
+	// ErrServerError means Proton kept answering with a 5xx status after --retries
+	ErrServerError ErrorCode = 1032
+	// ErrSaltsTimeout means fetching key salts exceeded --salts-timeout, logging in again may help
+	ErrSaltsTimeout ErrorCode = 1033
+)
+
+// errSaltsTimeout is returned when fetching salts runs out of --salts-timeout
+var errSaltsTimeout = errors.New("salts request timed out")
+
+// errorTypes maps each code to the stable identifier output as errorType
+var errorTypes = map[ErrorCode]string{
+	ErrReadInput:             "read_input",
+	ErrAuthFailed:            "auth_failed",
+	ErrTOTPRequired:          "totp_required",
+	ErrTwoFactorFailed:       "two_factor_failed",
+	ErrGetUser:               "get_user",
+	ErrKeySalts:              "key_salts",
+	ErrPasswordRequired:      "password_required",
+	ErrReadStoredTokens:      "read_stored_tokens",
+	ErrRefreshTokenInvalid:   "refresh_token_invalid",
+	ErrRefreshFailed:         "refresh_failed",
+	ErrInvalidOptions:        "invalid_options",
+	ErrFIDO2:                 "fido2",
+	ErrKeyUnlock:             "key_unlock",
+	ErrTimeout:               "timeout",
+	ErrInternal:              "internal",
+	ErrPinMismatch:           "pin_mismatch",
+	ErrHumanVerification:     "human_verification",
+	ErrForkFailed:            "fork_failed",
+	ErrUsernameRequired:      "username_required",
+	ErrRateLimited:           "rate_limited",
+	ErrRevokeFailed:          "revoke_failed",
+	ErrInterrupted:           "interrupted",
+	ErrInvalidCredentials:    "invalid_credentials",
+	ErrServeFailed:           "serve_failed",
+	ErrRecoveryCodeRejected:  "recovery_code_rejected",
+	ErrRecoveryCodeUsed:      "recovery_code_used",
+	ErrPasswordResetRequired: "password_reset_required",
+	ErrAccountDelinquent:     "account_delinquent",
+	ErrSRPVersionUnsupported: "srp_version_unsupported",
+	ErrNetwork:               "network",
+	ErrServerError:           "server_error",
+	ErrSaltsTimeout:          "salts_timeout",
+}
+
+// String returns the stable identifier for the code, or "unknown"
+func (code ErrorCode) String() string {
+	if name, ok := errorTypes[code]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// errorResult builds a failed AuthResult with both the numeric code and its identifier
+func errorResult(code ErrorCode, format string, args ...any) AuthResult {
+	return AuthResult{
+		Error:     fmt.Sprintf(format, args...),
+		ErrorCode: code,
+		ErrorType: code.String(),
+	}
+}
+
+// withHTTPStatus adds the status of Proton's error response to result, and the
+// attempt count if err was retried. The status stays zero if err isn't an error
+// response, e.g. for network errors.
+func withHTTPStatus(result AuthResult, err error) AuthResult {
+	result.HTTPStatus, _ = apiStatus(err)
+	result.Attempts = retryAttempts(err)
+	return result
+}
+
+// Process exit codes by error category, so shell callers can branch without
+// parsing the JSON. Anything not listed exits with exitFailure.
+const (
+	exitFailure     = 1
+	exitBadInput    = 2
+	exitCredentials = 3
+	exitRateLimited = 4
+	exitNetwork     = 5
+	exitTimeout     = 6
+	exitInterrupted = 130
+)
+
+// exitCodeHelp documents the exit codes in --help
+const exitCodeHelp = `Exit codes:
+  0    success
+  1    any other error
+  2    bad input: missing, unreadable or malformed credentials or options
+  3    credentials rejected: password, 2FA, key password or refresh token
+  4    rate limited by Proton
+  5    Proton unreachable, failing TLS pinning or answering with server errors
+  6    --timeout or --salts-timeout exceeded
+  130  interrupted by SIGINT or SIGTERM
+`
+
+// exitCode returns the process exit code for a run that failed with code
+func (code ErrorCode) exitCode() int {
+	switch code {
+	case ErrReadInput, ErrTOTPRequired, ErrPasswordRequired, ErrReadStoredTokens,
+		ErrInvalidOptions, ErrUsernameRequired, ErrInvalidCredentials:
+		return exitBadInput
+	case ErrAuthFailed, ErrTwoFactorFailed, ErrFIDO2, ErrKeyUnlock, ErrRefreshTokenInvalid,
+		ErrRecoveryCodeRejected, ErrRecoveryCodeUsed:
+		return exitCredentials
+	case ErrRateLimited:
+		return exitRateLimited
+	case ErrNetwork, ErrServerError, ErrPinMismatch:
+		return exitNetwork
+	case ErrTimeout, ErrSaltsTimeout:
+		return exitTimeout
+	case ErrInterrupted:
+		return exitInterrupted
+	}
+	return exitFailure
+}
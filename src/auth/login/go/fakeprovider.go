@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// fakeTokenTTL is the lifetime of tokens issued by fakeProvider
+const fakeTokenTTL = time.Hour
+
+// fakeProvider stands in for Proton in --dry-run: logins only check credential
+// formats, refreshes issue placeholder tokens. This lets --serve and its
+// clients run end to end without an account or network access.
+type fakeProvider struct {
+	opts      options
+	refreshes int
+}
+
+func (p *fakeProvider) Login(_ context.Context) AuthResult {
+	return dryRun(p.opts)
+}
+
+// Refresh issues new placeholder tokens, numbered so clients can tell a
+// refresh happened
+func (p *fakeProvider) Refresh(_ context.Context, stored AuthResult) AuthResult {
+	p.refreshes++
+	return AuthResult{
+		AccessToken:  fmt.Sprintf("dry-run-access-%d", p.refreshes),
+		RefreshToken: fmt.Sprintf("dry-run-refresh-%d", p.refreshes),
+		UID:          "dry-run-uid",
+		UserID:       "dry-run-user",
+		KeyPassword:  stored.KeyPassword,
+		ExpiresAt:    time.Now().Add(fakeTokenTTL).UTC().Format(time.RFC3339),
+		TTLSource:    "default",
+		DryRun:       true,
+	}
+}
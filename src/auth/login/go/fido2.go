@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/henrybear327/go-proton-api"
+)
+
+// fido2Assertion is the decoded form of a base64-encoded WebAuthn assertion,
+// as produced by navigator.credentials.get() and passed via --fido2-assertion
+type fido2Assertion struct {
+	ClientData        string `json:"clientData"`
+	AuthenticatorData string `json:"authenticatorData"`
+	Signature         string `json:"signature"`
+	CredentialID      string `json:"credentialID"`
+}
+
+// fido2Request builds the FIDO2 part of the 2FA request from a pre-computed
+// assertion, or prompts for one when running in a terminal.
+func fido2Request(info proton.FIDO2Info, assertion string, interactive bool, reader *bufio.Reader) (proton.FIDO2Req, error) {
+	if assertion == "" {
+		if !interactive {
+			return proton.FIDO2Req{}, errors.New("account requires a FIDO2 security key but no authenticator is available (use --fido2-assertion)")
+		}
+
+		challenge, err := json.Marshal(info.AuthenticationOptions)
+		if err != nil {
+			return proton.FIDO2Req{}, fmt.Errorf("failed to encode FIDO2 challenge: %w", err)
+		}
+		fmt.Fprintf(status, "Security key required. Sign this challenge with your authenticator:\n%s\n", challenge)
+		fmt.Fprint(status, "FIDO2 assertion (base64): ")
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return proton.FIDO2Req{}, errors.New("failed to read FIDO2 assertion")
+		}
+		assertion = line
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(assertion))
+	if err != nil {
+		return proton.FIDO2Req{}, fmt.Errorf("FIDO2 assertion is not valid base64: %w", err)
+	}
+
+	var a fido2Assertion
+	if err := json.Unmarshal(decoded, &a); err != nil {
+		return proton.FIDO2Req{}, fmt.Errorf("FIDO2 assertion is not valid JSON: %w", err)
+	}
+	if a.ClientData == "" || a.AuthenticatorData == "" || a.Signature == "" || a.CredentialID == "" {
+		return proton.FIDO2Req{}, errors.New("FIDO2 assertion requires clientData, authenticatorData, signature and credentialID")
+	}
+
+	return proton.FIDO2Req{
+		AuthenticationOptions: info.AuthenticationOptions,
+		ClientData:            a.ClientData,
+		AuthenticatorData:     a.AuthenticatorData,
+		Signature:             a.Signature,
+		CredentialID:          a.CredentialID,
+	}, nil
+}
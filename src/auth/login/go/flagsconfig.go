@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// applyConfigFile sets flags from a --config file, a YAML (or JSON) mapping of
+// flag names to values, e.g. "app-version: web-lumo@5.0.0". Flags given on the
+// command line keep their value. Repeatable flags take a list.
+func applyConfigFile(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("invalid config file: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return errors.New("invalid config file: expected a mapping of flag names to values")
+	}
+
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		f := fs.Lookup(key.Value)
+		if f == nil || f.Name == "config" {
+			return fmt.Errorf("config file line %d: unknown key %q, keys are flag names without dashes", key.Line, key.Value)
+		}
+		if explicit[f.Name] {
+			continue
+		}
+
+		var values []string
+		switch value.Kind {
+		case yaml.ScalarNode:
+			values = []string{value.Value}
+		case yaml.SequenceNode:
+			for _, item := range value.Content {
+				if item.Kind != yaml.ScalarNode {
+					return fmt.Errorf("config file line %d: %s must be a list of values", item.Line, key.Value)
+				}
+				values = append(values, item.Value)
+			}
+		default:
+			return fmt.Errorf("config file line %d: %s must be a value or a list", value.Line, key.Value)
+		}
+		for _, v := range values {
+			if err := f.Value.Set(v); err != nil {
+				return fmt.Errorf("config file line %d: invalid %s %q: %v", value.Line, key.Value, v, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package main
+
+// lockFile is a no-op where flock isn't available, writes are still atomic
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
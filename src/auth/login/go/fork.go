@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/henrybear327/go-proton-api"
+)
+
+// ForkedSession is a child session forked from the login session.
+// It has its own tokens and can be revoked without ending the parent.
+type ForkedSession struct {
+	Selector     string `json:"selector"`
+	UID          string `json:"uid"`
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresAt    string `json:"expiresAt"`
+}
+
+// forkRequest is the body of POST /auth/v4/sessions/forks
+type forkRequest struct {
+	ChildClientID string
+	Independent   int
+}
+
+// forkResponse is the pulled child session from GET /auth/v4/sessions/forks/{selector}
+type forkResponse struct {
+	UID          string
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64
+}
+
+// forkSession forks the authenticated session uid and pulls the child session.
+// go-proton-api doesn't expose the fork endpoints, so they're called directly
+// with the same host, headers and transport as the manager.
+func forkSession(ctx context.Context, opts options, uid, accessToken string) (*ForkedSession, error) {
+	client := newAPIClient(opts)
+
+	start := time.Now()
+	var created struct{ Selector string }
+	res, err := client.R().
+		SetContext(ctx).
+		SetHeader("x-pm-uid", uid).
+		SetAuthToken(accessToken).
+		SetBody(forkRequest{ChildClientID: childClientID(opts.appVersion)}).
+		SetResult(&created).
+		SetError(&proton.APIError{}).
+		Post("/auth/v4/sessions/forks")
+	err = apiError(res, err)
+	logStep(ctx, "ForkSession", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("create fork: %w", err)
+	}
+
+	start = time.Now()
+	var child forkResponse
+	res, err = client.R().
+		SetContext(ctx).
+		SetResult(&child).
+		SetError(&proton.APIError{}).
+		Get("/auth/v4/sessions/forks/" + created.Selector)
+	err = apiError(res, err)
+	logStep(ctx, "PullFork", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("pull fork: %w", err)
+	}
+
+	ttl := defaultTTL
+	if child.ExpiresIn > 0 {
+		ttl = time.Duration(child.ExpiresIn) * time.Second
+	}
+	return &ForkedSession{
+		Selector:     created.Selector,
+		UID:          child.UID,
+		AccessToken:  child.AccessToken,
+		RefreshToken: child.RefreshToken,
+		ExpiresAt:    time.Now().Add(ttl).UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// newAPIClient builds a plain resty client for endpoints go-proton-api lacks
+func newAPIClient(opts options) *resty.Client {
+	host := proton.DefaultHostURL
+	if opts.host != "" {
+		host = strings.TrimSuffix(opts.host, "/")
+	}
+	return resty.New().
+		SetBaseURL(host).
+		SetTransport(withUserAgent(newTransport(opts), opts.userAgent)).
+		SetLogger(restyLogger{}).
+		SetHeader("x-pm-appversion", opts.appVersion)
+}
+
+// apiError turns a failed response into a *proton.APIError, like go-proton-api does
+func apiError(res *resty.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	if res.IsError() {
+		apiErr, ok := res.Error().(*proton.APIError)
+		if !ok {
+			apiErr = &proton.APIError{Message: fmt.Sprintf("unexpected status %d", res.StatusCode())}
+		}
+		apiErr.Status = res.StatusCode()
+		return apiErr
+	}
+	return nil
+}
+
+// childClientID is the client name from the app version, e.g. "web-lumo" for "web-lumo@5.0.0"
+func childClientID(appVersion string) string {
+	name, _, _ := strings.Cut(appVersion, "@")
+	return name
+}
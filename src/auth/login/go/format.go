@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Output formats for --format
+const (
+	formatJSON      = "json"
+	formatDotenv    = "dotenv"
+	formatExport    = "export"
+	formatHASecrets = "ha-secrets"
+)
+
+// formatResult renders result in the given format, JSON for anything unknown
+func formatResult(result AuthResult, format string) []byte {
+	switch format {
+	case formatDotenv, formatExport:
+		return envOutput(result, format == formatExport)
+	case formatHASecrets:
+		output, _ := haSecretsYAML(result, nil)
+		return output
+	default:
+		output, _ := json.MarshalIndent(result, "", "  ")
+		return output
+	}
+}
+
+// envOutput renders result as KEY=value lines, prefixed with "export " if requested.
+// Empty fields are omitted. Error results have ERROR* lines instead of tokens.
+func envOutput(result AuthResult, export bool) []byte {
+	var b strings.Builder
+	for _, v := range envVars(result) {
+		if export {
+			fmt.Fprintf(&b, "export %s=%s\n", v[0], shellQuote(v[1]))
+		} else {
+			fmt.Fprintf(&b, "%s=%s\n", v[0], dotenvQuote(v[1]))
+		}
+	}
+	return []byte(strings.TrimSuffix(b.String(), "\n"))
+}
+
+// envVars lists the non-empty result fields as variable name/value pairs
+func envVars(result AuthResult) [][2]string {
+	var vars [][2]string
+	if result.Error != "" {
+		vars = [][2]string{
+			{"ERROR", result.Error},
+			{"ERROR_CODE", strconv.Itoa(int(result.ErrorCode))},
+			{"ERROR_TYPE", result.ErrorType},
+		}
+	} else {
+		vars = [][2]string{
+			{"PROTON_ACCESS_TOKEN", result.AccessToken},
+			{"PROTON_REFRESH_TOKEN", result.RefreshToken},
+			{"PROTON_UID", result.UID},
+			{"PROTON_USER_ID", result.UserID},
+			{"PROTON_KEY_PASSWORD", result.KeyPassword},
+			{"PROTON_EXPIRES_AT", result.ExpiresAt},
+			{"PROTON_TTL_SOURCE", result.TTLSource},
+		}
+		if result.Fork != nil {
+			vars = append(vars,
+				[2]string{"PROTON_FORK_UID", result.Fork.UID},
+				[2]string{"PROTON_FORK_ACCESS_TOKEN", result.Fork.AccessToken},
+				[2]string{"PROTON_FORK_REFRESH_TOKEN", result.Fork.RefreshToken},
+				[2]string{"PROTON_FORK_EXPIRES_AT", result.Fork.ExpiresAt},
+			)
+		}
+	}
+	return slices.DeleteFunc(vars, func(v [2]string) bool { return v[1] == "" })
+}
+
+// shellQuote single-quotes s for POSIX shells
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// dotenvQuote double-quotes s, escaping what dotenv parsers expand
+func dotenvQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
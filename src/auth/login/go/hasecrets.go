@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// haSecretName is the Home Assistant secrets.yaml key for an envVars name,
+// e.g. "proton_access_token" for PROTON_ACCESS_TOKEN
+func haSecretName(envName string) string {
+	name := strings.ToLower(envName)
+	if !strings.HasPrefix(name, "proton_") {
+		name = "proton_" + name
+	}
+	return name
+}
+
+// haSecretsYAML renders result as proton_* keys for Home Assistant's
+// secrets.yaml, for use with !secret. Keys are set in existing (other keys and
+// comments are kept), or in a new document if existing is empty.
+func haSecretsYAML(result AuthResult, existing []byte) ([]byte, error) {
+	var doc yaml.Node
+	if len(bytes.TrimSpace(existing)) > 0 {
+		if err := yaml.Unmarshal(existing, &doc); err != nil {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, errors.New("secrets file is not a YAML mapping")
+	}
+
+	for _, v := range envVars(result) {
+		setMappingValue(root, haSecretName(v[0]), v[1])
+	}
+
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// setMappingValue sets key to a double-quoted (so always escaped) string value
+func setMappingValue(mapping *yaml.Node, key, value string) {
+	valueNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Style: yaml.DoubleQuotedStyle, Value: value}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content[i+1] = valueNode
+			return
+		}
+	}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, valueNode)
+}
+
+// writeHASecrets updates the proton_* keys of the secrets file at path in place,
+// atomically and with token file permissions
+func writeHASecrets(path string, result AuthResult) error {
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	output, err := haSecretsYAML(result, existing)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, output, tokenFileMode())
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/henrybear327/go-proton-api"
+)
+
+// hvVerifyURL is where a user completes a human verification challenge
+const hvVerifyURL = "https://verify.proton.me/"
+
+// HumanVerification describes a challenge Proton requires before login can proceed.
+// Complete it at URL, then rerun with --hv-token set to the resulting token.
+type HumanVerification struct {
+	Methods []string `json:"methods"`
+	Token   string   `json:"token"`
+	URL     string   `json:"url"`
+}
+
+// humanVerification extracts the challenge from a "human verification required" API error
+func humanVerification(err error) (*HumanVerification, bool) {
+	var apiErr *proton.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != proton.HumanVerificationRequired {
+		return nil, false
+	}
+
+	var details struct {
+		HumanVerificationMethods []string
+		HumanVerificationToken   string
+	}
+	if raw, err := json.Marshal(apiErr.Details); err == nil {
+		_ = json.Unmarshal(raw, &details)
+	}
+
+	query := url.Values{}
+	query.Set("methods", strings.Join(details.HumanVerificationMethods, ","))
+	query.Set("token", details.HumanVerificationToken)
+
+	return &HumanVerification{
+		Methods: details.HumanVerificationMethods,
+		Token:   details.HumanVerificationToken,
+		URL:     hvVerifyURL + "?" + query.Encode(),
+	}, true
+}
+
+// hvResult builds the error result for a human verification challenge
+func hvResult(hv *HumanVerification) AuthResult {
+	result := errorResult(ErrHumanVerification, "Human verification required (%s): complete it at %s, then retry with --hv-token",
+		strings.Join(hv.Methods, ", "), hv.URL)
+	result.HumanVerification = hv
+	return result
+}
+
+// hvHeaders returns a pre-request hook that attaches a completed verification token
+func hvHeaders(token, tokenType string) resty.RequestMiddleware {
+	return func(_ *resty.Client, req *resty.Request) error {
+		req.SetHeader("x-pm-human-verification-token", token)
+		req.SetHeader("x-pm-human-verification-token-type", tokenType)
+		return nil
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// inspect prints a summary of a stored AuthResult from path (or stdin) to w.
+// Only metadata is shown, never tokens or key passwords. Makes no network calls.
+func inspect(path string, w io.Writer, now time.Time) error {
+	data, err := readPathOrStdin(path)
+	if err != nil {
+		return err
+	}
+	var stored AuthResult
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "UID:\t%s\n", orNone(stored.UID))
+	fmt.Fprintf(tw, "User ID:\t%s\n", orNone(stored.UserID))
+	fmt.Fprintf(tw, "Access token:\t%s\n", presence(stored.AccessToken))
+	fmt.Fprintf(tw, "Refresh token:\t%s\n", presence(stored.RefreshToken))
+	fmt.Fprintf(tw, "Key password:\t%s\n", presence(stored.KeyPassword))
+	if len(stored.Keys) > 0 {
+		fmt.Fprintf(tw, "Keys:\t%d\n", len(stored.Keys))
+		for _, key := range stored.Keys {
+			flags := ""
+			if key.Primary {
+				flags = " (primary)"
+			}
+			fmt.Fprintf(tw, "\t%s%s\n", orNone(key.Fingerprint), flags)
+		}
+	}
+	fmt.Fprintf(tw, "Expires at:\t%s\n", expiryStatus(stored.ExpiresAt, now))
+	if stored.Scope != "" {
+		fmt.Fprintf(tw, "Scope:\t%s\n", stored.Scope)
+	}
+	if stored.TTLSource != "" {
+		fmt.Fprintf(tw, "TTL source:\t%s\n", stored.TTLSource)
+	}
+	if stored.Error != "" {
+		fmt.Fprintf(tw, "Error:\t%s (%s, code %d)\n", stored.Error, stored.ErrorCode, int(stored.ErrorCode))
+	}
+	return tw.Flush()
+}
+
+// expiryStatus describes expiresAt relative to now
+func expiryStatus(expiresAt string, now time.Time) string {
+	if expiresAt == "" {
+		return "unknown"
+	}
+	exp, err := time.Parse(time.RFC3339, expiresAt)
+	if err != nil {
+		return fmt.Sprintf("%s (unparseable)", expiresAt)
+	}
+	remaining := exp.Sub(now).Round(time.Second)
+	if remaining <= 0 {
+		return fmt.Sprintf("%s (expired %s ago)", expiresAt, -remaining)
+	}
+	return fmt.Sprintf("%s (valid for %s)", expiresAt, remaining)
+}
+
+// presence reports whether a secret is set without revealing it
+func presence(secret string) string {
+	if secret == "" {
+		return "missing"
+	}
+	return "present"
+}
+
+// orNone shows a placeholder for empty values
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// interruptGrace is how long an interrupted run gets to wind down on its own
+// (in-flight requests fail on the cancelled context) before exiting anyway
+const interruptGrace = 2 * time.Second
+
+// handleInterrupts returns a context cancelled on SIGINT/SIGTERM, and a func to
+// call once the run has produced its result. On a signal the terminal state is
+// restored (a password prompt disables echo). If the run is still blocked after
+// interruptGrace, typically in a prompt, an interrupted result is printed and
+// the process exits.
+func handleInterrupts(format string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	fd := int(syscall.Stdin)
+	var state *term.State
+	if term.IsTerminal(fd) {
+		state, _ = term.GetState(fd)
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		select {
+		case <-finished:
+			return
+		case <-signals:
+		}
+		cancel()
+		if state != nil {
+			_ = term.Restore(fd, state)
+		}
+		logger.Info("Interrupted, cancelling")
+
+		select {
+		case <-finished:
+			return
+		case <-time.After(interruptGrace):
+		}
+		fmt.Fprintln(status) // end the prompt line
+		fmt.Fprintln(resultOut, string(formatResult(errorResult(ErrInterrupted, "Interrupted"), format)))
+		os.Exit(exitInterrupted)
+	}()
+
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			close(finished)
+			signal.Stop(signals)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// jsonCredentials is the object read from stdin with --json-input.
+// totp and mailboxPassword are only needed if the account requires them.
+type jsonCredentials struct {
+	Username        string `json:"username"`
+	Password        string `json:"password"`
+	TOTP            string `json:"totp,omitempty"`
+	MailboxPassword string `json:"mailboxPassword,omitempty"`
+}
+
+// applyJSONInput reads credentials from r and fills them into opts.
+// Explicit flags (e.g. --totp) take precedence over JSON fields.
+func applyJSONInput(r io.Reader, opts *options) error {
+	var creds jsonCredentials
+	if err := json.NewDecoder(r).Decode(&creds); err != nil {
+		return fmt.Errorf("invalid JSON input: %w", err)
+	}
+	if creds.Username == "" || creds.Password == "" {
+		return errors.New("username and password are required")
+	}
+
+	if opts.username == "" {
+		opts.username = creds.Username
+	}
+	opts.password = creds.Password
+	if opts.totp == "" {
+		opts.totp = creds.TOTP
+	}
+	opts.mailboxPassword = creds.MailboxPassword
+	return nil
+}
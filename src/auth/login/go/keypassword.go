@@ -0,0 +1,26 @@
+package main
+
+import "errors"
+
+// envKeyPassword can hold an already derived primary key password for --key-password
+const envKeyPassword = "PROTON_KEY_PASSWORD"
+
+// errKeyPasswordWithMailbox is returned when a key password is combined with a
+// mailbox password, which would only be used to derive the same key password
+var errKeyPasswordWithMailbox = errors.New("--key-password can't be combined with a mailbox password, it replaces it")
+
+// suppliedKeyPassword returns the key password from --key-password or env
+// (non-interactive). It replaces deriving one from the salts.
+func suppliedKeyPassword(opts options, interactive bool) ([]byte, bool, error) {
+	keyPassword, ok := opts.keyPassword, opts.keyPassword != ""
+	if !ok {
+		keyPassword, ok = lookupEnv(interactive, envKeyPassword)
+	}
+	if !ok {
+		return nil, false, nil
+	}
+	if opts.mailboxPassword != "" || opts.mailboxPasswordFile != "" {
+		return nil, false, errKeyPasswordWithMailbox
+	}
+	return []byte(keyPassword), true, nil
+}
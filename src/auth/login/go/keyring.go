@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/zalando/go-keyring"
+)
+
+// OS keyring entry used by --keyring.
+// Same service name as the lumo-tamer vault key.
+const (
+	keyringService = "lumo-tamer"
+	keyringAccount = "proton-auth"
+)
+
+// saveToKeyring stores the marshaled AuthResult in the OS keyring
+func saveToKeyring(output []byte) error {
+	return keyring.Set(keyringService, keyringAccount, string(output))
+}
+
+// loadFromKeyring reads a previously stored AuthResult from the OS keyring
+func loadFromKeyring() (AuthResult, error) {
+	data, err := keyring.Get(keyringService, keyringAccount)
+	if err != nil {
+		return AuthResult{}, fmt.Errorf("keyring: %w", err)
+	}
+	return parseStoredResult([]byte(data))
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/henrybear327/go-proton-api"
+)
+
+// KeyInfo describes one user key and the password that unlocks it
+type KeyInfo struct {
+	ID          string `json:"id"`
+	Fingerprint string `json:"fingerprint"`
+	Version     int    `json:"version"` // OpenPGP key version, 0 if the key couldn't be parsed
+	Primary     bool   `json:"primary"`
+	Active      bool   `json:"active"`
+	KeyPassword string `json:"keyPassword"`
+}
+
+// KeyPassword is an entry of AuthResult.KeyPasswords
+type KeyPassword struct {
+	KeyPassword string `json:"keyPassword"`
+	Version     int    `json:"version"`
+	Primary     bool   `json:"primary"`
+}
+
+// keyPasswords maps each key ID to its password, for looking up the key
+// a message was encrypted with
+func keyPasswords(keys []KeyInfo) map[string]KeyPassword {
+	if len(keys) == 0 {
+		return nil
+	}
+	m := make(map[string]KeyPassword, len(keys))
+	for _, key := range keys {
+		m[key.ID] = KeyPassword{KeyPassword: key.KeyPassword, Version: key.Version, Primary: key.Primary}
+	}
+	return m
+}
+
+// deriveKeys derives the key password for every user key from its own salt.
+// Keys that can't be parsed or have no salt are skipped with a warning,
+// the primary key is already handled by the caller.
+func deriveKeys(keys proton.Keys, salts proton.Salts, keyPass []byte) []KeyInfo {
+	infos := make([]KeyInfo, 0, len(keys))
+	for _, key := range keys {
+		keyPassword, err := salts.SaltForKey(keyPass, key.ID)
+		if err != nil || keyPassword == nil {
+			logger.Warn("Skipping key, failed to derive key password", "keyID", key.ID, "error", err)
+			continue
+		}
+		infos = append(infos, keyInfo(key, keyPassword))
+		wipe(keyPassword)
+	}
+	return infos
+}
+
+// keyInfo describes key, unlocked by keyPassword
+func keyInfo(key proton.Key, keyPassword []byte) KeyInfo {
+	fingerprint, version := "", 0
+	if parsed, err := crypto.NewKey(key.PrivateKey); err == nil {
+		fingerprint = parsed.GetFingerprint()
+		version = parsed.GetEntity().PrimaryKey.Version
+	} else {
+		logger.Warn("Failed to read key fingerprint", "keyID", key.ID, "error", err)
+	}
+
+	return KeyInfo{
+		ID:          key.ID,
+		Fingerprint: fingerprint,
+		Version:     version,
+		Primary:     bool(key.Primary),
+		Active:      bool(key.Active),
+		KeyPassword: string(keyPassword),
+	}
+}
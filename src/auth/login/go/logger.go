@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// logger writes leveled diagnostics to stderr (stdout is reserved for the result).
+// Replaced by setupLogging once flags are parsed.
+var logger = newLogger(slog.LevelInfo)
+
+// status receives prompts and informational lines on stderr, discarded with --quiet
+var status io.Writer = os.Stderr
+
+// sensitiveKeys are attribute names whose values are never logged
+var sensitiveKeys = map[string]bool{
+	"accesstoken":     true,
+	"refreshtoken":    true,
+	"keypassword":     true,
+	"password":        true,
+	"mailboxpassword": true,
+	"salt":            true,
+	"totp":            true,
+}
+
+// setupLogging configures the logger from the --log-level and --log-json flags.
+// With quiet, prompts and status lines are dropped and only errors are logged.
+// With jsonLines, every log line is a JSON object and status lines are dropped
+// unless stdin is a terminal, where prompts are still needed.
+func setupLogging(level string, quiet, jsonLines bool) error {
+	var l slog.Level
+	switch level {
+	case "error":
+		l = slog.LevelError
+	case "info":
+		l = slog.LevelInfo
+	case "debug":
+		l = slog.LevelDebug
+	default:
+		return fmt.Errorf("invalid --log-level %q: must be error, info or debug", level)
+	}
+	if quiet {
+		l = slog.LevelError
+		status = io.Discard
+	}
+	if jsonLines && !term.IsTerminal(int(syscall.Stdin)) {
+		status = io.Discard
+	}
+	jsonEvents = jsonLines
+	handlerOpts := &slog.HandlerOptions{Level: l, ReplaceAttr: redactAttr}
+	if jsonLines {
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, handlerOpts))
+	} else {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, handlerOpts))
+	}
+	return nil
+}
+
+func newLogger(level slog.Level) *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	}))
+}
+
+// Events logged for automation, as the "event" attribute
+const (
+	eventStart       = "start"
+	event2FARequired = "2fa_required"
+	eventSuccess     = "success"
+	eventError       = "error"
+)
+
+// jsonEvents is set with --log-json. Otherwise events are logged at debug
+// level only, the result on stdout already tells a terminal user the same.
+var jsonEvents bool
+
+// logEvent logs an event at level, or at debug level without --log-json
+func logEvent(level slog.Level, event, msg string, attrs ...any) {
+	if !jsonEvents {
+		level = slog.LevelDebug
+	}
+	logger.Log(context.Background(), level, msg, append(attrs, "event", event)...)
+}
+
+// logResult logs the outcome of a run as a success or error event. Error
+// events carry the error code and type, tokens and keys are never logged.
+func logResult(result AuthResult, attrs ...any) {
+	if result.Error != "" {
+		attrs = append(attrs, "code", int(result.ErrorCode), "errorType", result.ErrorType, "error", result.Error)
+		logEvent(slog.LevelError, eventError, "Failed", attrs...)
+		return
+	}
+	if result.ExpiresAt != "" {
+		attrs = append(attrs, "expiresAt", result.ExpiresAt)
+	}
+	logEvent(slog.LevelInfo, eventSuccess, "Succeeded", attrs...)
+}
+
+// redactAttr hides secrets, should one ever be passed as a log attribute
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, "[redacted]")
+	}
+	return a
+}
+
+// logStep logs the outcome and duration of an API step at debug level,
+// and records the duration for --timings
+func logStep(ctx context.Context, name string, start time.Time, err error, attrs ...any) {
+	took := time.Since(start)
+	recordStep(ctx, name, took)
+	if !logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	attrs = append(attrs, "duration", took.Round(time.Millisecond))
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+	logger.Debug(name, attrs...)
+}
+
+// restyLogger routes go-proton-api's HTTP client logs (mostly retry attempts)
+// through our logger at debug level
+type restyLogger struct{}
+
+func (restyLogger) Errorf(format string, v ...any) { logger.Debug(fmt.Sprintf(format, v...)) }
+func (restyLogger) Warnf(format string, v ...any)  { logger.Debug(fmt.Sprintf(format, v...)) }
+func (restyLogger) Debugf(format string, v ...any) { logger.Debug(fmt.Sprintf(format, v...)) }
@@ -2,11 +2,17 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
 	"os"
+	"regexp"
 	"strings"
 	"syscall"
 	"time"
@@ -15,16 +21,34 @@ import (
 	"golang.org/x/term"
 )
 
-// AuthResult is the JSON output structure
+// AuthResult is the JSON output structure, see --print-schema
 type AuthResult struct {
-	AccessToken  string `json:"accessToken"`
-	RefreshToken string `json:"refreshToken"`
-	UID          string `json:"uid"`
-	UserID       string `json:"userID"`
-	KeyPassword  string `json:"keyPassword"`
-	ExpiresAt    string `json:"expiresAt,omitempty"`
-	Error        string `json:"error,omitempty"`
-	ErrorCode    int    `json:"errorCode,omitempty"`
+	SchemaVersion int `json:"schemaVersion"` // set on output, see authResultSchemaVersion
+
+	AccessToken  string    `json:"accessToken"`
+	RefreshToken string    `json:"refreshToken"`
+	UID          string    `json:"uid"`
+	UserID       string    `json:"userID"`
+	KeyPassword  string    `json:"keyPassword"` // primary key only, see Keys
+	ExpiresAt    string    `json:"expiresAt,omitempty"`
+	TTLSource    string    `json:"ttlSource,omitempty"`  // "server" if ExpiresAt is from Proton, "default" if estimated
+	NearExpiry   bool      `json:"nearExpiry,omitempty"` // ExpiresAt is within --min-ttl already
+	Scope        string    `json:"scope,omitempty"`
+	ClockSkew    int64     `json:"clockSkewSeconds,omitempty"` // Proton's clock minus the local one
+	Error        string    `json:"error,omitempty"`
+	ErrorCode    ErrorCode `json:"errorCode,omitempty"`
+	ErrorType    string    `json:"errorType,omitempty"`  // stable identifier for ErrorCode, see errors.go
+	HTTPStatus   int       `json:"httpStatus,omitempty"` // status of Proton's error response, if it answered
+	Attempts     int       `json:"attempts,omitempty"`   // requests made by the failed step, if it was retried
+
+	Keys              []KeyInfo              `json:"keys,omitempty"`
+	KeyPasswords      map[string]KeyPassword `json:"keyPasswords,omitempty"` // Keys by key ID
+	Fork              *ForkedSession         `json:"fork,omitempty"`
+	Rotation          *Rotation              `json:"rotation,omitempty"` // --refresh only
+	Revoked           bool                   `json:"revoked,omitempty"`  // --revoke only
+	Timings           map[string]int64       `json:"timings,omitempty"`  // --timings only, milliseconds per step
+	DryRun            bool                   `json:"dryRun,omitempty"`   // --dry-run only
+	HumanVerification *HumanVerification     `json:"humanVerification,omitempty"`
 }
 
 // Default values for headers (can be overridden via CLI flags)
@@ -33,129 +57,911 @@ const (
 	defaultUserAgent  = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
 )
 
+// Environment variables for non-interactive credential input.
+// Only consulted when stdin is not a terminal (systemd, cron, pipes).
+const (
+	envUsername = "PROTON_USERNAME"
+	envPassword = "PROTON_PASSWORD"
+	envTOTP     = "PROTON_TOTP"
+)
+
+// appVersionPattern matches the name@semver shape Proton expects for X-PM-AppVersion,
+// e.g. "web-lumo@5.0.0" or "macos-drive@1.0.0-alpha.1+rclone"
+var appVersionPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*@\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+
+// options holds the settings parsed from command line flags
+type options struct {
+	appVersion   string
+	userAgent    string
+	host         string
+	env          string
+	insecure     bool
+	proxy        string
+	pins         stringList
+	timeout      time.Duration
+	saltsTimeout time.Duration
+	keyring      bool
+	verify       bool
+	timings      bool
+	fork         bool
+	mlock        bool
+	format       string
+	retries      int
+	dryRun       bool
+	saltsCache   string
+
+	noRefreshToken bool
+
+	username     string
+	passwordFile string
+	totp         string
+	totpSecret   string
+	recoveryCode string
+
+	fido2Assertion      string
+	mailboxPasswordFile string
+	keyPassword         string
+	hvToken             string
+	hvTokenType         string
+
+	// Set from --json-input, never from flags
+	jsonInput       bool
+	password        string
+	mailboxPassword string
+
+	// Never prompt, even in a terminal (JSON input, batch mode)
+	noPrompt bool
+
+	// Set from checkFilePerms at startup, reported by validate
+	filePermsErr error
+}
+
 func main() {
+	var opts options
+
 	// Parse command line flags
 	outputPath := flag.String("o", "", "Output file path (if not specified, outputs to stdout)")
-	appVersion := flag.String("app-version", defaultAppVersion, "X-PM-AppVersion header value")
-	userAgent := flag.String("user-agent", defaultUserAgent, "User-Agent header value")
+	flag.StringVar(&opts.appVersion, "app-version", defaultAppVersion, "X-PM-AppVersion header value, in name@semver form. Update when Proton bumps the minimum client version")
+	flag.StringVar(&opts.userAgent, "user-agent", defaultUserAgent, "User-Agent header value, set on the transport for every request (empty for the go-proton-api default)")
+	flag.StringVar(&opts.host, "host", "", "Proton API base URL (default "+proton.DefaultHostURL+"). Must be https unless --insecure is set")
+	flag.StringVar(&opts.env, "env", "production", "Proton environment: production or staging. --host takes precedence")
+	flag.BoolVar(&opts.insecure, "insecure", false, "Allow a plain http --host (e.g. a local mitmproxy)")
+	flag.StringVar(&opts.proxy, "proxy", "", "Proxy URL (http, https or socks5), overrides HTTP(S)_PROXY")
+	flag.Var(&opts.pins, "pin-sha256", "Base64 SHA-256 SPKI hash to pin the Proton certificate to (repeatable or comma-separated)")
+	flag.Var(&fileUmask, "umask", "Octal umask applied to written token files (default 077, i.e. mode 0600)")
+	flag.Var(&fileMode, "file-mode", "Octal mode of written token files, e.g. 0640 (overrides --umask)")
+	flag.StringVar(&fileOwner, "file-owner", "", "user:group to chown written token files to (root only)")
+	flag.BoolVar(&allowInsecurePerms, "allow-insecure-perms", false, "Allow world-readable token file modes")
+	flag.IntVar(&opts.retries, "retries", 3, "Retries for rate limited (429), server (5xx) and network errors in login, 2FA, user and salt requests, with exponential backoff (0 fails fast)")
+	flag.DurationVar(&opts.timeout, "timeout", 60*time.Second, "Maximum duration of the whole authentication flow (0 disables)")
+	flag.DurationVar(&opts.saltsTimeout, "salts-timeout", 10*time.Second, "Maximum duration of fetching key salts, retries included, so a slow request fails before Proton's salts window closes (0 disables)")
+	flag.StringVar(&opts.username, "username", "", "Proton username (email), skips the prompt")
+	flag.StringVar(&opts.passwordFile, "password-file", "", "File containing the login password, used instead of the prompt")
+	flag.StringVar(&opts.totp, "totp", "", "2FA TOTP code (skips the prompt)")
+	flag.StringVar(&opts.recoveryCode, "recovery-code", "", "2FA recovery code, used instead of TOTP or a security key (each code works once)")
+	flag.StringVar(&opts.totpSecret, "totp-secret", "", "Base32 TOTP seed to generate 2FA codes from (or set "+envTOTPSecret+"), for fully headless logins")
+	flag.StringVar(&opts.fido2Assertion, "fido2-assertion", "", "Base64-encoded WebAuthn assertion JSON for security key 2FA (headless use)")
+	flag.StringVar(&opts.mailboxPasswordFile, "mailbox-password-file", "", "File containing the mailbox password (two-password accounts only)")
+	flag.StringVar(&opts.keyPassword, "key-password", "", "Already derived primary key password (or set "+envKeyPassword+"), skips fetching salts. Replaces the mailbox password")
+	flag.StringVar(&opts.hvToken, "hv-token", "", "Completed human verification token, to retry a login that required CAPTCHA")
+	flag.StringVar(&opts.hvTokenType, "hv-token-type", "captcha", "Human verification method the --hv-token was obtained with")
+	flag.BoolVar(&opts.keyring, "keyring", false, "Store tokens in the OS keyring instead of a file (and read them from there with --refresh)")
+	flag.BoolVar(&opts.jsonInput, "json-input", false, `Read credentials from stdin as {"username","password","totp","mailboxPassword"} instead of prompting`)
+	quiet := flag.Bool("quiet", false, "Suppress prompts and status lines on stderr, only errors are logged")
+	logLevel := flag.String("log-level", "info", "Stderr log level: error, info or debug. Secrets are never logged")
+	logJSON := flag.Bool("log-json", false, "Log to stderr as JSON lines, with start, 2fa_required, success and error events for log pipelines")
+	flag.BoolVar(&opts.fork, "fork", false, "Fork a child session after login and output its tokens as \"fork\"")
+	flag.BoolVar(&opts.mlock, "mlock", false, "Lock password and key buffers in memory so they're never swapped (best effort)")
+	flag.BoolVar(&opts.dryRun, "dry-run", false, "Check credential formats (username, password, TOTP) without contacting Proton")
+	flag.BoolVar(&opts.noRefreshToken, "no-refresh-token", false, "Leave the refresh token out of the output, so only a full login can renew the tokens (not with --watch)")
+	flag.StringVar(&opts.saltsCache, "salts-cache", "", "File caching key salts by user ID, encrypted with the login password, to skip fetching them on later logins")
+	flag.BoolVar(&opts.timings, "timings", false, "Add a \"timings\" object with the milliseconds spent in each step (NewClientWithLogin, Auth2FA, GetUser, GetSalts, ...) to the result")
+	flag.BoolVar(&opts.verify, "verify", false, "Check that the derived key password unlocks the primary key before reporting success")
+	accountsFile := flag.String("accounts-file", "", "YAML/JSON list of {username, passwordFile, totp, totpSecret} to log in as a batch, outputs an array of results")
+	concurrency := flag.Int("concurrency", 4, "Number of batch accounts authenticated in parallel")
+	watchMode := flag.Bool("watch", false, "Keep running and refresh the -o token file whenever it's within --min-ttl of expiry")
+	minTTL := flag.Duration("min-ttl", time.Hour, "Remaining token lifetime that triggers a refresh in --watch and --serve mode, and a nearExpiry warning on output")
+	serveAddr := flag.String("serve", "", "After login, serve the current tokens over HTTP on this address (\":PORT\" binds to 127.0.0.1), refreshing them near expiry")
+	serveToken := flag.String("serve-token", "", "Bearer token required by --serve (or set "+envServeToken+")")
+	inspectMode := flag.Bool("inspect", false, "Print a summary of stored tokens (read from -o path or stdin) without secrets or network calls")
+	flag.StringVar(&opts.format, "format", formatJSON, "Output format: json, dotenv (KEY=\"value\" lines), export (shell export lines) or ha-secrets (Home Assistant secrets.yaml, merged into -o)")
+	selectMode := flag.Bool("select", false, "Pick a token file from --tokens-dir in a menu, then refresh or inspect it")
+	tokensDir := flag.String("tokens-dir", ".", "Directory with token files (*.json) listed by --select")
+	revokeMode := flag.Bool("revoke", false, "Log out the session of stored tokens (read from -o path or stdin) server-side")
+	refreshMode := flag.Bool("refresh", false, "Refresh stored tokens (read from -o path, "+envUID+" and "+envRefreshToken+", or stdin) instead of logging in")
+	outputFD := flag.Int("output-fd", 1, "File descriptor to write the result to instead of stdout, e.g. a pipe from a parent process")
+	printSchema := flag.Bool("print-schema", false, "Print the JSON Schema of the result and exit")
+	configPath := flag.String("config", "", "YAML/JSON file of flag values keyed by flag name (e.g. app-version: web-lumo@5.0.0), command line flags take precedence")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprint(flag.CommandLine.Output(), "\n"+exitCodeHelp)
+	}
 	flag.Parse()
 
-	result := authenticate(*appVersion, *userAgent)
+	if *configPath != "" {
+		if err := applyConfigFile(flag.CommandLine, *configPath); err != nil {
+			output, _ := json.MarshalIndent(errorResult(ErrInvalidOptions, "Invalid options: --config: %v", err), "", "  ")
+			fmt.Println(string(output))
+			os.Exit(ErrInvalidOptions.exitCode())
+		}
+	}
+	if err := resolveEnv(&opts); err != nil {
+		output, _ := json.MarshalIndent(errorResult(ErrInvalidOptions, "Invalid options: %v", err), "", "  ")
+		fmt.Println(string(output))
+		os.Exit(ErrInvalidOptions.exitCode())
+	}
+	if uid, gid, err := checkFilePerms(); err != nil {
+		// The error result is still written, so write it with the default mode
+		fileMode, fileUmask = 0, 0o077
+		opts.filePermsErr = err
+	} else {
+		fileUID, fileGID = uid, gid
+	}
+
+	// Without a usable descriptor the error can only go to stdout
+	if *outputFD != 1 {
+		f, err := openOutputFD(*outputFD)
+		if err != nil {
+			output, _ := json.MarshalIndent(errorResult(ErrInvalidOptions, "Invalid options: %v", err), "", "  ")
+			fmt.Println(string(output))
+			os.Exit(ErrInvalidOptions.exitCode())
+		}
+		defer f.Close()
+		resultOut = f
+	}
 
-	// Output JSON
-	output, _ := json.MarshalIndent(result, "", "  ")
+	if *printSchema {
+		output, _ := json.MarshalIndent(authResultSchema(), "", "  ")
+		fmt.Fprintln(resultOut, string(output))
+		return
+	}
+
+	logLevelErr := setupLogging(*logLevel, *quiet, *logJSON)
 
-	if *outputPath != "" {
-		err := os.WriteFile(*outputPath, output, 0600)
+	// The picked file becomes the -o path of the chosen mode, as if given on the command line
+	if *selectMode {
+		if !term.IsTerminal(int(syscall.Stdin)) {
+			output, _ := json.MarshalIndent(errorResult(ErrInvalidOptions, "Invalid options: %v", errSelectNeedsTerminal), "", "  ")
+			fmt.Fprintln(resultOut, string(output))
+			os.Exit(ErrInvalidOptions.exitCode())
+		}
+		path, action, err := selectAccount(*tokensDir, os.Stdin, status, time.Now())
 		if err != nil {
+			output, _ := json.MarshalIndent(errorResult(ErrReadStoredTokens, "Failed to select account: %v", err), "", "  ")
+			fmt.Fprintln(resultOut, string(output))
+			os.Exit(ErrReadStoredTokens.exitCode())
+		}
+		*outputPath = path
+		*inspectMode = action == selectInspect
+		*refreshMode = action == selectRefresh
+	}
+
+	if *inspectMode {
+		if err := inspect(*outputPath, resultOut, time.Now()); err != nil {
+			output, _ := json.MarshalIndent(errorResult(ErrReadStoredTokens, "Failed to read stored tokens: %v", err), "", "  ")
+			fmt.Fprintln(resultOut, string(output))
+			os.Exit(ErrReadStoredTokens.exitCode())
+		}
+		return
+	}
+
+	logEvent(slog.LevelInfo, eventStart, "Starting", "mode", runMode(*watchMode, *revokeMode, *refreshMode, *accountsFile != "", opts.dryRun))
+
+	// Watch mode applies --timeout per refresh, not to the whole run
+	if *watchMode && logLevelErr == nil {
+		result := runWatch(opts, *outputPath, *minTTL)
+		if result.Error != "" {
+			logResult(result)
+			output, _ := json.MarshalIndent(result, "", "  ")
+			fmt.Fprintln(resultOut, string(output))
+			os.Exit(result.ErrorCode.exitCode())
+		}
+		return
+	}
+
+	if *serveAddr != "" {
+		addr, err := checkServe(opts, *serveAddr, serveToken)
+		if err != nil {
+			output, _ := json.MarshalIndent(errorResult(ErrInvalidOptions, "Invalid options: %v", err), "", "  ")
+			fmt.Fprintln(resultOut, string(output))
+			os.Exit(ErrInvalidOptions.exitCode())
+		}
+		*serveAddr = addr
+	}
+
+	ctx, runDone := handleInterrupts(opts.format)
+	defer runDone()
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+
+	// The -o path is the input here, so the result always goes to stdout
+	if *revokeMode {
+		result := runSafely(func() AuthResult {
+			if logLevelErr != nil {
+				return errorResult(ErrInvalidOptions, "Invalid options: %v", logLevelErr)
+			}
+			if err := opts.validate(); err != nil {
+				return errorResult(ErrInvalidOptions, "Invalid options: %v", err)
+			}
+			return revoke(ctx, *outputPath, opts)
+		})
+		runDone()
+		logResult(result)
+		fmt.Fprintln(resultOut, string(formatResult(result, opts.format)))
+		if result.Error != "" {
+			os.Exit(result.ErrorCode.exitCode())
+		}
+		return
+	}
+
+	if *accountsFile != "" && logLevelErr == nil {
+		runBatch(ctx, opts, *accountsFile, *concurrency, *minTTL, *outputPath)
+		return
+	}
+
+	result := runSafely(func() AuthResult {
+		if logLevelErr != nil {
+			return errorResult(ErrInvalidOptions, "Invalid options: %v", logLevelErr)
+		}
+		return run(ctx, opts, *refreshMode, *outputPath)
+	})
+	runDone()
+	markNearExpiry(&result, *minTTL, time.Now())
+	logResult(result)
+
+	if *serveAddr != "" && result.Error == "" {
+		result = serve(newAuthProvider(opts), opts.timeout, *serveAddr, *serveToken, *minTTL, *outputPath, opts.noRefreshToken, result)
+		if result.Error == "" {
+			return
+		}
+		logResult(result)
+		output, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Fprintln(resultOut, string(output))
+		os.Exit(result.ErrorCode.exitCode())
+	}
+
+	if opts.noRefreshToken {
+		result.RefreshToken = ""
+	}
+
+	// Successful results go to the keyring (always as JSON) if requested, falling back to file/stdout
+	if opts.keyring && result.Error == "" {
+		output, _ := json.MarshalIndent(result, "", "  ")
+		err := saveToKeyring(output)
+		if err == nil {
+			fmt.Fprintf(status, "Auth tokens saved to OS keyring (%s/%s)\n", keyringService, keyringAccount)
+			return
+		}
+		logger.Warn("OS keyring unavailable, falling back to file output", "error", err)
+	}
+
+	// Home Assistant secrets are merged into the existing file, and errors never
+	// replace the working tokens there
+	if opts.format == formatHASecrets && *outputPath != "" {
+		if result.Error != "" {
+			fmt.Fprintln(resultOut, string(formatResult(result, formatJSON)))
+			os.Exit(result.ErrorCode.exitCode())
+		}
+		if err := writeHASecrets(*outputPath, result); err != nil {
 			fmt.Fprintf(os.Stderr, "Error writing to file: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Fprintf(os.Stderr, "Auth tokens written to %s\n", *outputPath)
-	} else {
-		fmt.Println(string(output))
+		fmt.Fprintf(status, "Auth tokens written to %s\n", *outputPath)
+		return
 	}
 
+	writeOutput(*outputPath, formatResult(result, opts.format))
+
 	if result.Error != "" {
-		os.Exit(1)
+		os.Exit(result.ErrorCode.exitCode())
 	}
 }
 
-func authenticate(appVersion, userAgent string) AuthResult {
-	reader := bufio.NewReader(os.Stdin)
+// runMode names the mode of a run for the start event
+func runMode(watchMode, revokeMode, refreshMode, batch, dryRun bool) string {
+	switch {
+	case watchMode:
+		return "watch"
+	case revokeMode:
+		return "revoke"
+	case batch:
+		return "batch"
+	case refreshMode:
+		return "refresh"
+	case dryRun:
+		return "dry-run"
+	}
+	return "login"
+}
+
+// runWatch validates the options and runs watch mode.
+// Errors are printed to stdout, never to the token file being watched.
+func runWatch(opts options, outputPath string, minTTL time.Duration) AuthResult {
+	if err := opts.validate(); err != nil {
+		return errorResult(ErrInvalidOptions, "Invalid options: %v", err)
+	}
+	if outputPath == "" {
+		return errorResult(ErrInvalidOptions, "Invalid options: %v", errWatchNeedsOutput)
+	}
+	if opts.format != formatJSON {
+		return errorResult(ErrInvalidOptions, "Invalid options: --watch only supports --format json")
+	}
+	if opts.noRefreshToken {
+		return errorResult(ErrInvalidOptions, "Invalid options: --watch needs the refresh token, drop --no-refresh-token")
+	}
+	return watch(opts, outputPath, minTTL)
+}
 
-	// Prompt for username
-	fmt.Fprint(os.Stderr, "Proton username (email): ")
-	username, err := reader.ReadString('\n')
+// checkServe validates the --serve options before logging in, filling in the
+// token from the environment and returning the address to listen on
+func checkServe(opts options, addr string, token *string) (string, error) {
+	if opts.format != formatJSON {
+		return "", errors.New("--serve only supports --format json")
+	}
+	if *token == "" {
+		*token = os.Getenv(envServeToken)
+	}
+	if *token == "" {
+		return "", errServeNeedsToken
+	}
+	resolved, err := serveAddress(addr)
 	if err != nil {
-		return AuthResult{Error: "Failed to read username", ErrorCode: 1000}
+		return "", fmt.Errorf("invalid --serve address: %w", err)
 	}
-	username = strings.TrimSpace(username)
+	return resolved, nil
+}
 
-	// Prompt for password (hidden input)
-	fmt.Fprint(os.Stderr, "Password: ")
-	passwordBytes, err := term.ReadPassword(int(syscall.Stdin))
-	fmt.Fprintln(os.Stderr) // newline after password
+// runBatch authenticates all accounts from the accounts file and outputs
+// an array of results. Exits non-zero if any account failed, see batchExitCode.
+func runBatch(ctx context.Context, opts options, accountsFile string, concurrency int, minTTL time.Duration, outputPath string) {
+	var errResult AuthResult
+	accounts, err := readAccounts(accountsFile)
 	if err != nil {
-		return AuthResult{Error: "Failed to read password", ErrorCode: 1000}
+		errResult = errorResult(ErrReadInput, "Failed to read accounts file: %v", err)
+	} else if err := opts.validate(); err != nil {
+		errResult = errorResult(ErrInvalidOptions, "Invalid options: %v", err)
+	} else if concurrency < 1 {
+		errResult = errorResult(ErrInvalidOptions, "Invalid options: --concurrency must be at least 1")
+	} else if opts.format != formatJSON {
+		errResult = errorResult(ErrInvalidOptions, "Invalid options: --accounts-file only supports --format json")
+	}
+	if errResult.Error != "" {
+		output, _ := json.MarshalIndent(errResult, "", "  ")
+		writeOutput(outputPath, output)
+		os.Exit(errResult.ErrorCode.exitCode())
+	}
+
+	results := authenticateBatch(ctx, opts, accounts, concurrency)
+	now := time.Now()
+	for i := range results {
+		markNearExpiry(&results[i].AuthResult, minTTL, now, "username", results[i].Username)
+		if opts.noRefreshToken {
+			results[i].RefreshToken = ""
+		}
+	}
+	output, _ := json.MarshalIndent(results, "", "  ")
+	writeOutput(outputPath, output)
+
+	if code := batchExitCode(results); code != 0 {
+		os.Exit(code)
+	}
+}
+
+// batchExitCode is the exit code shared by all failed accounts, exitFailure
+// if they failed for different reasons, or 0 if none failed
+func batchExitCode(results []accountResult) int {
+	code := 0
+	for _, r := range results {
+		if r.Error == "" {
+			continue
+		}
+		if c := r.ErrorCode.exitCode(); code == 0 || code == c {
+			code = c
+		} else {
+			return exitFailure
+		}
+	}
+	return code
+}
+
+// writeOutput atomically writes the output to the -o file (mode 0600 unless
+// --umask says otherwise), or to stdout (--output-fd)
+func writeOutput(outputPath string, output []byte) {
+	if outputPath != "" {
+		err := writeFileAtomic(outputPath, output, tokenFileMode())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing to file: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(status, "Auth tokens written to %s\n", outputPath)
+	} else {
+		fmt.Fprintln(resultOut, string(output))
 	}
-	password := string(passwordBytes)
+}
+
+// run validates the options and dispatches to the selected mode
+func run(ctx context.Context, opts options, refreshMode bool, outputPath string) AuthResult {
+	if err := opts.validate(); err != nil {
+		return errorResult(ErrInvalidOptions, "Invalid options: %v", err)
+	}
+	if refreshMode {
+		return timed(ctx, opts, func(ctx context.Context) AuthResult { return refresh(ctx, outputPath, opts) })
+	}
+	if opts.jsonInput {
+		opts.noPrompt = true
+		if err := applyJSONInput(os.Stdin, &opts); err != nil {
+			return errorResult(ErrReadInput, "Failed to read JSON input: %v", err)
+		}
+	}
+	return timed(ctx, opts, newAuthProvider(opts).Login)
+}
+
+// secretPattern matches long token-like strings, redacted from panic messages
+var secretPattern = regexp.MustCompile(`[A-Za-z0-9+/=_-]{20,}`)
+
+// runSafely converts a panic in fn (e.g. go-proton-api choking on a malformed
+// server response) into an error result, so stdout is always valid JSON.
+func runSafely(fn func() AuthResult) (result AuthResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg := secretPattern.ReplaceAllString(fmt.Sprint(r), "[redacted]")
+			result = errorResult(ErrInternal, "Internal error: %s", msg)
+		}
+	}()
+	return fn()
+}
+
+// lookupEnv returns the value of an environment variable, but only when
+// running non-interactively. In a terminal, prompts always take precedence.
+func lookupEnv(interactive bool, name string) (string, bool) {
+	if interactive {
+		return "", false
+	}
+	value := os.Getenv(name)
+	return value, value != ""
+}
+
+func authenticate(ctx context.Context, opts options) AuthResult {
+	reader := bufio.NewReader(os.Stdin)
+	// With --json-input stdin is already consumed, so never prompt
+	interactive := term.IsTerminal(int(syscall.Stdin)) && !opts.noPrompt
+
+	username, password, failure := readCredentials(opts, interactive, reader)
+	if failure != nil {
+		return *failure
+	}
+	lockSecret(opts, password)
+	defer wipe(password)
+
+	// A supplied key password replaces the salts and the mailbox password
+	suppliedKey, hasKeyPassword, err := suppliedKeyPassword(opts, interactive)
+	if err != nil {
+		return errorResult(ErrInvalidOptions, "Invalid options: %v", err)
+	}
+	lockSecret(opts, suppliedKey)
+	defer wipe(suppliedKey)
 
 	// Create Proton API manager
-	// Use default host URL (https://mail.proton.me/api) - don't override it
 	// Note: SRP auth often triggers CAPTCHA. Browser auth is the preferred method.
-	ctx := context.Background()
-	manager := proton.New(
-		proton.WithAppVersion(appVersion),
-		proton.WithUserAgent(userAgent),
-	)
+	manager, observer := newManager(opts)
 	defer manager.Close()
 
 	// Perform SRP authentication
-	client, auth, err := manager.NewClientWithLogin(ctx, username, []byte(password))
+	var client *proton.Client
+	var auth proton.Auth
+	err = withRetry(ctx, opts, observer, "NewClientWithLogin", func() error {
+		start := time.Now()
+		var err error
+		client, auth, err = manager.NewClientWithLogin(ctx, username, password)
+		srpVersion, _ := observer.negotiatedSRPVersion()
+		logStep(ctx, "NewClientWithLogin", start, err, "srpVersion", srpVersion, "twoFA", auth.TwoFA.Enabled, "passwordMode", auth.PasswordMode, "scope", auth.Scope)
+		return err
+	})
+	if hv, ok := humanVerification(err); ok {
+		return withHTTPStatus(hvResult(hv), err)
+	}
 	if err != nil {
-		return AuthResult{
-			Error:     fmt.Sprintf("Authentication failed: %v", err),
-			ErrorCode: 1001,
+		if result, ok := unsupportedSRPResult(err, observer); ok {
+			return result
 		}
+		return failed(ctx, ErrAuthFailed, "Authentication failed", err)
 	}
 	defer client.Close()
 
-	// Check if 2FA is required
-	if auth.TwoFA.Enabled != 0 {
-		fmt.Fprint(os.Stderr, "2FA TOTP code: ")
-		totp, err := reader.ReadString('\n')
+	if result, ok := passwordResetResult(observer); ok {
+		return result
+	}
+
+	// Check if 2FA is required.
+	// A recovery code replaces either method. Otherwise use the security key
+	// if an assertion was given or TOTP isn't an option.
+	twoFA := auth.TwoFA.Enabled
+	if twoFA != 0 {
+		logEvent(slog.LevelInfo, event2FARequired, "2FA required", "totp", twoFA&proton.HasTOTP != 0, "fido2", twoFA&proton.HasFIDO2 != 0)
+	}
+	useFIDO2 := opts.recoveryCode == "" && twoFA&proton.HasFIDO2 != 0 &&
+		(opts.fido2Assertion != "" || twoFA&proton.HasTOTP == 0)
+	if useFIDO2 {
+		fido2, err := fido2Request(auth.TwoFA.FIDO2, opts.fido2Assertion, interactive, reader)
 		if err != nil {
-			return AuthResult{Error: "Failed to read TOTP", ErrorCode: 1002}
+			return errorResult(ErrFIDO2, "FIDO2 failed: %v", err)
 		}
-		totp = strings.TrimSpace(totp)
 
-		err = client.Auth2FA(ctx, proton.Auth2FAReq{TwoFactorCode: totp})
+		err = auth2FA(ctx, opts, observer, client, proton.Auth2FAReq{FIDO2: fido2}, "method", "fido2")
 		if err != nil {
-			return AuthResult{
-				Error:     fmt.Sprintf("2FA failed: %v", err),
-				ErrorCode: 1003,
-			}
+			return failed(ctx, ErrTwoFactorFailed, "2FA failed", err)
+		}
+	} else if twoFA != 0 && opts.recoveryCode != "" {
+		if result := auth2FAWithRecoveryCode(ctx, opts, observer, client, opts.recoveryCode); result.Error != "" {
+			return result
+		}
+	} else if secret, ok := totpSecret(opts, interactive); twoFA != 0 && ok {
+		if err := auth2FAWithSecret(ctx, opts, observer, client, secret); err != nil {
+			return withClockSkew(failed(ctx, ErrTwoFactorFailed, "2FA with --totp-secret failed", err), observer)
+		}
+	} else if twoFA != 0 {
+		totp, ok := providedTOTP(opts, interactive)
+		if !ok && !interactive {
+			return errorResult(ErrTOTPRequired, "2FA is enabled but no TOTP code was provided (use --totp, %s or --totp-secret)", envTOTP)
+		}
+		if result := auth2FAWithTOTP(ctx, opts, observer, client, reader, totp, !ok); result.Error != "" {
+			return result
 		}
 	}
 
-	// Get user info to find the primary key ID
-	user, err := client.GetUser(ctx)
-	if err != nil {
-		return AuthResult{
-			Error:     fmt.Sprintf("Failed to get user: %v", err),
-			ErrorCode: 1006,
+	// Two-password accounts unlock their keys with a separate mailbox password
+	keyPass := password
+	twoPasswordMode := auth.PasswordMode == proton.TwoPasswordMode
+	if twoPasswordMode && !hasKeyPassword {
+		mailboxPassword, err := readMailboxPassword(opts, interactive)
+		if err != nil {
+			return errorResult(ErrReadInput, "Failed to read mailbox password: %v", err)
 		}
+		lockSecret(opts, mailboxPassword)
+		defer wipe(mailboxPassword)
+		keyPass = mailboxPassword
 	}
 
-	// Get salts - this is available in a time-limited window after auth
-	salts, err := client.GetSalts(ctx)
+	// Get user info to find the primary key ID
+	var user proton.User
+	err = withRetry(ctx, opts, observer, "GetUser", func() error {
+		start := time.Now()
+		var err error
+		user, err = client.GetUser(ctx)
+		logStep(ctx, "GetUser", start, err, "keys", len(user.Keys))
+		return err
+	})
 	if err != nil {
-		return AuthResult{
-			Error:     fmt.Sprintf("Failed to get salts: %v", err),
-			ErrorCode: 1007,
-		}
+		return failed(ctx, ErrGetUser, "Failed to get user", err)
+	}
+	if result, ok := delinquentResult(observer); ok {
+		return result
 	}
 
-	// Derive the key password using the primary key's salt
 	primaryKey := user.Keys.Primary()
-	keyPassword, err := salts.SaltForKey([]byte(password), primaryKey.ID)
-	if err != nil {
-		return AuthResult{
-			Error:     fmt.Sprintf("Failed to derive key password: %v", err),
-			ErrorCode: 1007,
+	var keyPassword []byte
+	var keys []KeyInfo
+	if hasKeyPassword {
+		// Without salts only the primary key is known. Always checked,
+		// nothing else would catch a stale key password.
+		logger.Debug("Using the supplied key password, skipping salts")
+		keyPassword = suppliedKey
+		if err := verifyKeyPassword(ctx, primaryKey, keyPassword); err != nil {
+			return errorResult(ErrKeyUnlock, "Supplied key password does not unlock the primary key: %v", err)
+		}
+		keys = []KeyInfo{keyInfo(primaryKey, keyPassword)}
+	} else {
+		// Get salts - this is available in a time-limited window after auth
+		// Reuse cached salts where possible, the salts endpoint is sensitive and rate limited
+		salts, cached := cachedSalts(opts, auth.UserID, password, user.Keys)
+		logger.Debug("Salts cache", "hit", cached)
+		if !cached {
+			salts, err = getSalts(ctx, opts, observer, client)
+			if err != nil {
+				return failed(ctx, ErrKeySalts, "Failed to get salts", err)
+			}
+			storeSalts(opts, auth.UserID, password, salts)
+		}
+
+		// Derive the key password using the primary key's salt
+		keyPassword, err = salts.SaltForKey(keyPass, primaryKey.ID)
+		if err != nil {
+			return errorResult(ErrKeySalts, "Failed to derive key password: %v", err)
+		}
+		lockSecret(opts, keyPassword)
+		defer wipe(keyPassword)
+
+		// A wrong password still derives a key password, so check it unlocks the primary key.
+		// Always done for two-password accounts, where the mailbox password isn't checked by SRP.
+		if opts.verify || twoPasswordMode {
+			if err := verifyKeyPassword(ctx, primaryKey, keyPassword); err != nil {
+				msg := "Key password does not unlock the primary key"
+				if twoPasswordMode {
+					msg = "Failed to unlock keys, mailbox password is incorrect"
+				}
+				return errorResult(ErrKeyUnlock, "%s: %v", msg, err)
+			}
 		}
+		keys = deriveKeys(user.Keys, salts, keyPass)
 	}
 
-	// Calculate expiry (tokens typically last ~24 hours, but we'll be conservative)
-	expiresAt := time.Now().Add(12 * time.Hour).UTC().Format(time.RFC3339)
+	expiresAt, ttlSource := observer.expiry()
 
-	return AuthResult{
+	result := AuthResult{
 		AccessToken:  auth.AccessToken,
 		RefreshToken: auth.RefreshToken,
 		UID:          auth.UID,
 		UserID:       auth.UserID,
 		KeyPassword:  string(keyPassword),
 		ExpiresAt:    expiresAt,
+		TTLSource:    ttlSource,
+		Scope:        auth.Scope,
+		Keys:         keys,
+	}
+	result = withClockSkew(result, observer)
+	result.KeyPasswords = keyPasswords(result.Keys)
+
+	if opts.fork {
+		fork, err := forkSession(ctx, opts, auth.UID, auth.AccessToken)
+		if err != nil {
+			return failed(ctx, ErrForkFailed, "Session fork failed", err)
+		}
+		result.Fork = fork
+	}
+	return result
+}
+
+// readCredentials gets the username and password from flags, JSON input, files,
+// env (non-interactive) or prompts. failure is set if they can't be read.
+func readCredentials(opts options, interactive bool, reader *bufio.Reader) (username string, password []byte, failure *AuthResult) {
+	fail := func(code ErrorCode, format string, args ...any) (string, []byte, *AuthResult) {
+		result := errorResult(code, format, args...)
+		return "", nil, &result
+	}
+
+	// Get username from flag, env (non-interactive) or prompt
+	username, ok := opts.username, opts.username != ""
+	if !ok {
+		username, ok = lookupEnv(interactive, envUsername)
+	}
+	if !ok {
+		// Reading piped stdin here would swallow input meant for something else
+		if !interactive {
+			return fail(ErrUsernameRequired, "Username is required when stdin is not a terminal (use --username or %s)", envUsername)
+		}
+		fmt.Fprint(status, "Proton username (email): ")
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return fail(ErrReadInput, "Failed to read username")
+		}
+		username = line
+	}
+	username = strings.TrimSpace(username)
+
+	// Get password from JSON input, file, env (non-interactive) or prompt (hidden input).
+	// Kept as []byte so it can be wiped once the key password is derived.
+	if opts.password != "" {
+		password = []byte(opts.password)
+	}
+	if password == nil && opts.passwordFile != "" {
+		var err error
+		password, err = readSecretFile(opts.passwordFile)
+		if err != nil {
+			return fail(ErrReadInput, "Failed to read password file: %v", err)
+		}
+	}
+	if password == nil {
+		if env, _ := lookupEnv(interactive, envPassword); env != "" {
+			password = []byte(env)
+		}
+	}
+	if password == nil {
+		if !interactive {
+			return fail(ErrPasswordRequired, "%s is required when stdin is not a terminal", envPassword)
+		}
+		fmt.Fprint(status, "Password: ")
+		var err error
+		password, err = term.ReadPassword(int(syscall.Stdin))
+		fmt.Fprintln(status) // newline after password
+		if err != nil {
+			return fail(ErrReadInput, "Failed to read password")
+		}
+	}
+	return username, password, nil
+}
+
+// providedTOTP returns the TOTP code from --totp, JSON input or env (non-interactive)
+func providedTOTP(opts options, interactive bool) (string, bool) {
+	if opts.totp != "" {
+		return opts.totp, true
+	}
+	return lookupEnv(interactive, envTOTP)
+}
+
+// verifyKeyPassword checks that keyPassword unlocks key
+func verifyKeyPassword(ctx context.Context, key proton.Key, keyPassword []byte) error {
+	start := time.Now()
+	unlocked, err := key.Unlock(keyPassword, nil)
+	logStep(ctx, "UnlockKey", start, err, "keyID", key.ID)
+	if err != nil {
+		return err
+	}
+	unlocked.ClearPrivateParams()
+	return nil
+}
+
+// auth2FA submits a second factor, retried like the other API calls
+func auth2FA(ctx context.Context, opts options, observer *responseObserver, client *proton.Client, req proton.Auth2FAReq, attrs ...any) error {
+	return withRetry(ctx, opts, observer, "Auth2FA", func() error {
+		start := time.Now()
+		err := client.Auth2FA(ctx, req)
+		logStep(ctx, "Auth2FA", start, err, attrs...)
+		return err
+	})
+}
+
+// getSalts fetches the key salts, retried like the other API calls but bounded
+// by --salts-timeout as a whole. Running out of it returns errSaltsTimeout.
+func getSalts(ctx context.Context, opts options, observer *responseObserver, client *proton.Client) (proton.Salts, error) {
+	saltsCtx := ctx
+	if opts.saltsTimeout > 0 {
+		var cancel context.CancelFunc
+		saltsCtx, cancel = context.WithTimeout(ctx, opts.saltsTimeout)
+		defer cancel()
+	}
+
+	var salts proton.Salts
+	err := withRetry(saltsCtx, opts, observer, "GetSalts", func() error {
+		start := time.Now()
+		var err error
+		salts, err = client.GetSalts(saltsCtx)
+		logStep(ctx, "GetSalts", start, err, "salts", len(salts))
+		return err
+	})
+	if err != nil && ctx.Err() == nil && errors.Is(saltsCtx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%w (--salts-timeout %s): %v", errSaltsTimeout, opts.saltsTimeout, err)
+	}
+	return salts, err
+}
+
+// readMailboxPassword gets the mailbox password from JSON input or file, or prompts for it
+func readMailboxPassword(opts options, interactive bool) ([]byte, error) {
+	if opts.mailboxPassword != "" {
+		return []byte(opts.mailboxPassword), nil
+	}
+	if opts.mailboxPasswordFile != "" {
+		return readSecretFile(opts.mailboxPasswordFile)
+	}
+	if !interactive {
+		return nil, errors.New("account uses a separate mailbox password, use --mailbox-password-file")
+	}
+
+	fmt.Fprint(status, "Mailbox password: ")
+	password, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Fprintln(status) // newline after password
+	return password, err
+}
+
+// readSecretFile reads a secret from a file, trimming a single trailing newline.
+// Warns if the file is readable by group or others.
+func readSecretFile(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		logger.Warn("Secret file is accessible by group/others, consider chmod 600", "path", path, "mode", fmt.Sprintf("%04o", info.Mode().Perm()))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	data = bytes.TrimSuffix(data, []byte("\r"))
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	return data, nil
+}
+
+// failed builds the result for a failed API call.
+// Transient failures (deadline, rate limiting, server and network errors) and
+// pinning errors get their own code, so callers can tell them from a rejection.
+// code is kept for answers about the request itself, e.g. a wrong password.
+func failed(ctx context.Context, code ErrorCode, msg string, err error) AuthResult {
+	if errors.Is(err, errPinMismatch) {
+		return errorResult(ErrPinMismatch, "%s: TLS pinning failed: %v", msg, err)
+	}
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return errorResult(ErrInterrupted, "%s: interrupted", msg)
+	}
+
+	var rateLimit *rateLimitError
+	status, isAPIError := apiStatus(err)
+	switch {
+	case errors.As(err, &rateLimit), isAPIError && status == http.StatusTooManyRequests:
+		code = ErrRateLimited
+	case errors.Is(err, errSaltsTimeout):
+		return errorResult(ErrSaltsTimeout, "%s: %v", msg, err)
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return errorResult(ErrTimeout, "%s: timed out: %v", msg, err)
+	case isAPIError && status >= 500:
+		code = ErrServerError
+	case !isAPIError && isNetworkError(err):
+		code = ErrNetwork
+	}
+	return withHTTPStatus(errorResult(code, "%s: %v", msg, err), err)
+}
+
+// validate checks option values before any network call is made
+func (opts options) validate() error {
+	if !appVersionPattern.MatchString(opts.appVersion) {
+		return fmt.Errorf("invalid --app-version %q: expected name@semver (e.g. web-lumo@5.0.0)", opts.appVersion)
+	}
+	if opts.host != "" {
+		u, err := url.Parse(opts.host)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid --host %q: must be an absolute URL", opts.host)
+		}
+		switch {
+		case u.Scheme == "https":
+		case u.Scheme == "http" && opts.insecure:
+		case u.Scheme == "http":
+			return fmt.Errorf("refusing plain http --host %q without --insecure", opts.host)
+		default:
+			return fmt.Errorf("invalid --host %q: unsupported scheme %q", opts.host, u.Scheme)
+		}
 	}
+	if opts.proxy != "" {
+		if _, err := parseProxyURL(opts.proxy); err != nil {
+			return err
+		}
+	}
+	for _, pin := range opts.pins {
+		if err := validatePin(pin); err != nil {
+			return err
+		}
+	}
+	if opts.retries < 0 {
+		return fmt.Errorf("invalid --retries %d: must not be negative", opts.retries)
+	}
+	if opts.saltsTimeout < 0 {
+		return fmt.Errorf("invalid --salts-timeout %s: must not be negative", opts.saltsTimeout)
+	}
+	if opts.filePermsErr != nil {
+		return opts.filePermsErr
+	}
+	switch opts.format {
+	case formatJSON, formatDotenv, formatExport, formatHASecrets:
+	default:
+		return fmt.Errorf("invalid --format %q: expected json, dotenv, export or ha-secrets", opts.format)
+	}
+	return nil
+}
+
+// newManager creates a Proton API manager.
+// Uses the default host URL (https://mail.proton.me/api) unless --host is set.
+func newManager(opts options) (*proton.Manager, *responseObserver) {
+	observer := &responseObserver{}
+	managerOpts := []proton.Option{
+		proton.WithAppVersion(opts.appVersion),
+		proton.WithTransport(observer.wrap(withUserAgent(newTransport(opts), opts.userAgent))),
+		proton.WithLogger(restyLogger{}),
+		proton.WithRetryCount(0), // retried by withRetry instead
+	}
+	if opts.host != "" {
+		managerOpts = append(managerOpts, proton.WithHostURL(strings.TrimSuffix(opts.host, "/")))
+	}
+	manager := proton.New(managerOpts...)
+	if opts.hvToken != "" {
+		manager.AddPreRequestHook(hvHeaders(opts.hvToken, opts.hvTokenType))
+	}
+
+	manager.AddPostRequestHook(observer.onResponse)
+	return manager, observer
 }
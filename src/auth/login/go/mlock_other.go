@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package main
+
+import "errors"
+
+func mlock(b []byte) error {
+	return errors.New("mlock is not supported on this platform")
+}
@@ -0,0 +1,9 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package main
+
+import "golang.org/x/sys/unix"
+
+func mlock(b []byte) error {
+	return unix.Mlock(b)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// responseObserver records metadata from raw Proton API responses
+// that go-proton-api doesn't expose on its typed results.
+type responseObserver struct {
+	mu         sync.Mutex
+	expiresIn  time.Duration
+	retryAfter time.Duration
+	rateLimit  bool
+
+	temporaryPassword bool
+	delinquent        int
+	srpVersion        int
+	srpVersionSeen    bool
+
+	clockSkew       time.Duration
+	clockSkewSeen   bool
+	clockSkewWarned bool
+}
+
+// wrap returns a transport that records the server clock and the Retry-After
+// header of rate limited responses. Post-request hooks don't run for errors,
+// so this sits below resty.
+func (o *responseObserver) wrap(rt http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		res, err := rt.RoundTrip(req)
+		if err == nil {
+			o.recordServerDate(res.Header, time.Now())
+		}
+		if err == nil && (res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable) {
+			if wait, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+				o.mu.Lock()
+				o.retryAfter, o.rateLimit = wait, true
+				o.mu.Unlock()
+			}
+		}
+		return res, err
+	})
+}
+
+// takeRetryAfter returns and clears the last recorded Retry-After wait
+func (o *responseObserver) takeRetryAfter() (time.Duration, bool) {
+	if o == nil {
+		return 0, false
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	wait, ok := o.retryAfter, o.rateLimit
+	o.retryAfter, o.rateLimit = 0, false
+	return wait, ok
+}
+
+// parseRetryAfter parses a Retry-After header in seconds or HTTP-date form
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now).Round(time.Second), 0), true
+	}
+	return 0, false
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// onResponse is registered as a manager post-request hook.
+// Only called for successful responses.
+func (o *responseObserver) onResponse(_ *resty.Client, res *resty.Response) error {
+	if res.Request == nil || res.Request.RawRequest == nil {
+		return nil
+	}
+
+	path := res.Request.RawRequest.URL.Path
+	logger.Debug("API response", "method", res.Request.Method, "path", path, "status", res.StatusCode(), "duration", res.Time().Round(time.Millisecond))
+
+	// Login and refresh both report the access token lifetime in seconds,
+	// login also whether the password must be reset first
+	if strings.HasSuffix(path, "/auth/v4") || strings.HasSuffix(path, "/auth/v4/refresh") {
+		var body struct {
+			ExpiresIn         int64
+			TemporaryPassword int
+		}
+		if err := json.Unmarshal(res.Body(), &body); err == nil {
+			o.mu.Lock()
+			if body.ExpiresIn > 0 {
+				o.expiresIn = time.Duration(body.ExpiresIn) * time.Second
+			}
+			o.temporaryPassword = body.TemporaryPassword != 0
+			o.mu.Unlock()
+		}
+	}
+
+	// The SRP version Proton negotiated, for reporting versions go-srp can't handle
+	if strings.HasSuffix(path, "/auth/v4/info") {
+		var body struct {
+			Version *int
+		}
+		if err := json.Unmarshal(res.Body(), &body); err == nil && body.Version != nil {
+			o.mu.Lock()
+			o.srpVersion, o.srpVersionSeen = *body.Version, true
+			o.mu.Unlock()
+		}
+	}
+
+	// The billing state isn't part of go-proton-api's User
+	if strings.HasSuffix(path, "/core/v4/users") {
+		var body struct {
+			User struct {
+				Delinquent int
+			}
+		}
+		if err := json.Unmarshal(res.Body(), &body); err == nil {
+			o.mu.Lock()
+			o.delinquent = body.User.Delinquent
+			o.mu.Unlock()
+		}
+	}
+	return nil
+}
+
+// accountState returns the password reset and billing state recorded from
+// the login and user responses
+func (o *responseObserver) accountState() (temporaryPassword bool, delinquent int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.temporaryPassword, o.delinquent
+}
+
+// negotiatedSRPVersion returns the SRP version from the last auth info response
+func (o *responseObserver) negotiatedSRPVersion() (int, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.srpVersion, o.srpVersionSeen
+}
+
+// Sources for AuthResult.TTLSource
+const (
+	ttlSourceServer  = "server"
+	ttlSourceDefault = "default"
+)
+
+// defaultTTL is used when Proton doesn't report a token lifetime
+// (tokens typically last ~24 hours, but we'll be conservative)
+const defaultTTL = 12 * time.Hour
+
+// expiry calculates the token expiry from the lifetime Proton reported,
+// and whether that value is authoritative.
+func (o *responseObserver) expiry() (expiresAt, source string) {
+	o.mu.Lock()
+	ttl := o.expiresIn
+	o.mu.Unlock()
+
+	source = ttlSourceServer
+	if ttl == 0 {
+		ttl, source = defaultTTL, ttlSourceDefault
+	}
+	return time.Now().Add(ttl).UTC().Format(time.RFC3339), source
+}
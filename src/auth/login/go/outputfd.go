@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// resultOut receives the result, stdout unless --output-fd says otherwise
+var resultOut io.Writer = os.Stdout
+
+// openOutputFD opens the inherited file descriptor fd for writing the result,
+// e.g. a pipe set up by a parent process. Stdin and stderr are refused, and
+// so is an fd that isn't open for writing.
+func openOutputFD(fd int) (*os.File, error) {
+	if fd < 0 || fd == 0 || fd == 2 {
+		return nil, fmt.Errorf("invalid --output-fd %d: must be 1 or an inherited descriptor above 2", fd)
+	}
+	if err := checkWritableFD(fd); err != nil {
+		return nil, fmt.Errorf("invalid --output-fd %d: %w", fd, err)
+	}
+	return os.NewFile(uintptr(fd), fmt.Sprintf("fd%d", fd)), nil
+}
@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package main
+
+import "errors"
+
+// checkWritableFD refuses all descriptors where inherited fds can't be checked (Windows handles)
+func checkWritableFD(fd int) error {
+	return errors.New("not supported on this platform")
+}
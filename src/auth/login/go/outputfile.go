@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// octalMode is a flag.Value for permission bits given in octal, e.g. "077"
+type octalMode os.FileMode
+
+func (m *octalMode) String() string {
+	return fmt.Sprintf("%03o", uint32(*m))
+}
+
+func (m *octalMode) Set(value string) error {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0o777 {
+		return fmt.Errorf("invalid octal mode %q", value)
+	}
+	*m = octalMode(mode)
+	return nil
+}
+
+// Token file permissions, set from --umask, --file-mode, --file-owner and
+// --allow-insecure-perms. The defaults keep token files at 0600.
+var (
+	fileUmask          = octalMode(0o077)
+	fileMode           octalMode // 0 to derive the mode from fileUmask
+	fileOwner          string
+	allowInsecurePerms bool
+
+	// Resolved from fileOwner at startup, -1 leaves the ID unchanged
+	fileUID, fileGID = -1, -1
+)
+
+// tokenFileMode is the permission of written token files
+func tokenFileMode() os.FileMode {
+	if fileMode != 0 {
+		return os.FileMode(fileMode)
+	}
+	return 0o666 &^ os.FileMode(fileUmask)
+}
+
+// checkFilePerms rejects world-readable token files unless explicitly allowed,
+// and resolves --file-owner to the IDs to chown written files to
+func checkFilePerms() (uid, gid int, err error) {
+	if mode := tokenFileMode(); mode&0o004 != 0 && !allowInsecurePerms {
+		return -1, -1, fmt.Errorf("token files would be world-readable (mode %03o), set --allow-insecure-perms if that's intended", uint32(mode))
+	}
+	if fileOwner == "" {
+		return -1, -1, nil
+	}
+	if os.Geteuid() != 0 {
+		return -1, -1, errors.New("--file-owner requires running as root")
+	}
+	uid, gid, err = lookupOwner(fileOwner)
+	if err != nil {
+		return -1, -1, fmt.Errorf("invalid --file-owner %q: %w", fileOwner, err)
+	}
+	return uid, gid, nil
+}
+
+// lookupOwner resolves "user:group", "user" or ":group" (names or numeric IDs)
+func lookupOwner(owner string) (uid, gid int, err error) {
+	userName, groupName, _ := strings.Cut(owner, ":")
+	uid, gid = -1, -1
+	if userName != "" {
+		if uid, err = strconv.Atoi(userName); err != nil {
+			u, err := user.Lookup(userName)
+			if err != nil {
+				return 0, 0, err
+			}
+			uid, _ = strconv.Atoi(u.Uid)
+		}
+	}
+	if groupName != "" {
+		if gid, err = strconv.Atoi(groupName); err != nil {
+			g, err := user.LookupGroup(groupName)
+			if err != nil {
+				return 0, 0, err
+			}
+			gid, _ = strconv.Atoi(g.Gid)
+		}
+	}
+	if uid == -1 && gid == -1 {
+		return 0, 0, errors.New("expected user:group")
+	}
+	return uid, gid, nil
+}
+
+// writeFileAtomic writes data to a temp file in the same directory (with perm
+// and the --file-owner) and renames it into place, so readers never see a
+// partially written file. Writers also hold an advisory lock on a
+// ".<name>.lock" file next to it, so concurrent writers (e.g. --watch and a
+// manual --refresh) don't interleave.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir, name := filepath.Dir(path), filepath.Base(path)
+	unlock, err := lockFile(filepath.Join(dir, "."+name+".lock"))
+	if err != nil {
+		return fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	defer unlock()
+
+	tmp, err := os.CreateTemp(dir, "."+name+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if fileUID != -1 || fileGID != -1 {
+		if err := tmp.Chown(fileUID, fileGID); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/henrybear327/go-proton-api"
+)
+
+// auth2FAWithRecoveryCode submits a 2FA recovery code. Proton accepts them in
+// place of a TOTP code, each one only once.
+func auth2FAWithRecoveryCode(ctx context.Context, opts options, observer *responseObserver, client *proton.Client, code string) AuthResult {
+	err := auth2FA(ctx, opts, observer, client, proton.Auth2FAReq{TwoFactorCode: strings.TrimSpace(code)}, "method", "recovery-code")
+	if err == nil {
+		return AuthResult{}
+	}
+
+	var apiErr *proton.APIError
+	if !errors.As(err, &apiErr) || isRetryable(err) {
+		return failed(ctx, ErrTwoFactorFailed, "2FA failed", err)
+	}
+	if recoveryCodeUsed(apiErr) {
+		return withHTTPStatus(errorResult(ErrRecoveryCodeUsed, "Recovery code was already used: %v", err), err)
+	}
+	return withHTTPStatus(errorResult(ErrRecoveryCodeRejected, "Recovery code rejected: %v", err), err)
+}
+
+// recoveryCodeUsedMessage is how Proton rejects a recovery code that was
+// consumed before. There's no dedicated API code for it.
+const recoveryCodeUsedMessage = "Recovery code already used"
+
+// recoveryCodeUsed reports whether Proton rejected a recovery code because it
+// was consumed before. Other messages, e.g. for a mistyped code, don't count.
+func recoveryCodeUsed(apiErr *proton.APIError) bool {
+	return strings.EqualFold(strings.TrimSuffix(strings.TrimSpace(apiErr.Message), "."), recoveryCodeUsedMessage)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/henrybear327/go-proton-api"
+	"golang.org/x/term"
+)
+
+// refresh exchanges the refresh token of a stored AuthResult for a new
+// access token, without re-entering credentials.
+func refresh(ctx context.Context, inputPath string, opts options) AuthResult {
+	stored, err := loadStoredResult(inputPath, opts.keyring)
+	if err != nil {
+		return errorResult(ErrReadStoredTokens, "Failed to read stored tokens: %v", err)
+	}
+
+	// Sessions from env carry no key password, a supplied one fills it in
+	keyPassword, ok, err := suppliedKeyPassword(opts, term.IsTerminal(int(syscall.Stdin)))
+	if err != nil {
+		return errorResult(ErrInvalidOptions, "Invalid options: %v", err)
+	}
+	if ok {
+		stored.KeyPassword = string(keyPassword)
+	}
+	return newAuthProvider(opts).Refresh(ctx, stored)
+}
+
+// refreshTokens refreshes an already loaded AuthResult
+func refreshTokens(ctx context.Context, stored AuthResult, opts options) AuthResult {
+	manager, observer := newManager(opts)
+	defer manager.Close()
+
+	var client *proton.Client
+	var auth proton.Auth
+	err := withRetry(ctx, opts, observer, "NewClientWithRefresh", func() error {
+		start := time.Now()
+		var err error
+		client, auth, err = manager.NewClientWithRefresh(ctx, stored.UID, stored.RefreshToken)
+		logStep(ctx, "NewClientWithRefresh", start, err, "scope", auth.Scope)
+		return err
+	})
+	if err != nil {
+		if isRefreshTokenInvalid(err) {
+			return withHTTPStatus(errorResult(ErrRefreshTokenInvalid, "Refresh token expired or revoked, login required: %v", err), err)
+		}
+		return failed(ctx, ErrRefreshFailed, "Token refresh failed", err)
+	}
+	defer client.Close()
+
+	// The refresh response doesn't carry user info or the key password,
+	// so keep those from the stored result
+	userID := auth.UserID
+	if userID == "" {
+		userID = stored.UserID
+	}
+
+	expiresAt, ttlSource := observer.expiry()
+
+	return withClockSkew(AuthResult{
+		AccessToken:  auth.AccessToken,
+		RefreshToken: auth.RefreshToken,
+		UID:          auth.UID,
+		UserID:       userID,
+		KeyPassword:  stored.KeyPassword,
+		Keys:         stored.Keys,
+		KeyPasswords: stored.KeyPasswords,
+		ExpiresAt:    expiresAt,
+		TTLSource:    ttlSource,
+		Scope:        auth.Scope,
+		Rotation:     rotation(stored, auth),
+	}, observer)
+}
+
+// Rotation describes what changed between the stored and the refreshed session,
+// without exposing the tokens themselves
+type Rotation struct {
+	RefreshTokenRotated        bool     `json:"refreshTokenRotated"`
+	PreviousRefreshTokenSuffix string   `json:"previousRefreshTokenSuffix"`
+	ScopesAdded                []string `json:"scopesAdded,omitempty"`
+	ScopesRemoved              []string `json:"scopesRemoved,omitempty"`
+}
+
+// rotation compares the stored result with the refreshed session.
+// Scope changes are only reported if the stored result recorded its scope.
+func rotation(stored AuthResult, auth proton.Auth) *Rotation {
+	r := &Rotation{
+		RefreshTokenRotated:        auth.RefreshToken != stored.RefreshToken,
+		PreviousRefreshTokenSuffix: tokenSuffix(stored.RefreshToken),
+	}
+	if stored.Scope != "" {
+		oldScopes, newScopes := strings.Fields(stored.Scope), strings.Fields(auth.Scope)
+		for _, scope := range newScopes {
+			if !slices.Contains(oldScopes, scope) {
+				r.ScopesAdded = append(r.ScopesAdded, scope)
+			}
+		}
+		for _, scope := range oldScopes {
+			if !slices.Contains(newScopes, scope) {
+				r.ScopesRemoved = append(r.ScopesRemoved, scope)
+			}
+		}
+	}
+	if r.RefreshTokenRotated {
+		logger.Info("Refresh token rotated", "previousSuffix", r.PreviousRefreshTokenSuffix)
+	}
+	if len(r.ScopesAdded) > 0 || len(r.ScopesRemoved) > 0 {
+		logger.Info("Session scope changed", "added", r.ScopesAdded, "removed", r.ScopesRemoved)
+	}
+	return r
+}
+
+// tokenSuffix returns the last 6 characters of a token, enough to correlate it in logs
+func tokenSuffix(token string) string {
+	if len(token) <= 6 {
+		return ""
+	}
+	return token[len(token)-6:]
+}
+
+// Environment variables for a file-less --refresh, used when no input file is given
+const (
+	envRefreshToken = "PROTON_REFRESH_TOKEN"
+	envUID          = "PROTON_UID"
+)
+
+// loadStoredResult loads the tokens to refresh, trying the OS keyring first if enabled.
+// Without a path, the session in the environment is used if set, else stdin.
+func loadStoredResult(path string, useKeyring bool) (AuthResult, error) {
+	if useKeyring {
+		stored, err := loadFromKeyring()
+		if err == nil {
+			return stored, nil
+		}
+		logger.Warn("Failed to read tokens from OS keyring, falling back to file input", "error", err)
+	}
+	if path == "" {
+		if stored, ok, err := storedResultFromEnv(); ok {
+			return stored, err
+		}
+	}
+	return readStoredResult(path)
+}
+
+// storedResultFromEnv builds the session to refresh from PROTON_UID and
+// PROTON_REFRESH_TOKEN. ok is false if neither is set, and err is set if only one is.
+func storedResultFromEnv() (stored AuthResult, ok bool, err error) {
+	uid, refreshToken := os.Getenv(envUID), os.Getenv(envRefreshToken)
+	switch {
+	case uid == "" && refreshToken == "":
+		return AuthResult{}, false, nil
+	case uid == "":
+		return AuthResult{}, true, fmt.Errorf("%s is set but %s is missing", envRefreshToken, envUID)
+	case refreshToken == "":
+		return AuthResult{}, true, fmt.Errorf("%s is set but %s is missing", envUID, envRefreshToken)
+	}
+	return AuthResult{UID: uid, RefreshToken: refreshToken}, true, nil
+}
+
+// readStoredResult loads a previously written AuthResult from path,
+// or from stdin if path is empty.
+func readStoredResult(path string) (AuthResult, error) {
+	data, err := readPathOrStdin(path)
+	if err != nil {
+		return AuthResult{}, err
+	}
+	return parseStoredResult(data)
+}
+
+// readPathOrStdin reads the file at path, or all of stdin if path is empty
+func readPathOrStdin(path string) ([]byte, error) {
+	if path != "" {
+		return os.ReadFile(path)
+	}
+	return io.ReadAll(os.Stdin)
+}
+
+// parseStoredResult decodes a stored AuthResult and checks it can be refreshed
+func parseStoredResult(data []byte) (AuthResult, error) {
+	var stored AuthResult
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return AuthResult{}, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if stored.UID == "" || stored.RefreshToken == "" {
+		return AuthResult{}, errors.New("uid and refreshToken are required")
+	}
+	return stored, nil
+}
+
+// isRefreshTokenInvalid reports whether Proton rejected the refresh token itself
+// (expired or revoked), as opposed to a transient failure. Only its dedicated
+// code counts: other 400 and 422 errors aren't about the token.
+func isRefreshTokenInvalid(err error) bool {
+	var apiErr *proton.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == proton.AuthRefreshTokenInvalid
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/henrybear327/go-proton-api"
+)
+
+// Backoff bounds for retried API calls (go-proton-api's own retries are disabled)
+const (
+	retryBaseDelay = time.Second
+	retryMaxDelay  = 30 * time.Second
+)
+
+// rateLimitError is returned when Proton asks to wait longer than the remaining --timeout
+type rateLimitError struct {
+	wait time.Duration
+	err  error
+}
+
+func (e *rateLimitError) Error() string {
+	return fmt.Sprintf("rate limited, retry after %s: %v", e.wait, e.err)
+}
+
+func (e *rateLimitError) Unwrap() error {
+	return e.err
+}
+
+// withRetry runs fn, retrying up to --retries times with exponential backoff and
+// jitter on rate limiting, server and network errors. Anything else, like a
+// wrong password, fails immediately. The returned error notes the attempt count.
+// A Retry-After header recorded by observer replaces the backoff delay.
+func withRetry(ctx context.Context, opts options, observer *responseObserver, step string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		if err := rateLimitGate.wait(ctx); err != nil {
+			return err
+		}
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		retryAfter, hasRetryAfter := observer.takeRetryAfter()
+		if attempt > opts.retries || !isRetryable(err) {
+			return attemptsError(err, attempt)
+		}
+
+		delay := backoffDelay(attempt)
+		if hasRetryAfter {
+			if deadline, ok := ctx.Deadline(); ok && retryAfter > time.Until(deadline) {
+				return &rateLimitError{wait: retryAfter, err: attemptsError(err, attempt)}
+			}
+			delay = retryAfter
+		}
+		if isRateLimited(err) {
+			rateLimitGate.hold(delay)
+		}
+		logger.Warn("Request failed, retrying", "step", step, "attempt", attempt, "retryIn", delay, "error", err)
+		if !sleep(ctx, delay) {
+			return attemptsError(err, attempt)
+		}
+	}
+}
+
+// rateLimitGate is shared by all requests in the process, so once Proton rate
+// limits one batch worker the others back off too instead of piling on
+var rateLimitGate = &gate{}
+
+// gate blocks callers until a point in time
+type gate struct {
+	mu    sync.Mutex
+	until time.Time
+}
+
+// hold closes the gate for d, unless it's already closed for longer
+func (g *gate) hold(d time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if until := time.Now().Add(d); until.After(g.until) {
+		g.until = until
+	}
+}
+
+// wait blocks until the gate is open or ctx is done
+func (g *gate) wait(ctx context.Context) error {
+	g.mu.Lock()
+	wait := time.Until(g.until)
+	g.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	if !sleep(ctx, wait) {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// isRateLimited reports whether err is an HTTP 429 from Proton
+func isRateLimited(err error) bool {
+	var apiErr *proton.APIError
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusTooManyRequests
+}
+
+// retriedError notes how many attempts were made before giving up
+type retriedError struct {
+	attempts int
+	err      error
+}
+
+func (e *retriedError) Error() string {
+	return fmt.Sprintf("%v (after %d attempts)", e.err, e.attempts)
+}
+
+func (e *retriedError) Unwrap() error {
+	return e.err
+}
+
+// attemptsError adds the attempt count to err if it was retried
+func attemptsError(err error, attempts int) error {
+	if attempts == 1 {
+		return err
+	}
+	return &retriedError{attempts: attempts, err: err}
+}
+
+// retryAttempts returns the attempt count noted in err, 0 if it wasn't retried
+func retryAttempts(err error) int {
+	var retried *retriedError
+	if errors.As(err, &retried) {
+		return retried.attempts
+	}
+	return 0
+}
+
+// isRetryable reports whether err is transient: HTTP 429, 5xx or a network error
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, errPinMismatch) {
+		return false
+	}
+	if status, ok := apiStatus(err); ok {
+		return status == http.StatusTooManyRequests || status >= 500
+	}
+	return isNetworkError(err)
+}
+
+// apiStatus returns the HTTP status of an error response from Proton
+func apiStatus(err error) (int, bool) {
+	var apiErr *proton.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Status, true
+	}
+	return 0, false
+}
+
+// isNetworkError reports whether err happened before Proton answered:
+// DNS, connection and TLS failures
+func isNetworkError(err error) bool {
+	var netErr *proton.NetError
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var opErr net.Error
+	return errors.As(err, &opErr)
+}
+
+// backoffDelay is the wait before retry attempt+1: base * 2^(attempt-1) plus up to as much jitter, capped.
+// The shift is clamped too, so a high --retries can't overflow it.
+func backoffDelay(attempt int) time.Duration {
+	delay := min(retryBaseDelay<<min(attempt-1, 16), retryMaxDelay)
+	return min(delay+rand.N(delay), retryMaxDelay)
+}
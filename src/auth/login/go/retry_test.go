@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/henrybear327/go-proton-api"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{1, time.Second, 2 * time.Second},
+		{2, 2 * time.Second, 4 * time.Second},
+		{3, 4 * time.Second, 8 * time.Second},
+		{4, 8 * time.Second, 16 * time.Second},
+		{5, 16 * time.Second, retryMaxDelay},
+		{6, retryMaxDelay, retryMaxDelay},
+		{20, retryMaxDelay, retryMaxDelay},
+		{35, retryMaxDelay, retryMaxDelay},
+		{64, retryMaxDelay, retryMaxDelay},
+		{1000, retryMaxDelay, retryMaxDelay},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint("attempt ", tt.attempt), func(t *testing.T) {
+			// The jitter is random, so sample it
+			for range 100 {
+				if delay := backoffDelay(tt.attempt); delay < tt.min || delay > tt.max {
+					t.Fatalf("backoffDelay(%d) = %v, want between %v and %v", tt.attempt, delay, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value string
+		wait  time.Duration
+		ok    bool
+	}{
+		{"missing", "", 0, false},
+		{"seconds", "5", 5 * time.Second, true},
+		{"zero", "0", 0, true},
+		{"negative seconds", "-3", 0, true},
+		{"HTTP date", now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{"HTTP date in the past", now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"garbage", "soon", 0, false},
+		{"fractional seconds", "1.5", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, ok := parseRetryAfter(tt.value, now)
+			if wait != tt.wait || ok != tt.ok {
+				t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tt.value, wait, ok, tt.wait, tt.ok)
+			}
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	apiError := func(status int) error {
+		return &proton.APIError{Status: status, Message: http.StatusText(status)}
+	}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"rate limited", apiError(http.StatusTooManyRequests), true},
+		{"server error", apiError(http.StatusInternalServerError), true},
+		{"unavailable", apiError(http.StatusServiceUnavailable), true},
+		{"wrapped server error", fmt.Errorf("GetSalts: %w", apiError(http.StatusBadGateway)), true},
+		{"already retried", attemptsError(apiError(http.StatusServiceUnavailable), 3), true},
+		{"wrong password", apiError(http.StatusUnprocessableEntity), false},
+		{"bad request", apiError(http.StatusBadRequest), false},
+		{"unauthorized", apiError(http.StatusUnauthorized), false},
+		{"proton network error", &proton.NetError{Cause: errors.New("connection reset"), Message: "network error"}, true},
+		{"dial error", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{"DNS error", &net.DNSError{Err: "no such host", Name: "mail.proton.me"}, true},
+		{"cancelled", context.Canceled, false},
+		{"timed out", fmt.Errorf("auth: %w", context.DeadlineExceeded), false},
+		{"pin mismatch", &net.OpError{Op: "remote error", Err: errPinMismatch}, false},
+		{"other error", errors.New("unexpected"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.err); got != tt.want {
+				t.Errorf("isRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttemptsError(t *testing.T) {
+	err := errors.New("unavailable")
+	if got := attemptsError(err, 1); got != err || retryAttempts(got) != 0 {
+		t.Errorf("a single attempt should return err unchanged, got %v", got)
+	}
+	retried := attemptsError(err, 3)
+	if !errors.Is(retried, err) || retryAttempts(retried) != 3 {
+		t.Errorf("attemptsError(err, 3) = %v, want err after 3 attempts", retried)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// revoke ends the session of a stored AuthResult (read from inputPath or stdin)
+// server-side, so it disappears from the account's session list.
+// A --fork child can be revoked on its own by passing its object, e.g. `jq .fork`.
+func revoke(ctx context.Context, inputPath string, opts options) AuthResult {
+	stored, err := readRevokeInput(inputPath, opts.keyring)
+	if err != nil {
+		return errorResult(ErrReadStoredTokens, "Failed to read stored tokens: %v", err)
+	}
+
+	manager, observer := newManager(opts)
+	defer manager.Close()
+
+	// The client refreshes an expired access token on 401 if a refresh token is known
+	client := manager.NewClient(stored.UID, stored.AccessToken, stored.RefreshToken)
+	defer client.Close()
+
+	err = withRetry(ctx, opts, observer, "AuthDelete", func() error {
+		start := time.Now()
+		err := client.AuthDelete(ctx)
+		logStep(ctx, "AuthDelete", start, err)
+		return err
+	})
+	if err != nil {
+		return failed(ctx, ErrRevokeFailed, "Session revoke failed", err)
+	}
+	return AuthResult{UID: stored.UID, UserID: stored.UserID, Revoked: true}
+}
+
+// readRevokeInput loads the session to revoke. Unlike refresh, an access token
+// alone is enough.
+func readRevokeInput(path string, useKeyring bool) (AuthResult, error) {
+	if useKeyring {
+		stored, err := loadFromKeyring()
+		if err == nil {
+			return stored, nil
+		}
+		logger.Warn("Failed to read tokens from OS keyring, falling back to file input", "error", err)
+	}
+	data, err := readPathOrStdin(path)
+	if err != nil {
+		return AuthResult{}, err
+	}
+	var stored AuthResult
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return AuthResult{}, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if stored.UID == "" || (stored.AccessToken == "" && stored.RefreshToken == "") {
+		return AuthResult{}, errors.New("uid and accessToken or refreshToken are required")
+	}
+	return stored, nil
+}
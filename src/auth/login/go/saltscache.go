@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/henrybear327/go-proton-api"
+)
+
+// saltsMemCache keeps salts by user ID for the lifetime of the process, so a
+// batch fetches them once per account. Only logins use salts: refreshes
+// (--refresh, and the renewals of --watch and --serve) never hit this cache.
+var saltsMemCache = struct {
+	sync.Mutex
+	salts map[string]proton.Salts
+}{salts: map[string]proton.Salts{}}
+
+// cachedSalts returns cached salts for userID if they cover every key in keys.
+// The memory cache is tried first, then the --salts-cache file, whose entries
+// are encrypted with the login password. A missing key means the keys were
+// rotated, which counts as a miss.
+func cachedSalts(opts options, userID string, password []byte, keys proton.Keys) (proton.Salts, bool) {
+	saltsMemCache.Lock()
+	salts, ok := saltsMemCache.salts[userID]
+	saltsMemCache.Unlock()
+
+	if !ok && opts.saltsCache != "" {
+		var err error
+		salts, err = readSaltsCache(opts.saltsCache, userID, password)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				logger.Debug("Salts cache unusable", "path", opts.saltsCache, "error", err)
+			}
+			return nil, false
+		}
+	}
+	if salts == nil || !saltsCoverKeys(salts, keys) {
+		return nil, false
+	}
+	return salts, true
+}
+
+// storeSalts saves salts in memory and, with --salts-cache, on disk
+func storeSalts(opts options, userID string, password []byte, salts proton.Salts) {
+	saltsMemCache.Lock()
+	saltsMemCache.salts[userID] = salts
+	saltsMemCache.Unlock()
+
+	if opts.saltsCache == "" {
+		return
+	}
+	if err := writeSaltsCache(opts.saltsCache, userID, password, salts); err != nil {
+		logger.Warn("Failed to write salts cache", "path", opts.saltsCache, "error", err)
+	}
+}
+
+// saltsCoverKeys reports whether there's a salt for every key
+func saltsCoverKeys(salts proton.Salts, keys proton.Keys) bool {
+	ids := make(map[string]bool, len(salts))
+	for _, salt := range salts {
+		ids[salt.ID] = true
+	}
+	for _, key := range keys {
+		if !ids[key.ID] {
+			return false
+		}
+	}
+	return true
+}
+
+// readSaltsFile loads the cache file: armored, password-encrypted salts by user ID
+func readSaltsFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	entries := map[string]string{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func readSaltsCache(path, userID string, password []byte) (proton.Salts, error) {
+	entries, err := readSaltsFile(path)
+	if err != nil {
+		return nil, err
+	}
+	armored, ok := entries[userID]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	message, err := crypto.NewPGPMessageFromArmored(armored)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := crypto.DecryptMessageWithPassword(message, password)
+	if err != nil {
+		return nil, err
+	}
+	var salts proton.Salts
+	if err := json.Unmarshal(plain.GetBinary(), &salts); err != nil {
+		return nil, err
+	}
+	return salts, nil
+}
+
+// saltsFileMu serializes read-modify-write of the cache file between batch workers
+var saltsFileMu sync.Mutex
+
+func writeSaltsCache(path, userID string, password []byte, salts proton.Salts) error {
+	saltsFileMu.Lock()
+	defer saltsFileMu.Unlock()
+
+	entries, err := readSaltsFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		entries = map[string]string{}
+	}
+
+	plain, err := json.Marshal(salts)
+	if err != nil {
+		return err
+	}
+	message, err := crypto.EncryptMessageWithPassword(crypto.NewPlainMessage(plain), password)
+	wipe(plain)
+	if err != nil {
+		return err
+	}
+	armored, err := message.GetArmored()
+	if err != nil {
+		return err
+	}
+	entries[userID] = armored
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0600)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// authResultSchemaVersion is output as schemaVersion. Bump it when fields are
+// renamed, removed or change type. Added optional fields don't need a bump.
+// Keep SRP_AUTH_RESULT_SCHEMA_VERSION in types.ts in sync.
+const authResultSchemaVersion = 1
+
+// MarshalJSON sets schemaVersion on every output
+func (r AuthResult) MarshalJSON() ([]byte, error) {
+	type authResult AuthResult // without this method, so Marshal doesn't recurse
+	r.SchemaVersion = authResultSchemaVersion
+	return json.Marshal(authResult(r))
+}
+
+// MarshalJSON keeps the username next to the fields of the embedded AuthResult,
+// whose promoted MarshalJSON would otherwise replace them
+func (r accountResult) MarshalJSON() ([]byte, error) {
+	type authResult AuthResult
+	r.SchemaVersion = authResultSchemaVersion
+	return json.Marshal(struct {
+		Username string `json:"username"`
+		authResult
+	}{r.Username, authResult(r.AuthResult)})
+}
+
+// authResultSchema builds the JSON Schema of AuthResult from its fields and
+// JSON tags, so it can't drift from the output. Fields tagged omitempty are optional.
+func authResultSchema() map[string]any {
+	schema := typeSchema(reflect.TypeFor[AuthResult]())
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "AuthResult"
+	schema["schemaVersion"] = authResultSchemaVersion
+
+	properties := schema["properties"].(map[string]any)
+	properties["schemaVersion"] = map[string]any{"type": "integer", "const": authResultSchemaVersion}
+
+	codes := make([]int, 0, len(errorTypes))
+	names := make([]string, 0, len(errorTypes))
+	for code, name := range errorTypes {
+		codes = append(codes, int(code))
+		names = append(names, name)
+	}
+	slices.Sort(codes)
+	slices.Sort(names)
+	properties["errorCode"].(map[string]any)["enum"] = codes
+	properties["errorType"].(map[string]any)["enum"] = names
+	return schema
+}
+
+// typeSchema maps a Go type to its JSON Schema
+func typeSchema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]any{}
+		required := []string{}
+		for i := range t.NumField() {
+			field := t.Field(i)
+			name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" || !field.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = typeSchema(field.Type)
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		slices.Sort(required)
+		return map[string]any{"type": "object", "properties": properties, "required": required}
+	}
+	return map[string]any{}
+}
@@ -0,0 +1,20 @@
+package main
+
+// wipe overwrites a secret buffer with zeros once it's no longer needed,
+// so it doesn't linger in memory or core dumps
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// lockSecret keeps a secret buffer out of swap if --mlock is set.
+// Failure (e.g. RLIMIT_MEMLOCK too low) is not fatal.
+func lockSecret(opts options, b []byte) {
+	if !opts.mlock || len(b) == 0 {
+		return
+	}
+	if err := mlock(b); err != nil {
+		logger.Debug("mlock failed", "error", err)
+	}
+}
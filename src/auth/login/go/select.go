@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Actions offered for the account picked with --select
+const (
+	selectRefresh = "refresh"
+	selectInspect = "inspect"
+)
+
+var errSelectNeedsTerminal = errors.New("--select requires an interactive terminal")
+
+// storedAccount is a token file found by --select
+type storedAccount struct {
+	path   string
+	result AuthResult
+}
+
+// findAccounts lists the token files in dir: JSON files with a uid, in name order
+func findAccounts(dir string) ([]storedAccount, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var accounts []storedAccount
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			logger.Debug("Skipping unreadable file", "path", path, "error", err)
+			continue
+		}
+		var result AuthResult
+		if json.Unmarshal(data, &result) != nil || result.UID == "" {
+			continue
+		}
+		accounts = append(accounts, storedAccount{path: path, result: result})
+	}
+	if len(accounts) == 0 {
+		return nil, fmt.Errorf("no token files found in %s", dir)
+	}
+	return accounts, nil
+}
+
+// selectAccount lists the token files in dir on out and lets the user pick one
+// and an action. Returns the chosen file and selectRefresh or selectInspect.
+func selectAccount(dir string, in io.Reader, out io.Writer, now time.Time) (string, string, error) {
+	accounts, err := findAccounts(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for i, account := range accounts {
+		fmt.Fprintf(tw, "%d)\t%s\t%s\t%s\n", i+1, filepath.Base(account.path),
+			orNone(account.result.UserID), expiryStatus(account.result.ExpiresAt, now))
+	}
+	tw.Flush()
+
+	reader := bufio.NewReader(in)
+	var account storedAccount
+	for {
+		line, err := prompt(reader, out, fmt.Sprintf("Account [1-%d]: ", len(accounts)))
+		if err != nil {
+			return "", "", err
+		}
+		n, err := strconv.Atoi(line)
+		if err == nil && n >= 1 && n <= len(accounts) {
+			account = accounts[n-1]
+			break
+		}
+	}
+	for {
+		line, err := prompt(reader, out, "Action ([r]efresh, [i]nspect): ")
+		if err != nil {
+			return "", "", err
+		}
+		switch strings.ToLower(line) {
+		case "r", selectRefresh:
+			return account.path, selectRefresh, nil
+		case "i", selectInspect:
+			return account.path, selectInspect, nil
+		}
+	}
+}
+
+// prompt writes label and reads one trimmed line
+func prompt(reader *bufio.Reader, out io.Writer, label string) (string, error) {
+	fmt.Fprint(out, label)
+	line, err := reader.ReadString('\n')
+	if err != nil && (line == "" || !errors.Is(err, io.EOF)) {
+		return "", fmt.Errorf("failed to read selection: %w", err)
+	}
+	return strings.TrimSpace(line), nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// envServeToken can hold the --serve-token value, keeping it out of the process list
+const envServeToken = "PROTON_SERVE_TOKEN"
+
+var errServeNeedsToken = errors.New("--serve requires --serve-token or " + envServeToken)
+
+// serveAddress resolves the --serve address, binding to loopback if only a port is given
+func serveAddress(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// tokenServer hands out the current tokens over HTTP, refreshing them with
+// provider when they're within minTTL of expiry. Refreshes are serialized so a
+// refresh token is never spent twice.
+type tokenServer struct {
+	provider   AuthProvider
+	timeout    time.Duration
+	token      string
+	minTTL     time.Duration
+	outputPath string
+	// Keep the refresh token in memory only (--no-refresh-token)
+	noRefreshToken bool
+
+	mu      sync.Mutex
+	current AuthResult
+}
+
+// serve runs the token endpoint on addr until SIGINT/SIGTERM, starting from an
+// already authenticated result. Refreshed tokens are also written to outputPath if set.
+// timeout bounds each refresh.
+func serve(provider AuthProvider, timeout time.Duration, addr, token string, minTTL time.Duration, outputPath string, noRefreshToken bool, result AuthResult) AuthResult {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s := &tokenServer{provider: provider, timeout: timeout, token: token, minTTL: minTTL, outputPath: outputPath, noRefreshToken: noRefreshToken, current: result}
+	s.save(result)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errorResult(ErrServeFailed, "Failed to listen on %s: %v", addr, err)
+	}
+
+	srv := &http.Server{
+		Handler:           s.handler(ctx),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info("Serving tokens", "address", listener.Addr().String())
+	if err := srv.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return errorResult(ErrServeFailed, "Token server failed: %v", err)
+	}
+	logger.Info("Stopping token server")
+	return AuthResult{}
+}
+
+// handler routes the endpoints. Refreshes run on ctx rather than the request
+// context, so a client disconnecting can't abort a refresh halfway.
+func (s *tokenServer) handler(ctx context.Context) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		expiresAt := tokenExpiry(s.current)
+		s.mu.Unlock()
+		if time.Now().After(expiresAt) {
+			http.Error(w, "expired", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("GET /token", s.authorized(func(w http.ResponseWriter, r *http.Request) {
+		writeResult(w, s.public(s.tokens(ctx, false)))
+	}))
+	mux.HandleFunc("POST /refresh", s.authorized(func(w http.ResponseWriter, r *http.Request) {
+		writeResult(w, s.public(s.tokens(ctx, true)))
+	}))
+	return mux
+}
+
+// authorized rejects requests without the expected bearer token
+func (s *tokenServer) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// tokens returns the current tokens, refreshing first if they're near expiry
+// or force is set. If a due (but not forced) refresh fails while the current
+// access token is still valid, the current tokens are returned.
+func (s *tokenServer) tokens(ctx context.Context, force bool) AuthResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt := tokenExpiry(s.current)
+	if !force && time.Until(expiresAt) > s.minTTL {
+		return s.current
+	}
+
+	result := refreshWithTimeout(ctx, s.provider, s.current, s.timeout)
+	if result.Error != "" {
+		if !force && time.Now().Before(expiresAt) {
+			logger.Warn("Token refresh failed, serving current tokens", "error", result.Error, "expiresAt", s.current.ExpiresAt)
+			return s.current
+		}
+		return result
+	}
+
+	logger.Info("Tokens refreshed", "expiresAt", result.ExpiresAt)
+	s.current = result
+	s.save(result)
+	return result
+}
+
+// save writes result to the output file, if any. Failures are logged only,
+// the tokens are still served from memory. Dry run tokens are never written,
+// so they can't replace real ones.
+func (s *tokenServer) save(result AuthResult) {
+	if s.outputPath == "" || result.DryRun {
+		return
+	}
+	output, _ := json.MarshalIndent(s.public(result), "", "  ")
+	if err := writeFileAtomic(s.outputPath, output, tokenFileMode()); err != nil {
+		logger.Warn("Failed to write token file", "path", s.outputPath, "error", err)
+	}
+}
+
+// public returns result as handed out, without the refresh token if requested
+func (s *tokenServer) public(result AuthResult) AuthResult {
+	if s.noRefreshToken {
+		result.RefreshToken = ""
+	}
+	return result
+}
+
+// writeResult writes result as JSON, with 503 for error results
+func writeResult(w http.ResponseWriter, result AuthResult) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if result.Error != "" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SRP auth versions go-srp computes proofs for. go-proton-api uses whichever
+// version Proton negotiated, so only newer versions need a library update.
+const (
+	minSRPVersion = 0
+	maxSRPVersion = 4
+)
+
+// unsupportedSRPResult turns go-srp's "unsupported auth version" error, or a
+// negotiated version outside the supported range, into an actionable error
+func unsupportedSRPResult(err error, observer *responseObserver) (AuthResult, bool) {
+	version, seen := observer.negotiatedSRPVersion()
+	unsupported := seen && (version < minSRPVersion || version > maxSRPVersion)
+	if !unsupported && !strings.Contains(err.Error(), "unsupported auth version") {
+		return AuthResult{}, false
+	}
+	advertised := "an unknown version"
+	if seen {
+		advertised = fmt.Sprintf("version %d", version)
+	}
+	return errorResult(ErrSRPVersionUnsupported, "Proton requires SRP auth %s, but this build of proton-auth supports versions %d-%d: update go-proton-api (and go-srp) and rebuild", advertised, minSRPVersion, maxSRPVersion), true
+}
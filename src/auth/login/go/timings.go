@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// stepTimings collects the duration of each API step of a run for --timings,
+// summed over retries. Only step names and durations are kept.
+type stepTimings struct {
+	mu   sync.Mutex
+	took map[string]time.Duration
+}
+
+type timingsKey struct{}
+
+// withTimings returns a context that collects step durations into the returned stepTimings
+func withTimings(ctx context.Context) (context.Context, *stepTimings) {
+	t := &stepTimings{took: map[string]time.Duration{}}
+	return context.WithValue(ctx, timingsKey{}, t), t
+}
+
+// recordStep adds d to the duration of step, if ctx collects timings
+func recordStep(ctx context.Context, step string, d time.Duration) {
+	t, ok := ctx.Value(timingsKey{}).(*stepTimings)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	t.took[step] += d
+	t.mu.Unlock()
+}
+
+// millis returns the collected durations in milliseconds, as output in AuthResult.Timings
+func (t *stepTimings) millis() map[string]int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ms := make(map[string]int64, len(t.took))
+	for step, d := range t.took {
+		ms[step] = d.Milliseconds()
+	}
+	return ms
+}
+
+// timed runs fn, adding the durations of its steps to the result if opts.timings is set
+func timed(ctx context.Context, opts options, fn func(context.Context) AuthResult) AuthResult {
+	if !opts.timings {
+		return fn(ctx)
+	}
+	ctx, t := withTimings(ctx)
+	result := fn(ctx)
+	result.Timings = t.millis()
+	return result
+}
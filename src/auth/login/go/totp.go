@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/henrybear327/go-proton-api"
+)
+
+// envTOTPSecret can hold the base32 TOTP seed for --totp-secret
+const envTOTPSecret = "PROTON_TOTP_SECRET"
+
+// totpPeriod is the RFC 6238 time step Proton uses
+const totpPeriod = 30 * time.Second
+
+// totpSecret returns the TOTP seed from --totp-secret or env (non-interactive).
+// An explicitly given code always takes precedence, so ok is false then.
+func totpSecret(opts options, interactive bool) (string, bool) {
+	if _, ok := providedTOTP(opts, interactive); ok {
+		return "", false
+	}
+	if opts.totpSecret != "" {
+		return opts.totpSecret, true
+	}
+	return lookupEnv(interactive, envTOTPSecret)
+}
+
+// decodeTOTPSecret decodes a base32 seed as shown by authenticator apps,
+// ignoring case, spaces and missing padding
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(secret), " ", ""))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(key) == 0 {
+		return nil, errors.New("TOTP secret is not valid base32")
+	}
+	return key, nil
+}
+
+// totpCode computes the 6 digit RFC 6238 code (HMAC-SHA1) for key at t
+func totpCode(key []byte, t time.Time) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(t.Unix()/int64(totpPeriod.Seconds())))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1_000_000)
+}
+
+// maxTOTPRetries caps how often a rejected TOTP code is retried, so a run
+// never burns enough attempts to get the account locked out
+const maxTOTPRetries = 1
+
+// auth2FAWithSecret submits the code generated from the TOTP seed on Proton's
+// clock. If Proton rejects it, the code of the nearer adjacent time window is tried once.
+func auth2FAWithSecret(ctx context.Context, opts options, observer *responseObserver, client *proton.Client, secret string) error {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return err
+	}
+	defer wipe(key)
+
+	now := observer.serverNow()
+	for attempt, window := range []int{0, adjacentWindow(now)} {
+		code := totpCode(key, now.Add(time.Duration(window)*totpPeriod))
+		err = auth2FA(ctx, opts, observer, client, proton.Auth2FAReq{TwoFactorCode: code}, "method", "totp-secret", "window", window)
+		if err == nil || attempt >= maxTOTPRetries || !isInvalidTOTP(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// auth2FAWithTOTP submits a TOTP code given up front, or prompts for one. A
+// prompted code that Proton rejects is asked for again, up to maxTOTPRetries times.
+func auth2FAWithTOTP(ctx context.Context, opts options, observer *responseObserver, client *proton.Client, reader *bufio.Reader, totp string, prompt bool) AuthResult {
+	label := "2FA TOTP code: "
+	for attempt := 0; ; attempt++ {
+		if prompt {
+			fmt.Fprint(status, label)
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return errorResult(ErrTOTPRequired, "Failed to read TOTP")
+			}
+			totp = line
+		}
+
+		err := auth2FA(ctx, opts, observer, client, proton.Auth2FAReq{TwoFactorCode: strings.TrimSpace(totp)}, "method", "totp", "attempt", attempt+1)
+		if err == nil {
+			return AuthResult{}
+		}
+		if !prompt || attempt >= maxTOTPRetries || !isInvalidTOTP(err) {
+			return withClockSkew(failed(ctx, ErrTwoFactorFailed, "2FA failed", err), observer)
+		}
+		logger.Warn("TOTP code rejected, asking again", "error", err)
+		label = "Code rejected, try the next one: "
+	}
+}
+
+// adjacentWindow returns the time window next to now's that a code is more
+// likely to be off by: the previous one early in the period, the next one late
+func adjacentWindow(now time.Time) int {
+	if now.Unix()%int64(totpPeriod.Seconds()) < int64(totpPeriod.Seconds())/2 {
+		return -1
+	}
+	return 1
+}
+
+// isInvalidTOTP reports whether Proton rejected a 2FA code as wrong, the only
+// rejection worth another code. Lockouts and transient errors aren't.
+func isInvalidTOTP(err error) bool {
+	var apiErr *proton.APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusUnprocessableEntity {
+		return false
+	}
+	msg := strings.ToLower(apiErr.Message)
+	return !strings.Contains(msg, "too many") && !strings.Contains(msg, "locked")
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// errPinMismatch is returned by the TLS handshake when no certificate
+// in the chain matches a --pin-sha256 value
+var errPinMismatch = errors.New("certificate does not match any pinned SPKI hash")
+
+// newTransport builds the HTTP transport used for all Proton API calls.
+// Honors HTTP_PROXY/HTTPS_PROXY/NO_PROXY unless --proxy overrides them.
+func newTransport(opts options) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if opts.proxy != "" {
+		// Already checked by validate
+		proxyURL, _ := parseProxyURL(opts.proxy)
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if len(opts.pins) > 0 {
+		transport.TLSClientConfig = &tls.Config{
+			VerifyPeerCertificate: verifyPins(opts.pins),
+		}
+	}
+	return transport
+}
+
+// withUserAgent sets the User-Agent header on every request sent through rt,
+// independent of go-proton-api's X-PM-AppVersion handling. An empty userAgent
+// leaves requests untouched, so go-proton-api's default applies.
+func withUserAgent(rt http.RoundTripper, userAgent string) http.RoundTripper {
+	if userAgent == "" {
+		return rt
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", userAgent)
+		return rt.RoundTrip(req)
+	})
+}
+
+// verifyPins returns a TLS callback that accepts the connection only if a
+// certificate in the verified chain has one of the given SPKI SHA-256 hashes.
+// Runs during the handshake, so nothing is sent to an unpinned server.
+func verifyPins(pins []string) func([][]byte, [][]*x509.Certificate) error {
+	return func(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+		for _, chain := range verifiedChains {
+			for _, cert := range chain {
+				sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+				hash := base64.StdEncoding.EncodeToString(sum[:])
+				for _, pin := range pins {
+					if hash == pin {
+						return nil
+					}
+				}
+			}
+		}
+		return errPinMismatch
+	}
+}
+
+// validatePin checks that a --pin-sha256 value is a base64 SHA-256 hash
+func validatePin(pin string) error {
+	decoded, err := base64.StdEncoding.DecodeString(pin)
+	if err != nil || len(decoded) != sha256.Size {
+		return fmt.Errorf("invalid --pin-sha256 %q: expected base64 of a SHA-256 hash", pin)
+	}
+	return nil
+}
+
+// stringList is a flag.Value collecting repeated and comma-separated values
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// parseProxyURL parses a --proxy value (http, https, socks5 or socks5h)
+func parseProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --proxy %q: %w", raw, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid --proxy %q: scheme must be http, https, socks5 or socks5h", raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid --proxy %q: missing host", raw)
+	}
+	return u, nil
+}
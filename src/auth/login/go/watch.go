@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Backoff bounds for failed refreshes in watch mode
+const (
+	watchMinBackoff = 30 * time.Second
+	watchMaxBackoff = 30 * time.Minute
+)
+
+var errWatchNeedsOutput = errors.New("--watch requires -o with an existing token file")
+
+// watch keeps the token file at path fresh: whenever ExpiresAt is within minTTL,
+// the tokens are refreshed and the file rewritten. SIGHUP forces an immediate
+// refresh. Runs until SIGINT/SIGTERM, or until the refresh token is rejected
+// (login required).
+func watch(opts options, path string, minTTL time.Duration) AuthResult {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// SIGHUP is only received while waiting, on this goroutine, so a forced
+	// refresh never overlaps a scheduled one
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	stored, err := readStoredResult(path)
+	if err != nil {
+		return errorResult(ErrReadStoredTokens, "Failed to read stored tokens: %v", err)
+	}
+
+	provider := newAuthProvider(opts)
+	backoff := watchMinBackoff
+	refreshed := false
+	for {
+		wait := time.Until(tokenExpiry(stored)) - minTTL
+		if stored.NearExpiry {
+			// --min-ttl can't be kept with these tokens, refresh halfway to expiry instead
+			wait = time.Until(tokenExpiry(stored)) / 2
+		}
+		if refreshed {
+			// Tokens issued for less than --min-ttl would otherwise be
+			// refreshed back to back, rotating the refresh token each time
+			wait = max(wait, watchMinBackoff)
+		}
+		if wait > 0 {
+			logger.Info("Watching token file", "path", path, "expiresAt", stored.ExpiresAt, "nextRefresh", time.Now().Add(wait).UTC().Format(time.RFC3339))
+			if !sleepOrSignal(ctx, wait, hup) {
+				logger.Info("Stopping watch")
+				return stored
+			}
+		}
+
+		result := refreshWithTimeout(ctx, provider, stored, opts.timeout)
+		if ctx.Err() != nil {
+			logger.Info("Stopping watch")
+			return stored
+		}
+
+		switch {
+		case result.ErrorCode == ErrRefreshTokenInvalid:
+			// Retrying can't help, a full login is needed
+			return result
+		case result.Error != "":
+			logger.Warn("Token refresh failed, retrying", "error", result.Error, "retryIn", backoff)
+			if !sleepOrSignal(ctx, backoff, hup) {
+				logger.Info("Stopping watch")
+				return stored
+			}
+			backoff = min(backoff*2, watchMaxBackoff)
+			continue
+		}
+
+		markNearExpiry(&result, minTTL, time.Now(), "path", path)
+
+		// The old refresh token is now spent, so keep retrying the write
+		// rather than refreshing again
+		output, _ := json.MarshalIndent(result, "", "  ")
+		for {
+			err := writeFileAtomic(path, output, tokenFileMode())
+			if err == nil {
+				break
+			}
+			logger.Warn("Failed to write token file, retrying", "path", path, "error", err, "retryIn", backoff)
+			if !sleep(ctx, backoff) {
+				return result
+			}
+			backoff = min(backoff*2, watchMaxBackoff)
+		}
+
+		logger.Info("Tokens refreshed", "path", path, "expiresAt", result.ExpiresAt)
+		stored = result
+		backoff = watchMinBackoff
+		refreshed = true
+
+		// A SIGHUP that arrived during this refresh is already served by it
+		select {
+		case <-hup:
+		default:
+		}
+	}
+}
+
+// sleepOrSignal is sleep, but also returns (true) early on SIGHUP
+func sleepOrSignal(ctx context.Context, d time.Duration, hup <-chan os.Signal) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-hup:
+		logger.Info("SIGHUP received, refreshing now")
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// refreshWithTimeout refreshes with the --timeout bound applied to this attempt only
+func refreshWithTimeout(ctx context.Context, provider AuthProvider, stored AuthResult, timeout time.Duration) AuthResult {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return runSafely(func() AuthResult { return provider.Refresh(ctx, stored) })
+}
+
+// markNearExpiry flags fresh tokens that expire within minTTL, which happens
+// when Proton issues short-lived tokens, so consumers can refresh early.
+// Watch mode then refreshes halfway to expiry instead of minTTL before it.
+func markNearExpiry(result *AuthResult, minTTL time.Duration, now time.Time, attrs ...any) {
+	if result.Error != "" || result.AccessToken == "" || result.ExpiresAt == "" {
+		return
+	}
+	if left := tokenExpiry(*result).Sub(now); left < minTTL {
+		result.NearExpiry = true
+		logger.Warn("Tokens expire soon after issue, refresh early", append(attrs, "expiresAt", result.ExpiresAt, "expiresIn", left.Round(time.Second), "minTTL", minTTL)...)
+	}
+}
+
+// tokenExpiry parses ExpiresAt, treating a missing or malformed value as expired
+func tokenExpiry(result AuthResult) time.Time {
+	expiresAt, err := time.Parse(time.RFC3339, result.ExpiresAt)
+	if err != nil {
+		return time.Time{}
+	}
+	return expiresAt
+}
+
+// sleep waits for d, returning false if ctx is cancelled first
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,75 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// startDryRunWatch runs watch on an expired dry-run token file and waits for
+// its first refresh. The returned func stops watch and returns its result.
+func startDryRunWatch(t *testing.T, minTTL time.Duration) (path string, stop func() AuthResult) {
+	t.Helper()
+	opts := dryRunOptions(t)
+	path = writeStoredTokens(t, &opts)
+
+	done := make(chan AuthResult, 1)
+	go func() { done <- watch(opts, path, minTTL) }()
+
+	// The expired tokens are refreshed right away, by fakeProvider
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stored, err := readStoredResult(path)
+		if err == nil && stored.DryRun {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("watch didn't write dry-run tokens, it's using the real provider")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return path, func() AuthResult {
+		// watch catches SIGTERM itself and stops
+		if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case result := <-done:
+			return result
+		case <-time.After(5 * time.Second):
+			t.Fatal("watch didn't stop on SIGTERM")
+			return AuthResult{}
+		}
+	}
+}
+
+func TestWatchDryRun(t *testing.T) {
+	_, stop := startDryRunWatch(t, time.Minute)
+
+	if result := stop(); !strings.HasPrefix(result.AccessToken, "dry-run-access-") {
+		t.Errorf("AccessToken = %q, want one from fakeProvider", result.AccessToken)
+	}
+}
+
+func TestWatchShortLivedTokens(t *testing.T) {
+	// fakeProvider tokens last an hour, so they're within --min-ttl right away
+	path, stop := startDryRunWatch(t, 2*fakeTokenTTL)
+	time.Sleep(200 * time.Millisecond)
+	result := stop()
+
+	stored, err := readStoredResult(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.AccessToken != "dry-run-access-1" || result.AccessToken != "dry-run-access-1" {
+		t.Errorf("refreshed again right away, up to %q", result.AccessToken)
+	}
+	if !stored.NearExpiry {
+		t.Error("nearExpiry not set in the token file")
+	}
+}
@@ -5,7 +5,7 @@
 import { spawn } from 'child_process';
 import { existsSync } from 'fs';
 import { authConfig } from '../../app/config.js';
-import type { SRPAuthResult } from './types.js';
+import { SRP_AUTH_RESULT_SCHEMA_VERSION, type SRPAuthResult } from './types.js';
 
 /**
  * Run the proton-auth Go binary to perform SRP authentication.
@@ -78,6 +78,15 @@ export async function runProtonAuth(
 
                 const result = JSON.parse(stdout) as SRPAuthResult;
 
+                // A newer version renamed or removed fields we rely on
+                if ((result.schemaVersion ?? 1) > SRP_AUTH_RESULT_SCHEMA_VERSION) {
+                    reject(new Error(
+                        `proton-auth output has schemaVersion ${result.schemaVersion}, ` +
+                        `expected ${SRP_AUTH_RESULT_SCHEMA_VERSION}. Rebuild it with: npm run build:login`
+                    ));
+                    return;
+                }
+
                 if (result.error) {
                     reject(new Error(`Authentication failed: ${result.error}`));
                     return;
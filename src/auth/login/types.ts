@@ -2,16 +2,73 @@
  * Types for go-proton-api authentication
  */
 
+// Highest schemaVersion of SRPAuthResult this code understands
+export const SRP_AUTH_RESULT_SCHEMA_VERSION = 1;
+
 // Output from the Go binary
 export interface SRPAuthResult {
+    // Output format version, see proton-auth --print-schema
+    schemaVersion?: number;
     accessToken: string;
     refreshToken: string;
     uid: string;
     userID: string;
     keyPassword: string;
     expiresAt?: string;
+    // 'server' if expiresAt comes from Proton's token lifetime, 'default' if estimated
+    ttlSource?: 'server' | 'default';
+    // Set when expiresAt is already within --min-ttl (short-lived tokens)
+    nearExpiry?: boolean;
+    // Proton's clock minus the local one, in seconds
+    clockSkewSeconds?: number;
+    // Space separated session scopes
+    scope?: string;
+    // Set by --refresh: what changed compared to the stored session
+    rotation?: {
+        refreshTokenRotated: boolean;
+        previousRefreshTokenSuffix: string;
+        scopesAdded?: string[];
+        scopesRemoved?: string[];
+    };
     error?: string;
     errorCode?: number;
+    // Stable identifier for errorCode, e.g. 'auth_failed' (see go/errors.go)
+    errorType?: string;
+    // Status of Proton's error response, if it answered
+    httpStatus?: number;
+    // Requests made by the failed step, set if it was retried (see --retries)
+    attempts?: number;
+    // All user keys, keyPassword above is for the primary key only
+    keys?: {
+        id: string;
+        fingerprint: string;
+        version: number;
+        primary: boolean;
+        active: boolean;
+        keyPassword: string;
+    }[];
+    // Same key passwords, by key ID
+    keyPasswords?: Record<string, {
+        keyPassword: string;
+        version: number;
+        primary: boolean;
+    }>;
+    // Child session from --fork, revocable without ending the main session
+    fork?: {
+        selector: string;
+        uid: string;
+        accessToken: string;
+        refreshToken: string;
+        expiresAt: string;
+    };
+    // Set by --revoke once the session is logged out
+    revoked?: boolean;
+    // Set with errorCode 1018 when Proton requires a CAPTCHA or other verification
+    humanVerification?: {
+        methods: string[];
+        token: string;
+        url: string;
+    };
 }
 
 // Configuration for SRP authentication
@@ -6,9 +6,11 @@
  * - On-demand refresh (on 401 errors)
  * - Token refresh for all auth methods (all use /auth/refresh endpoint)
  * - Logout with session revocation
+ * - Readiness checks (token expiry, failing refreshes)
  */
 
 import { logger } from '../app/logger.js';
+import { getMetrics } from '../app/metrics.js';
 import { createProtonApi, type ProtonApiWithRefresh } from './api-factory.js';
 import { logout as performLogout } from './logout.js';
 import type { IAuthProvider, ProtonApi } from './types.js';
@@ -29,13 +31,22 @@ export interface AuthManagerOptions {
     };
 }
 
+export interface Readiness {
+    ready: boolean;
+    /** Why the server can't serve requests, when not ready */
+    reason?: string;
+}
+
 export class AuthManager {
     private provider: IAuthProvider;
     private vaultPath: string;
     private autoRefreshConfig: NonNullable<AuthManagerOptions['autoRefresh']>;
     private refreshTimer?: NodeJS.Timeout;
     private protonApi?: ProtonApiWithRefresh;
-    private isRefreshing = false;
+    /** In-flight refresh, shared by concurrent callers so only one runs at a time */
+    private refreshInFlight?: Promise<void>;
+    /** Error of the last refresh, cleared by a successful one */
+    private lastRefreshError?: string;
 
     constructor(options: AuthManagerOptions) {
         this.provider = options.provider;
@@ -117,35 +128,49 @@ export class AuthManager {
      * Refresh tokens immediately
      *
      * All auth methods now use provider.refresh() which calls /auth/refresh endpoint.
+     * Concurrent calls (e.g. several requests getting a 401 at once) wait for the
+     * same refresh instead of spending the refresh token twice.
      */
     async refreshNow(): Promise<void> {
-        if (this.isRefreshing) {
-            logger.debug('Refresh already in progress, skipping');
-            return;
+        if (this.refreshInFlight) {
+            logger.debug('Refresh already in progress, waiting for it');
+            return this.refreshInFlight;
         }
 
-        this.isRefreshing = true;
+        this.refreshInFlight = this.doRefresh();
         try {
-            logger.info({ method: this.provider.method }, 'Refreshing tokens...');
+            await this.refreshInFlight;
+        } finally {
+            this.refreshInFlight = undefined;
+        }
+    }
 
+    private async doRefresh(): Promise<void> {
+        logger.info({ method: this.provider.method }, 'Refreshing tokens...');
+
+        try {
             if (this.provider.refresh) {
                 await this.provider.refresh();
             } else {
                 throw new Error(`No refresh method available for ${this.provider.method}`);
             }
+            this.lastRefreshError = undefined;
+            getMetrics()?.tokenRefreshesTotal.inc({ status: 'success' });
+        } catch (error) {
+            this.lastRefreshError = error instanceof Error ? error.message : String(error);
+            getMetrics()?.tokenRefreshesTotal.inc({ status: 'failure' });
+            throw error;
+        }
 
-            // Update the API's credentials if we have one
-            if (this.protonApi?.updateCredentials) {
-                this.protonApi.updateCredentials(
-                    this.provider.getUid(),
-                    this.getAccessToken()
-                );
-            }
-
-            logger.info({ method: this.provider.method }, 'Token refresh complete');
-        } finally {
-            this.isRefreshing = false;
+        // Update the API's credentials if we have one
+        if (this.protonApi?.updateCredentials) {
+            this.protonApi.updateCredentials(
+                this.provider.getUid(),
+                this.getAccessToken()
+            );
         }
+
+        logger.info({ method: this.provider.method }, 'Token refresh complete');
     }
 
     /**
@@ -164,6 +189,29 @@ export class AuthManager {
         }
     }
 
+    /**
+     * Check whether requests can be served: the access token is not expired and
+     * refreshing works. Optionally pings Proton with the token (core/v4/users).
+     */
+    async checkReadiness(ping = false): Promise<Readiness> {
+        if (!this.provider.isValid()) {
+            return { ready: false, reason: 'Proton access token expired' };
+        }
+        if (this.lastRefreshError) {
+            return { ready: false, reason: `Token refresh failing: ${this.lastRefreshError}` };
+        }
+        if (ping) {
+            try {
+                const api = this.protonApi ?? this.provider.createApi();
+                await api({ url: 'core/v4/users', method: 'get' });
+            } catch (error) {
+                const message = error instanceof Error ? error.message : String(error);
+                return { ready: false, reason: `Proton API ping failed: ${message}` };
+            }
+        }
+        return { ready: true };
+    }
+
     /**
      * Get current access token from provider
      */
@@ -29,7 +29,7 @@ import type {
     MessageId,
     SpaceId,
 } from '../types.js';
-import { getLogConfig } from '../../app/config.js';
+import { getConversationsConfig, getLogConfig } from '../../app/config.js';
 import { getMetrics } from '../../app/metrics.js';
 
 /** Max conversations to keep in memory (LRU eviction) */
@@ -44,6 +44,7 @@ const MAX_CONVERSATIONS = 100;
 export class FallbackStore {
     private conversations = new Map<ConversationId, ConversationState>();
     private accessOrder: ConversationId[] = [];  // LRU tracking
+    private lastAccessAt = new Map<ConversationId, number>();  // Idle TTL tracking
     private maxConversations = MAX_CONVERSATIONS;
     private defaultSpaceId: SpaceId;
     private onDirtyCallback?: () => void;
@@ -75,6 +76,7 @@ export class FallbackStore {
         }
 
         this.touchLRU(id);
+        this.evictIdle();
         this.evictIfNeeded();
 
         return state;
@@ -379,6 +381,7 @@ export class FallbackStore {
         const existed = this.conversations.delete(id);
         if (existed) {
             this.accessOrder = this.accessOrder.filter(cid => cid !== id);
+            this.lastAccessAt.delete(id);
             logger.debug({ conversationId: id }, 'Deleted conversation');
         }
         return existed;
@@ -469,6 +472,27 @@ export class FallbackStore {
         }
         // Add to end (most recently used)
         this.accessOrder.push(id);
+        this.lastAccessAt.set(id, Date.now());
+    }
+
+    /**
+     * Evict conversations not accessed within conversations.idleTtlMinutes.
+     * With sync enabled, dirty conversations are kept until they're synced.
+     */
+    private evictIdle(): void {
+        const { idleTtlMinutes, enableSync } = getConversationsConfig();
+        if (idleTtlMinutes <= 0) return;
+
+        const cutoff = Date.now() - idleTtlMinutes * 60_000;
+        for (const id of [...this.accessOrder]) {
+            // accessOrder is sorted by last access, so the rest is more recent
+            if ((this.lastAccessAt.get(id) ?? 0) > cutoff) break;
+            if (enableSync && this.conversations.get(id)?.dirty) continue;
+            this.conversations.delete(id);
+            this.accessOrder.splice(this.accessOrder.indexOf(id), 1);
+            this.lastAccessAt.delete(id);
+            logger.debug({ conversationId: id }, 'Evicted idle conversation');
+        }
     }
 
     private evictIfNeeded(): void {
@@ -490,12 +514,14 @@ export class FallbackStore {
                         const forced = this.accessOrder.shift();
                         if (forced) {
                             this.conversations.delete(forced);
+                            this.lastAccessAt.delete(forced);
                             logger.warn({ conversationId: forced }, 'Force-evicted dirty conversation');
                         }
                         break;
                     }
                 } else {
                     this.conversations.delete(toEvict);
+                    this.lastAccessAt.delete(toEvict);
                     logger.debug({ conversationId: toEvict }, 'Evicted conversation from cache');
                 }
             }
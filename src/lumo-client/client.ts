@@ -3,7 +3,8 @@
  * Minimal implementation with U2L encryption support
  */
 
-import { decryptString } from '@lumo/crypto/index.js';
+import { setTimeout as sleep } from 'timers/promises';
+import { decryptUint8Array } from '@lumo/crypto/index.js';
 import {
     DEFAULT_LUMO_PUB_KEY,
     encryptTurns,
@@ -15,6 +16,8 @@ import {
 } from '@lumo/lib/lumo-api-client/core/encryptionParams.js';
 import { StreamProcessor } from '@lumo/lib/lumo-api-client/core/streaming.js';
 import { logger } from '../app/logger.js';
+import { Utf8StreamDecoder } from './utf8-stream-decoder.js';
+import { InlineReasoningFilter } from './inline-reasoning-filter.js';
 import {
     Role,
     type AesGcmCryptoKey,
@@ -28,19 +31,58 @@ import {
     type AssistantMessageData,
     type LumoClientOptions,
     type ChatResult,
+    type TokenUsage,
 } from './types.js';
-import { getInstructionsConfig, getLogConfig, getConfigMode, getCustomToolsConfig, getEnableWebSearch } from '../app/config.js';
+import { getInstructionsConfig, getLogConfig, getConfigMode, getCustomToolsConfig, getEnableWebSearch, getRetryConfig } from '../app/config.js';
 import { injectInstructionsIntoTurns } from './instructions.js';
 import { NativeToolCallProcessor } from '../api/tools/native-tool-call-processor.js';
 import { postProcessTitle } from '@lumo/lib/lumo-api-client/utils.js';
 
 // Re-export types for external consumers
-export type { LumoClientOptions, ChatResult };
+export type { LumoClientOptions, ChatResult, TokenUsage };
 
 const DEFAULT_INTERNAL_TOOLS: ToolName[] = ['proton_info'];
 const DEFAULT_EXTERNAL_TOOLS: ToolName[] = ['web_search', 'weather', 'stock', 'cryptocurrency'];
 const DEFAULT_ENDPOINT = 'ai/v1/chat';
 
+/** Network errors worth retrying (Node fetch puts them on error.cause) */
+const RETRYABLE_ERROR_CODES = new Set(['ECONNRESET', 'ECONNREFUSED', 'ETIMEDOUT', 'EPIPE', 'UND_ERR_SOCKET']);
+
+/** Whether a failed Lumo request is transient: a 5xx response or a dropped connection. */
+function isRetryableError(error: unknown): boolean {
+    const err = error as { status?: number; code?: string; cause?: { code?: string } } | undefined;
+    if (typeof err?.status === 'number') return err.status >= 500;
+    const code = err?.code ?? err?.cause?.code;
+    return code !== undefined && RETRYABLE_ERROR_CODES.has(code);
+}
+
+/** Rough token estimate for when Lumo doesn't report usage, ~4 characters per token */
+function estimateTokens(text: string): number {
+    return Math.ceil(text.length / 4);
+}
+
+/**
+ * Read token counts from a message's `usage` field, if Lumo sent one.
+ * Accepts both OpenAI naming styles, as the field is undocumented.
+ */
+function parseUsage(msg: unknown): Omit<TokenUsage, 'estimated'> | undefined {
+    const usage = (msg as { usage?: Record<string, unknown> }).usage;
+    if (typeof usage !== 'object' || usage === null) return undefined;
+    const promptTokens = usage.prompt_tokens ?? usage.input_tokens;
+    const completionTokens = usage.completion_tokens ?? usage.output_tokens;
+    if (typeof promptTokens !== 'number' || typeof completionTokens !== 'number') return undefined;
+    return { promptTokens, completionTokens };
+}
+
+function addUsage(a: TokenUsage, b?: TokenUsage): TokenUsage {
+    if (!b) return a;
+    return {
+        promptTokens: a.promptTokens + b.promptTokens,
+        completionTokens: a.completionTokens + b.completionTokens,
+        estimated: a.estimated || b.estimated,
+    };
+}
+
 /** Build the bounce instruction: config text + the misrouted tool call as JSON example.
  *  Includes the prefix in the example JSON so Lumo outputs it correctly. */
 function buildBounceInstruction(toolCall: ParsedToolCall): string {
@@ -99,19 +141,69 @@ export class LumoClient {
         },
         /** When true, ignore misrouted tool calls (they're stale leftovers in bounce responses). */
         isBounce = false,
+        streamOptions: Pick<LumoClientOptions, 'signal' | 'idleTimeoutMs' | 'truncationNote' | 'waitForConsumer' | 'onReasoning' | 'reasoningMarkers'> = {},
     ): Promise<ChatResult> {
+        const { signal, idleTimeoutMs, truncationNote, waitForConsumer, onReasoning, reasoningMarkers } = streamOptions;
         const reader = stream.getReader();
+        // Stop reading when aborted, also for streams that don't watch the signal themselves
+        const cancelReader = () => { reader.cancel(signal?.reason).catch(() => { }); };
+        signal?.addEventListener('abort', cancelReader, { once: true });
         const decoder = new TextDecoder('utf-8');
         const processor = new StreamProcessor();
         let fullResponse = '';
         let fullTitle = '';
+        let reportedUsage: Omit<TokenUsage, 'estimated'> | undefined;
 
         // Native tool call processing (SSE tool_call/tool_result targets)
         const nativeToolProcessor = new NativeToolCallProcessor(isBounce);
         let suppressChunks = false;
         let abortEarly = false;
+        let truncated = false;
+
+        // Decrypted chunks are bytes, a character may continue in the next chunk
+        const utf8 = new Utf8StreamDecoder();
+        // Reasoning written into the message itself, between markers
+        const inlineReasoning = reasoningMarkers?.length ? new InlineReasoningFilter(reasoningMarkers) : undefined;
+
+        const emitMessage = (text: string) => {
+            if (!text) return;
+            fullResponse += text;
+            if (!suppressChunks) {
+                onChunk?.(text);
+            }
+        };
+        // Kept out of the response, the caller decides what to do with it
+        const emitReasoning = (text: string) => {
+            if (text) onReasoning?.(text);
+        };
+
+        const handleContent = (target: string, content: string) => {
+            if (!content) return;
+            if (target === 'message') {
+                if (inlineReasoning) {
+                    const { text, reasoning } = inlineReasoning.push(content);
+                    emitReasoning(reasoning);
+                    emitMessage(text);
+                } else {
+                    emitMessage(content);
+                }
+            } else if (target === 'title') {
+                // Accumulate title chunks (title streams before message)
+                fullTitle += content;
+            } else if (target === 'tool_call') {
+                if (nativeToolProcessor.feedToolCall(content)) {
+                    suppressChunks = true;
+                    abortEarly = true;
+                }
+            } else if (target === 'tool_result') {
+                nativeToolProcessor.feedToolResult(content);
+            } else if (target === 'reasoning') {
+                emitReasoning(content);
+            }
+        };
 
         const processMessage = async (msg: GenerationResponseMessage) => {
+            reportedUsage = parseUsage(msg) ?? reportedUsage;
             if (msg.type === 'token_data') {
                 let content = msg.content;
 
@@ -124,33 +216,19 @@ export class LumoClient {
                 ) {
                     const adString = `lumo.response.${encryptionContext.requestId}.chunk`;
                     try {
-                        content = await decryptString(
+                        const bytes = await decryptUint8Array(
                             content,
                             encryptionContext.requestKey,
                             adString
                         );
+                        content = utf8.decode(msg.target, bytes);
                     } catch (error) {
                         logger.error(error, 'Failed to decrypt chunk:');
                         // Continue with encrypted content
                     }
                 }
 
-                if (msg.target === 'message') {
-                    fullResponse += content;
-                    if (!suppressChunks) {
-                        onChunk?.(content);
-                    }
-                } else if (msg.target === 'title') {
-                    // Accumulate title chunks (title streams before message)
-                    fullTitle += content;
-                } else if (msg.target === 'tool_call') {
-                    if (nativeToolProcessor.feedToolCall(content)) {
-                        suppressChunks = true;
-                        abortEarly = true;
-                    }
-                } else if (msg.target === 'tool_result') {
-                    nativeToolProcessor.feedToolResult(content);
-                }
+                handleContent(msg.target, content);
             } else if (
                 msg.type === 'error' ||
                 msg.type === 'rejected' ||
@@ -162,9 +240,36 @@ export class LumoClient {
             }
         };
 
+        // Read the next chunk, or 'timeout' when the stream stalls for idleTimeoutMs
+        const read = async (): Promise<ReadableStreamReadResult<Uint8Array> | 'timeout'> => {
+            if (!idleTimeoutMs) return reader.read();
+            const pending = reader.read();
+            pending.catch(() => { }); // Settles after the timeout won, when the reader is cancelled
+            let timer: NodeJS.Timeout | undefined;
+            const timeout = new Promise<'timeout'>(resolve => {
+                timer = setTimeout(() => resolve('timeout'), idleTimeoutMs);
+            });
+            try {
+                return await Promise.race([pending, timeout]);
+            } finally {
+                clearTimeout(timer);
+            }
+        };
+
         try {
             while (true) {
-                const { done, value } = await reader.read();
+                // Backpressure: time spent waiting for the consumer doesn't count as a stall
+                await waitForConsumer?.();
+                signal?.throwIfAborted();
+                const readResult = await read();
+                if (readResult === 'timeout') {
+                    logger.warn({ idleTimeoutMs, receivedChars: fullResponse.length }, 'Lumo stream stalled, closing with partial response');
+                    truncated = true;
+                    reader.cancel().catch(() => { });
+                    break;
+                }
+
+                const { done, value } = readResult;
                 if (done) break;
 
                 const chunk = decoder.decode(value, { stream: true });
@@ -175,12 +280,24 @@ export class LumoClient {
                 }
                 if (abortEarly) break;
             }
+            signal?.throwIfAborted();
 
             // Process any remaining data
             const finalMessages = processor.finalize();
             for (const msg of finalMessages) {
                 await processMessage(msg);
             }
+            for (const [target, content] of utf8.flush()) {
+                handleContent(target, content);
+            }
+            if (inlineReasoning) {
+                const { text, reasoning } = inlineReasoning.flush();
+                emitReasoning(reasoning);
+                emitMessage(text);
+            }
+            if (truncated && truncationNote) {
+                emitMessage(truncationNote);
+            }
 
             // Finalize tracking and get result
             nativeToolProcessor.finalize();
@@ -204,10 +321,13 @@ export class LumoClient {
                 title: fullTitle || undefined,
                 nativeToolCallFailed: nativeResult.toolCall ? nativeResult.failed : undefined,
                 misrouted: nativeResult.misrouted,
+                truncated: truncated || undefined,
+                usage: reportedUsage && { ...reportedUsage, estimated: false },
                 // Keep parsed tool call for bounce handling (internal use only)
                 _nativeToolCallForBounce: nativeResult.misrouted ? nativeResult.toolCall : undefined,
             };
         } finally {
+            signal?.removeEventListener('abort', cancelReader);
             reader.releaseLock();
         }
     }
@@ -230,7 +350,20 @@ export class LumoClient {
             requestTitle = false,
             instructions,
             injectInstructionsInto = 'first',
+            model,
+            temperature,
+            topP,
+            maxTokens,
+            stop,
+            signal,
+            idleTimeoutMs,
+            truncationNote,
+            waitForConsumer,
+            onReasoning,
+            reasoningMarkers,
+            enableWebSearch = getEnableWebSearch(),
         } = options;
+        signal?.throwIfAborted();
 
         const turn = turns[turns.length - 1];
         const logConfig = getLogConfig();
@@ -242,8 +375,8 @@ export class LumoClient {
                 } `);
         }
 
-        // Read from config - applies to both server and CLI modes
-        const tools: ToolName[] = getEnableWebSearch()
+        // Per request, else from config - applies to both server and CLI modes
+        const tools: ToolName[] = enableWebSearch
             ? [...DEFAULT_INTERNAL_TOOLS, ...DEFAULT_EXTERNAL_TOOLS]
             : DEFAULT_INTERNAL_TOOLS;
 
@@ -269,11 +402,23 @@ export class LumoClient {
         // See WebClients client.ts:110: targets = requestTitle ? ['title', 'message'] : ['message']
         const targets: Array<'title' | 'message'> = requestTitle ? ['title', 'message'] : ['message'];
 
-        const request: LumoApiGenerationRequest = {
+        // The upstream request type has no model, sampling, length and stop fields yet, they're sent alongside the documented ones
+        const request: LumoApiGenerationRequest & {
+            model?: string;
+            temperature?: number;
+            top_p?: number;
+            max_tokens?: number;
+            stop?: string[];
+        } = {
             type: 'generation_request',
             turns: processedTurns,
             options: { tools },
             targets,
+            ...(model ? { model } : {}),
+            ...(temperature !== undefined ? { temperature } : {}),
+            ...(topP !== undefined ? { top_p: topP } : {}),
+            ...(maxTokens !== undefined ? { max_tokens: maxTokens } : {}),
+            ...(stop?.length ? { stop } : {}),
             ...(enableEncryption && requestKeyEncB64 && encryptionParams
                 ? {
                     request_key: requestKeyEncB64,
@@ -284,18 +429,105 @@ export class LumoClient {
 
         const payload = { Prompt: request };
 
-        const stream = (await this.protonApi({
-            url: endpoint,
-            method: 'post',
-            data: payload,
-            output: 'stream',
-        })) as ReadableStream<Uint8Array>;
+        // Retry transient failures, but only while nothing was streamed to the caller yet,
+        // a retry would otherwise repeat the output
+        const { maxRetries, baseDelayMs, streamFallback } = getRetryConfig();
+        let streamed = false;
+        const trackedOnChunk = onChunk && ((content: string) => {
+            streamed = true;
+            onChunk(content);
+        });
+        const trackedOnReasoning = onReasoning && ((content: string) => {
+            streamed = true;
+            onReasoning(content);
+        });
+
+        // Non-streaming fallback: Lumo only streams, so the request is re-sent and its
+        // output buffered, then passed on as a single chunk once complete
+        let buffered = false;
+
+        let result: ChatResult;
+        for (let attempt = 1; ; attempt++) {
+            let streamOpened = false;
+            try {
+                const stream = (await this.protonApi({
+                    url: endpoint,
+                    method: 'post',
+                    data: payload,
+                    output: 'stream',
+                    signal,
+                })) as ReadableStream<Uint8Array>;
+                streamOpened = true;
+
+                const chunks: string[] = [];
+                const reasoningChunks: string[] = [];
+                result = await this.processStream(stream, buffered ? (content => chunks.push(content)) : trackedOnChunk, {
+                    enableEncryption,
+                    requestKey: encryptionParams?.requestKey,
+                    requestId: encryptionParams?.requestId,
+                }, isBounce, {
+                    signal,
+                    idleTimeoutMs,
+                    truncationNote,
+                    waitForConsumer,
+                    onReasoning: buffered && trackedOnReasoning ? (content => reasoningChunks.push(content)) : trackedOnReasoning,
+                    reasoningMarkers,
+                });
+                if (reasoningChunks.length > 0) trackedOnReasoning?.(reasoningChunks.join(''));
+                if (chunks.length > 0) trackedOnChunk?.(chunks.join(''));
+                break;
+            } catch (error) {
+                if (signal?.aborted) throw error;
+
+                // Rather drop sampling, length and stop parameters Lumo doesn't accept than fail the
+                // conversation (max_tokens and stop are still enforced server-side)
+                const status = (error as { status?: number }).status;
+                if (!streamed && (status === 400 || status === 422)
+                    && (request.temperature !== undefined || request.top_p !== undefined
+                        || request.max_tokens !== undefined || request.stop !== undefined)) {
+                    logger.warn({
+                        status,
+                        error: String(error),
+                        temperature: request.temperature,
+                        top_p: request.top_p,
+                        max_tokens: request.max_tokens,
+                        stop: request.stop,
+                    }, 'Lumo rejected sampling parameters, retrying without them');
+                    delete request.temperature;
+                    delete request.top_p;
+                    delete request.max_tokens;
+                    delete request.stop;
+                    attempt--;
+                    continue;
+                }
+                if (streamed || attempt > maxRetries || !isRetryableError(error)) {
+                    // The stream broke before the first token: try once more without streaming
+                    if (streamFallback && streamOpened && !streamed && !buffered) {
+                        logger.warn({ error: String(error) }, 'Lumo stream failed before the first token, retrying without streaming');
+                        buffered = true;
+                        continue;
+                    }
+                    throw error;
+                }
 
-        const result = await this.processStream(stream, onChunk, {
-            enableEncryption,
-            requestKey: encryptionParams?.requestKey,
-            requestId: encryptionParams?.requestId,
-        }, isBounce);
+                const delayMs = baseDelayMs * 2 ** (attempt - 1);
+                logger.warn({
+                    attempt,
+                    maxRetries,
+                    status,
+                    error: String(error),
+                    delayMs,
+                }, 'Lumo request failed, retrying');
+                await sleep(delayMs, undefined, { signal });
+            }
+        }
+
+        // Estimate usage from content length when Lumo didn't report it
+        result.usage ??= {
+            promptTokens: turnsWithInstructions.reduce((sum, t) => sum + estimateTokens(t.content ?? ''), 0),
+            completionTokens: estimateTokens(result.message.content),
+            estimated: true,
+        };
 
         // Log response
         if (logConfig.messageContent) {
@@ -319,7 +551,8 @@ export class LumoClient {
                 { role: Role.User, content: bounceInstruction },
             ];
 
-            return this.chatWithHistory(bounceTurns, onChunk, options, true);
+            const bounceResult = await this.chatWithHistory(bounceTurns, onChunk, options, true);
+            return { ...bounceResult, usage: addUsage(result.usage, bounceResult.usage) };
         }
 
         // Post-process title (remove quotes, trim, limit length)
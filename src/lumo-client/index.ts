@@ -15,4 +15,5 @@ export type {
     AssistantMessageData,
     LumoClientOptions,
     ChatResult,
+    TokenUsage,
 } from './types.js';
@@ -0,0 +1,99 @@
+/**
+ * Inline reasoning removal for streamed message text
+ *
+ * Lumo normally sends its reasoning on a separate target, but a model may also
+ * write it into the answer between markers like <think> and </think>. This splits
+ * such text off from the answer. Like StopSequenceMatcher, a chunk ending in what
+ * may be the start of a marker is held back until the next chunk tells, so markers
+ * split across chunks are still caught. Reasoning that's never closed stays reasoning.
+ */
+
+import type { ReasoningMarkers } from './types.js';
+
+export class InlineReasoningFilter {
+    private pending = '';
+    /** Closing marker of the reasoning being read, undefined outside reasoning */
+    private closing: string | undefined;
+    private sawReasoning = false;
+    private answered = false;
+
+    constructor(private readonly markers: ReasoningMarkers) {}
+
+    /** Add a chunk. Returns the answer text and reasoning in it, either may be empty. */
+    push(chunk: string): { text: string; reasoning: string } {
+        let input = this.pending + chunk;
+        this.pending = '';
+        let text = '';
+        let reasoning = '';
+
+        while (input) {
+            if (this.closing !== undefined) {
+                const end = input.indexOf(this.closing);
+                if (end === -1) {
+                    const keep = partialMatchLength(input, [this.closing]);
+                    reasoning += input.slice(0, input.length - keep);
+                    this.pending = input.slice(input.length - keep);
+                    break;
+                }
+                reasoning += input.slice(0, end);
+                input = input.slice(end + this.closing.length);
+                this.closing = undefined;
+                continue;
+            }
+
+            let start = -1;
+            let marker: [string, string] | undefined;
+            for (const [open, close] of this.markers) {
+                const index = input.indexOf(open);
+                if (index === -1) continue;
+                // The earliest marker wins, the longest one when several start there
+                if (!marker || index < start || (index === start && open.length > marker[0].length)) {
+                    start = index;
+                    marker = [open, close];
+                }
+            }
+            if (!marker) {
+                const keep = partialMatchLength(input, this.markers.map(([open]) => open));
+                text += this.answer(input.slice(0, input.length - keep));
+                this.pending = input.slice(input.length - keep);
+                break;
+            }
+            text += this.answer(input.slice(0, start));
+            input = input.slice(start + marker[0].length);
+            this.closing = marker[1];
+            this.sawReasoning = true;
+        }
+
+        return { text, reasoning };
+    }
+
+    /** Emit whatever is left at end of stream. A held back partial marker wasn't one. */
+    flush(): { text: string; reasoning: string } {
+        const rest = this.pending;
+        this.pending = '';
+        return this.closing !== undefined
+            ? { text: '', reasoning: rest }
+            : { text: this.answer(rest), reasoning: '' };
+    }
+
+    /** Drop the whitespace separating leading reasoning from the answer */
+    private answer(text: string): string {
+        if (!this.answered && this.sawReasoning) text = text.trimStart();
+        if (text) this.answered = true;
+        return text;
+    }
+}
+
+/** Length of the longest end of text that is the start of one of the markers */
+function partialMatchLength(text: string, markers: string[]): number {
+    let longest = 0;
+    for (const marker of markers) {
+        for (let length = Math.min(marker.length - 1, text.length); length > longest; length--) {
+            if (text.endsWith(marker.slice(0, length))) {
+                longest = length;
+                break;
+            }
+        }
+    }
+    return longest;
+}
@@ -96,6 +96,9 @@ export interface AssistantMessageData {
 
 // LumoClient types
 
+/** Open and close markers around reasoning, e.g. ["<think>", "</think>"] */
+export type ReasoningMarkers = Array<[open: string, close: string]>;
+
 export interface LumoClientOptions {
     enableEncryption?: boolean;
     endpoint?: string;
@@ -104,6 +107,50 @@ export interface LumoClientOptions {
     instructions?: string;
     /** Where to inject instructions: 'first' or 'last' user turn. Default: 'first'. */
     injectInstructionsInto?: 'first' | 'last';
+    /** Model to request from Lumo. Omitted to let Lumo choose. */
+    model?: string;
+    /** Sampling temperature. Omitted to let Lumo choose. */
+    temperature?: number;
+    /** Nucleus sampling (top_p). Omitted to let Lumo choose. */
+    topP?: number;
+    /** Max response length in tokens (max_tokens). Omitted for no limit. */
+    maxTokens?: number;
+    /** Stop sequences (stop). Omitted or empty for none. */
+    stop?: string[];
+    /** Aborts the request (including retries and bounces), e.g. when the API client disconnects. */
+    signal?: AbortSignal;
+    /**
+     * Max time between stream chunks before giving up on a stalled stream, keeping the
+     * partial response. Not a limit on the total response time. Omitted or 0 to wait forever.
+     */
+    idleTimeoutMs?: number;
+    /** Appended to the response when it was cut off by idleTimeoutMs */
+    truncationNote?: string;
+    /**
+     * Awaited before every read from Lumo's stream, so a slow consumer pauses it
+     * instead of buffering output without limit. Should also resolve on abort.
+     */
+    waitForConsumer?: () => Promise<void>;
+    /**
+     * Called with Lumo's reasoning ("thinking") chunks, which never become part of the
+     * response. Omitted to drop them.
+     */
+    onReasoning?: (content: string) => void;
+    /**
+     * Markers of reasoning Lumo may write into the response itself. Such
+     * reasoning is passed to onReasoning instead, omitted or empty to leave it.
+     */
+    reasoningMarkers?: ReasoningMarkers;
+    /** Enable Lumo's web search and other external tools, overriding config enableWebSearch */
+    enableWebSearch?: boolean;
+}
+
+/** Token counts of a chat request. */
+export interface TokenUsage {
+    promptTokens: number;
+    completionTokens: number;
+    /** True when Lumo didn't report counts and they were estimated from content length */
+    estimated: boolean;
 }
 
 /** Result from a chat request. */
@@ -116,6 +163,10 @@ export interface ChatResult {
     nativeToolCallFailed?: boolean;
     /** Whether a misrouted custom tool was detected (routed through native SSE pipeline) */
     misrouted?: boolean;
+    /** Whether the stream stalled and the response was cut off (see idleTimeoutMs) */
+    truncated?: boolean;
+    /** Token usage, summed over bounces */
+    usage?: TokenUsage;
     /**
      * Parsed native tool call (for bounce handling).
      * Only set when misrouted=true, used to build the bounce instruction.
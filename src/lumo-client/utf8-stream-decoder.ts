@@ -0,0 +1,36 @@
+/**
+ * Boundary-safe UTF-8 decoding for streamed chunks
+ *
+ * Each encrypted token is decrypted on its own, so a multibyte character can
+ * straddle two tokens. Decoding every token separately would turn both halves
+ * into U+FFFD. This keeps one streaming TextDecoder per stream (message, title,
+ * tool_call, ...), which holds back incomplete sequences until the rest arrives.
+ */
+
+export class Utf8StreamDecoder {
+    private decoders = new Map<string, TextDecoder>();
+
+    /** Decode the bytes of one chunk, returning only complete characters. */
+    decode(stream: string, bytes: Uint8Array): string {
+        let decoder = this.decoders.get(stream);
+        if (!decoder) {
+            decoder = new TextDecoder('utf-8');
+            this.decoders.set(stream, decoder);
+        }
+        return decoder.decode(bytes, { stream: true });
+    }
+
+    /**
+     * End all streams. Returns the text still buffered per stream, where
+     * leftover partial sequences become U+FFFD. Streams with nothing left are omitted.
+     */
+    flush(): [stream: string, text: string][] {
+        const rest: [string, string][] = [];
+        for (const [stream, decoder] of this.decoders) {
+            const text = decoder.decode();
+            if (text) rest.push([stream, text]);
+        }
+        this.decoders.clear();
+        return rest;
+    }
+}
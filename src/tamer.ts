@@ -1,7 +1,7 @@
 #!/usr/bin/env node
 
 import arg from 'arg';
-import { initConfig, getLogConfig } from './app/config.js';
+import { initConfig, getLogConfig, setFlushIntervalMs } from './app/config.js';
 import { initLogger, logger } from './app/logger.js';
 import { printAuthHelp, printHelp, printServerHelp } from './app/terminal.js';
 import './shims/uint8array-base64-polyfill.js';
@@ -10,15 +10,20 @@ import './shims/uint8array-base64-polyfill.js';
 const args = arg({
   '--help': Boolean,
   '-h': '--help',
+  '--log-unredacted': Boolean,
 }, {
   permissive: true,
   stopAtPositional: true,
   argv: process.argv.slice(2)
 });
 
+// Flags may follow the subcommand (see stopAtPositional), strip them from the positionals
+const logUnredacted = args['--log-unredacted'] || args._.includes('--log-unredacted');
+args._ = args._.filter(a => a !== '--log-unredacted');
+
 const mode = args._[0] === 'server' ? 'server' : 'cli';
 initConfig(mode);
-initLogger(getLogConfig());
+initLogger(getLogConfig(), { redact: !logUnredacted });
 
 // Handle --help for main command and subcommands
 if (args['--help'] || args._.includes('--help') || args._.includes('-h')) {
@@ -51,6 +56,12 @@ if (args._[0] === 'auth') {
 
   logger.info('Starting lumo-tamer API Server...');
 
+  // Server flags follow the subcommand
+  const serverArgs = arg({ '--flush-interval': Number }, { argv: args._.slice(1), permissive: true });
+  if (serverArgs['--flush-interval'] !== undefined) {
+    setFlushIntervalMs(serverArgs['--flush-interval']);
+  }
+
   const app = await Application.create();
   const apiServer = new APIServer(app);
   await apiServer.start();
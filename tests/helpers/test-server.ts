@@ -12,8 +12,9 @@ import { createResponsesRouter } from '../../src/api/routes/responses/index.js';
 import { createChatCompletionsRouter } from '../../src/api/routes/chat-completions/index.js';
 import { createHealthRouter } from '../../src/api/routes/health.js';
 import { createModelsRouter } from '../../src/api/routes/models.js';
+import { createUsageRouter } from '../../src/api/routes/usage.js';
 import { RequestQueue } from '../../src/api/queue.js';
-import { LumoClient } from '../../src/lumo-client/index.js';
+import { LumoClient, type ProtonApi } from '../../src/lumo-client/index.js';
 import { createMockProtonApi } from '../../src/mock/mock-api.js';
 import { FallbackStore } from '../../src/conversations/fallback/store.js';
 import { MetricsService, setMetrics } from '../../src/app/metrics.js';
@@ -28,6 +29,10 @@ type Scenario = MockConfig['scenario'];
 export interface TestServerOptions {
   /** Enable metrics collection and /metrics endpoint */
   metrics?: boolean;
+  /** Requests processed at the same time (default: 1) */
+  concurrency?: number;
+  /** ProtonApi to use instead of the scenario's mock */
+  protonApi?: ProtonApi;
 }
 
 export interface TestServer {
@@ -51,10 +56,10 @@ export async function createTestServer(
   scenario: Scenario = 'success',
   options: TestServerOptions = {}
 ): Promise<TestServer> {
-  const mockApi = createMockProtonApi(scenario);
+  const mockApi = options.protonApi ?? createMockProtonApi(scenario);
   const lumoClient = new LumoClient(mockApi, { enableEncryption: false });
   const store = new FallbackStore();
-  const queue = new RequestQueue(1);
+  const queue = new RequestQueue(options.concurrency ?? 1);
 
   const deps: EndpointDependencies = {
     queue,
@@ -79,6 +84,7 @@ export async function createTestServer(
   }
   app.use(createHealthRouter(deps));
   app.use(createModelsRouter());
+  app.use(createUsageRouter());
   app.use(createChatCompletionsRouter(deps));
   app.use(createResponsesRouter(deps));
 
@@ -0,0 +1,156 @@
+/**
+ * Integration tests for request cancellation
+ *
+ * When the API client closes the connection (e.g. Home Assistant cancelling an
+ * assist pipeline), the upstream Lumo request is aborted and everything the
+ * request started (stream reader, timers, queue slot) ends with it.
+ */
+
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { createTestServer, type TestServer } from '../helpers/test-server.js';
+import { formatSSEMessage, delay } from '../../src/mock/mock-api.js';
+import type { ProtonApi } from '../../src/lumo-client/index.js';
+
+/** Signals of the Lumo requests made, and whether their streams were cancelled */
+const upstream: Array<{ signal?: AbortSignal; cancelled: boolean }> = [];
+
+/** Mock Lumo streaming a token every 20ms for a few seconds */
+const slowApi: ProtonApi = async (options) => {
+  const call = { signal: options.signal, cancelled: false };
+  upstream.push(call);
+  const encoder = new TextEncoder();
+  let stopped = false;
+
+  return new ReadableStream<Uint8Array>({
+    async start(controller) {
+      for (let i = 0; i < 200 && !stopped; i++) {
+        controller.enqueue(encoder.encode(
+          formatSSEMessage({ type: 'token_data', target: 'message', count: i, content: 'token ' })
+        ));
+        await delay(20);
+      }
+      if (!stopped) {
+        controller.enqueue(encoder.encode(formatSSEMessage({ type: 'done' })));
+        controller.close();
+      }
+    },
+    cancel() {
+      stopped = true;
+      call.cancelled = true;
+    },
+  });
+};
+
+let ts: TestServer;
+
+beforeAll(async () => {
+  ts = await createTestServer('success', { protonApi: slowApi });
+});
+
+afterAll(async () => {
+  await ts.close();
+});
+
+/** Start a streaming request, read its first chunk, then disconnect */
+async function disconnectAfterFirstChunk(path: string, body: Record<string, unknown>): Promise<void> {
+  const controller = new AbortController();
+  const res = await fetch(`${ts.baseUrl}${path}`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ model: 'lumo', stream: true, ...body }),
+    signal: controller.signal,
+  });
+  const reader = res.body!.getReader();
+  await reader.read();
+  controller.abort();
+  await reader.closed.catch(() => { });
+}
+
+/** Count timers and intervals alive in the process, e.g. idle timeouts and keep-alive pings */
+function activeTimers(): number {
+  return process.getActiveResourcesInfo().filter(type => type === 'Timeout').length;
+}
+
+/** Wait until the last Lumo request was cancelled, or time out */
+async function waitForCancellation(timeoutMs = 1000) {
+  const call = upstream[upstream.length - 1];
+  for (let waited = 0; !call.cancelled && waited < timeoutMs; waited += 10) {
+    await delay(10);
+  }
+  return call;
+}
+
+describe('client disconnects', () => {
+  it('cancels the Lumo request of a chat completion', async () => {
+    await disconnectAfterFirstChunk('/v1/chat/completions', {
+      messages: [{ role: 'user', content: 'Tell me a long story' }],
+    });
+
+    const call = await waitForCancellation();
+    expect(call.signal?.aborted).toBe(true);
+    expect(call.cancelled).toBe(true);
+  });
+
+  it('cancels the Lumo request of a response', async () => {
+    await disconnectAfterFirstChunk('/v1/responses', { input: 'Tell me a long story' });
+
+    const call = await waitForCancellation();
+    expect(call.signal?.aborted).toBe(true);
+    expect(call.cancelled).toBe(true);
+  });
+
+  it('leaves no timers behind', async () => {
+    const body = { messages: [{ role: 'user', content: 'Tell me a long story' }] };
+    // Warm up, so lazily created timers of the HTTP stack count in the baseline
+    await disconnectAfterFirstChunk('/v1/chat/completions', body);
+    await waitForCancellation();
+    await delay(100);
+    const baseline = activeTimers();
+
+    for (let i = 0; i < 5; i++) {
+      await disconnectAfterFirstChunk('/v1/chat/completions', body);
+      await waitForCancellation();
+      await disconnectAfterFirstChunk('/v1/responses', { input: 'Tell me a long story' });
+      await waitForCancellation();
+    }
+    await delay(100);
+
+    expect(activeTimers()).toBeLessThanOrEqual(baseline);
+    expect(ts.deps.queue.getPending()).toBe(0);
+    expect(ts.deps.queue.getSize()).toBe(0);
+  });
+
+  it('drops a queued request without calling Lumo', async () => {
+    // The first request holds the queue (concurrency 1) while the second one waits
+    const first = new AbortController();
+    const running = fetch(`${ts.baseUrl}/v1/chat/completions`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ model: 'lumo', stream: true, messages: [{ role: 'user', content: 'First' }] }),
+      signal: first.signal,
+    });
+    const reader = (await running).body!.getReader();
+    await reader.read();
+    const callsBefore = upstream.length;
+
+    const second = new AbortController();
+    // Responses start streaming events before the queue, so the fetch resolves
+    const queued = fetch(`${ts.baseUrl}/v1/responses`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ model: 'lumo', stream: true, input: 'Second' }),
+      signal: second.signal,
+    });
+    const queuedReader = (await queued).body!.getReader();
+    second.abort();
+    await queuedReader.closed.catch(() => { });
+    await delay(50);
+
+    first.abort();
+    await reader.closed.catch(() => { });
+    await waitForCancellation();
+    await ts.deps.queue.waitForIdle();
+
+    expect(upstream.length).toBe(callsBefore);
+  });
+});
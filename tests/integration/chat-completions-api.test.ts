@@ -4,7 +4,9 @@
 
 import { describe, it, expect, beforeAll, afterAll } from 'vitest';
 import { createTestServer, parseSSEEvents, type TestServer } from '../helpers/test-server.js';
-import { getCustomToolsConfig } from '../../src/app/config.js';
+import { getCustomToolsConfig, getServerConfig } from '../../src/app/config.js';
+import { formatSSEMessage, delay } from '../../src/mock/mock-api.js';
+import type { ProtonApi } from '../../src/lumo-client/index.js';
 
 /** POST /v1/chat/completions with JSON body, returning the raw Response. */
 function postChat(ts: TestServer, body: Record<string, unknown>): Promise<Response> {
@@ -133,6 +135,154 @@ describe('/v1/chat/completions', () => {
     });
   });
 
+  describe('with server.flushIntervalMs', () => {
+    const tokens = ['Tur', 'ning', ' on', ' the', ' li', 'ghts', ' now'];
+    /** Mock Lumo streaming tokens 5ms apart */
+    const tokenApi: ProtonApi = async () => {
+      const encoder = new TextEncoder();
+      return new ReadableStream<Uint8Array>({
+        async start(controller) {
+          for (let i = 0; i < tokens.length; i++) {
+            controller.enqueue(encoder.encode(
+              formatSSEMessage({ type: 'token_data', target: 'message', count: i, content: tokens[i] })
+            ));
+            await delay(5);
+          }
+          controller.enqueue(encoder.encode(formatSSEMessage({ type: 'done' })));
+          controller.close();
+        },
+      });
+    };
+    let tokenTs: TestServer;
+
+    beforeAll(async () => {
+      tokenTs = await createTestServer('success', { protonApi: tokenApi });
+      getServerConfig().flushIntervalMs = 1000;
+    });
+    afterAll(async () => {
+      getServerConfig().flushIntervalMs = 0;
+      await tokenTs.close();
+    });
+
+    it('sends whole words and flushes the rest at end of stream', async () => {
+      const res = await postChat(tokenTs, { model: 'lumo', messages: userMessage('Lights'), stream: true });
+      const events = parseSSEEvents(await res.text()).filter(e => typeof e.data === 'object');
+      const deltas = events
+        .map(e => (e.data as any).choices[0].delta.content)
+        .filter((content): content is string => typeof content === 'string');
+
+      // The interval outlasts the stream, so the only flush is the final one, before finish_reason
+      expect(deltas).toEqual([tokens.join('')]);
+      expect((events[events.length - 1].data as any).choices[0].finish_reason).toBe('stop');
+    });
+  });
+
+  describe('with reasoning', () => {
+    /** Mock Lumo thinking before it answers */
+    const reasoningApi: ProtonApi = async () => {
+      const encoder = new TextEncoder();
+      const messages = [
+        { type: 'token_data', target: 'reasoning', count: 0, content: 'The user wants ' },
+        { type: 'token_data', target: 'reasoning', count: 1, content: 'the lights on.' },
+        { type: 'token_data', target: 'message', count: 0, content: 'Turning on ' },
+        { type: 'token_data', target: 'message', count: 1, content: 'the lights.' },
+        { type: 'done' },
+      ];
+      return new ReadableStream<Uint8Array>({
+        start(controller) {
+          for (const msg of messages) controller.enqueue(encoder.encode(formatSSEMessage(msg)));
+          controller.close();
+        },
+      });
+    };
+    let reasoningTs: TestServer;
+
+    beforeAll(async () => {
+      reasoningTs = await createTestServer('success', { protonApi: reasoningApi });
+    });
+    afterAll(async () => {
+      getServerConfig().reasoning = 'discard';
+      await reasoningTs.close();
+    });
+
+    it('keeps reasoning out of the answer by default', async () => {
+      const res = await postChat(reasoningTs, { model: 'lumo', messages: userMessage('Lights'), stream: false });
+      const body = await res.json();
+
+      expect(body.choices[0].message.content).toBe('Turning on the lights.');
+      expect(body.choices[0].message.reasoning_content).toBeUndefined();
+    });
+
+    it('streams reasoning as reasoning_content deltas with server.reasoning: emit', async () => {
+      getServerConfig().reasoning = 'emit';
+      const res = await postChat(reasoningTs, { model: 'lumo', messages: userMessage('Lights'), stream: true });
+      const deltas = parseSSEEvents(await res.text())
+        .filter(e => typeof e.data === 'object')
+        .map(e => (e.data as any).choices[0].delta);
+
+      expect(deltas.map(d => d.reasoning_content ?? '').join('')).toBe('The user wants the lights on.');
+      expect(deltas.map(d => d.content ?? '').join('')).toBe('Turning on the lights.');
+    });
+
+    it('returns reasoning_content next to content with server.reasoning: emit', async () => {
+      getServerConfig().reasoning = 'emit';
+      const res = await postChat(reasoningTs, { model: 'lumo', messages: userMessage('Lights'), stream: false });
+      const body = await res.json();
+
+      expect(body.choices[0].message.content).toBe('Turning on the lights.');
+      expect(body.choices[0].message.reasoning_content).toBe('The user wants the lights on.');
+    });
+  });
+
+  describe('with reasoning inline in the answer', () => {
+    const tokens = ['<thi', 'nk>Which', ' lights?</thi', 'nk>', '\n\nTurning on ', 'the lights.'];
+    const inlineApi: ProtonApi = async () => {
+      const encoder = new TextEncoder();
+      return new ReadableStream<Uint8Array>({
+        start(controller) {
+          for (let i = 0; i < tokens.length; i++) {
+            controller.enqueue(encoder.encode(
+              formatSSEMessage({ type: 'token_data', target: 'message', count: i, content: tokens[i] })
+            ));
+          }
+          controller.enqueue(encoder.encode(formatSSEMessage({ type: 'done' })));
+          controller.close();
+        },
+      });
+    };
+    let inlineTs: TestServer;
+
+    beforeAll(async () => {
+      inlineTs = await createTestServer('success', { protonApi: inlineApi });
+    });
+    afterAll(async () => {
+      getServerConfig().reasoning = 'discard';
+      await inlineTs.close();
+    });
+
+    it('never streams reasoning between markers split across chunks', async () => {
+      const res = await postChat(inlineTs, { model: 'lumo', messages: userMessage('Lights'), stream: true });
+      const text = await res.text();
+
+      expect(text).not.toContain('Which');
+      expect(text).not.toContain('think');
+      const content = parseSSEEvents(text)
+        .filter(e => typeof e.data === 'object')
+        .map(e => (e.data as any).choices[0].delta.content ?? '')
+        .join('');
+      expect(content).toBe('Turning on the lights.');
+    });
+
+    it('routes it like other reasoning with server.reasoning: emit', async () => {
+      getServerConfig().reasoning = 'emit';
+      const res = await postChat(inlineTs, { model: 'lumo', messages: userMessage('Lights'), stream: false });
+      const body = await res.json();
+
+      expect(body.choices[0].message.content).toBe('Turning on the lights.');
+      expect(body.choices[0].message.reasoning_content).toBe('Which lights?');
+    });
+  });
+
   describe('misroutedToolCall scenario (bounce)', () => {
     let nativeTs: TestServer;
     const dummyTools = [{ type: 'function', function: { name: 'GetLiveContext', parameters: {} } }];
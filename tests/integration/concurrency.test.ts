@@ -0,0 +1,94 @@
+/**
+ * Integration tests for concurrent conversations
+ *
+ * Runs many simultaneous streams (as several Home Assistant devices would) against
+ * a mock Lumo that echoes a device marker token by token with random delays,
+ * and asserts every stream only contains its own content.
+ */
+
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { createTestServer, parseSSEEvents, type TestServer } from '../helpers/test-server.js';
+import { formatSSEMessage, delay } from '../../src/mock/mock-api.js';
+import type { ProtonApi, Turn } from '../../src/lumo-client/index.js';
+
+const DEVICES = 20;
+
+const expectedReply = (device: number) => `Reply for device-${device}, all good.`;
+
+/** Mock Lumo streaming the reply for the device mentioned in the last turn, one character at a time */
+const echoApi: ProtonApi = async (options) => {
+  const turns: Turn[] = (options.data as { Prompt: { turns: Turn[] } }).Prompt.turns;
+  const device = Number(turns[turns.length - 1].content?.match(/device-(\d+)/)?.[1]);
+  const encoder = new TextEncoder();
+
+  return new ReadableStream<Uint8Array>({
+    async start(controller) {
+      const reply = expectedReply(device);
+      for (let i = 0; i < reply.length; i++) {
+        controller.enqueue(encoder.encode(
+          formatSSEMessage({ type: 'token_data', target: 'message', count: i, content: reply[i] })
+        ));
+        await delay(Math.random() * 5);
+      }
+      controller.enqueue(encoder.encode(formatSSEMessage({ type: 'done' })));
+      controller.close();
+    },
+  });
+};
+
+let ts: TestServer;
+
+beforeAll(async () => {
+  ts = await createTestServer('success', { concurrency: DEVICES, protonApi: echoApi });
+});
+
+afterAll(async () => {
+  await ts.close();
+});
+
+const devices = Array.from({ length: DEVICES }, (_, i) => i);
+
+describe('concurrent conversations', () => {
+  it('keeps chat completion streams apart', async () => {
+    const outputs = await Promise.all(devices.map(async (device) => {
+      const res = await fetch(`${ts.baseUrl}/v1/chat/completions`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({
+          model: 'lumo',
+          stream: true,
+          messages: [{ role: 'user', content: `Hello from device-${device}` }],
+        }),
+      });
+      const events = parseSSEEvents(await res.text());
+      return events
+        .map(e => (e.data as { choices?: Array<{ delta?: { content?: string } }> }).choices?.[0]?.delta?.content ?? '')
+        .join('');
+    }));
+
+    expect(outputs).toEqual(devices.map(expectedReply));
+  });
+
+  it('keeps responses streams apart', async () => {
+    const outputs = await Promise.all(devices.map(async (device) => {
+      const res = await fetch(`${ts.baseUrl}/v1/responses`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({
+          model: 'lumo',
+          stream: true,
+          input: `Hello from device-${device}`,
+          user: `conversation-${device}`,
+        }),
+      });
+      const events = parseSSEEvents(await res.text());
+      return events
+        .map(e => e.data as { type?: string; delta?: string })
+        .filter(data => data.type === 'response.output_text.delta')
+        .map(data => data.delta)
+        .join('');
+    }));
+
+    expect(outputs).toEqual(devices.map(expectedReply));
+  });
+});
@@ -1,9 +1,15 @@
 /**
- * Integration tests for /health and /v1/models endpoints
+ * Integration tests for /health, /healthz, /v1/models and /v1/usage endpoints
  */
 
 import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import express from 'express';
+import type { Server } from 'http';
+import type { AddressInfo } from 'net';
 import { createTestServer, type TestServer } from '../helpers/test-server.js';
+import { createHealthRouter } from '../../src/api/routes/health.js';
+import { AuthManager } from '../../src/auth/manager.js';
+import type { IAuthProvider } from '../../src/auth/types.js';
 
 let ts: TestServer;
 
@@ -26,6 +32,56 @@ describe('GET /health', () => {
   });
 });
 
+describe('GET /healthz', () => {
+  /** Fetch /healthz from a server whose auth manager uses the given provider */
+  async function fetchHealthz(provider: Partial<IAuthProvider>): Promise<Response> {
+    const authManager = new AuthManager({ provider: provider as IAuthProvider, vaultPath: '' });
+    const app = express();
+    app.use(createHealthRouter({ ...ts.deps, authManager }));
+    const server = await new Promise<Server>((resolve) => {
+      const s = app.listen(0, () => resolve(s));
+    });
+    try {
+      const { port } = server.address() as AddressInfo;
+      return await fetch(`http://localhost:${port}/healthz`);
+    } finally {
+      server.close();
+    }
+  }
+
+  it('is ready without an auth manager (mock mode)', async () => {
+    const res = await fetch(`${ts.baseUrl}/healthz`);
+    expect(res.status).toBe(200);
+    expect(await res.json()).toEqual({ status: 'ok' });
+  });
+
+  it('is ready with a valid token', async () => {
+    const res = await fetchHealthz({ method: 'login', isValid: () => true });
+    expect(res.status).toBe(200);
+  });
+
+  it('returns 503 when the token is expired', async () => {
+    const res = await fetchHealthz({ method: 'login', isValid: () => false });
+    expect(res.status).toBe(503);
+    expect(await res.json()).toEqual({ status: 'unavailable', reason: 'Proton access token expired' });
+  });
+
+  it('returns 503 while refreshing fails', async () => {
+    const provider: Partial<IAuthProvider> = {
+      method: 'login',
+      isValid: () => true,
+      refresh: async () => { throw new Error('Invalid refresh token'); },
+    };
+    const authManager = new AuthManager({ provider: provider as IAuthProvider, vaultPath: '' });
+    await expect(authManager.refreshNow()).rejects.toThrow();
+
+    expect(await authManager.checkReadiness()).toEqual({
+      ready: false,
+      reason: 'Token refresh failing: Invalid refresh token',
+    });
+  });
+});
+
 describe('GET /v1/models', () => {
   it('returns list with single model', async () => {
     const res = await fetch(`${ts.baseUrl}/v1/models`);
@@ -45,3 +101,24 @@ describe('GET /v1/models', () => {
     expect(body.data[0].id).toBe('lumo');
   });
 });
+
+describe('GET /v1/usage', () => {
+  it('reports estimated token counts of completed requests', async () => {
+    await fetch(`${ts.baseUrl}/v1/chat/completions`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ model: 'lumo', messages: [{ role: 'user', content: 'Hello' }] }),
+    });
+
+    const res = await fetch(`${ts.baseUrl}/v1/usage`);
+    expect(res.status).toBe(200);
+
+    const body = await res.json();
+    expect(body.today.requests).toBe(1);
+    expect(body.today.promptTokens).toBeGreaterThan(0);
+    expect(body.today.completionTokens).toBeGreaterThan(0);
+    // The mock reports no usage
+    expect(body.today.estimated).toBe(true);
+    expect(body.days).toHaveLength(1);
+  });
+});
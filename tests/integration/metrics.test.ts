@@ -136,6 +136,20 @@ describe('GET /metrics', () => {
     expect(body).toContain('role="user"');
   });
 
+  it('tracks Lumo request outcome and duration', async () => {
+    await fetch(`${ts.baseUrl}/v1/responses`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ input: 'Hello world' }),
+    });
+
+    const res = await fetch(`${ts.baseUrl}/metrics`);
+    const body = await res.text();
+
+    expect(body).toContain('lumo_upstream_requests_total{outcome="success"}');
+    expect(body).toContain('lumo_upstream_request_duration_seconds_count{outcome="success"}');
+  });
+
   it('tracks streaming flag in request metrics', async () => {
     // Create a fresh server for this test to avoid metric contamination
     const freshServer = await createTestServerWithMetrics();
@@ -184,6 +198,10 @@ describe('GET /metrics', () => {
     expect(body).toContain('lumo_sync_operations_total');
     expect(body).toContain('lumo_sync_duration_seconds');
     expect(body).toContain('lumo_auth_failures_total');
+    expect(body).toContain('lumo_token_refreshes_total');
+    expect(body).toContain('lumo_active_requests');
+    expect(body).toContain('lumo_upstream_requests_total');
+    expect(body).toContain('lumo_upstream_request_duration_seconds');
     expect(body).toContain('lumo_proton_api_requests_total');
     expect(body).toContain('lumo_proton_api_request_duration_seconds');
   });
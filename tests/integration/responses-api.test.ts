@@ -7,7 +7,9 @@
 
 import { describe, it, expect, beforeAll, afterAll } from 'vitest';
 import { createTestServer, parseSSEEvents, type TestServer } from '../helpers/test-server.js';
-import { getCustomToolsConfig } from '../../src/app/config.js';
+import { getCustomToolsConfig, getServerConfig } from '../../src/app/config.js';
+import { formatSSEMessage } from '../../src/mock/mock-api.js';
+import type { ProtonApi } from '../../src/lumo-client/index.js';
 
 /** POST /v1/responses with JSON body, returning the raw Response. */
 function postResponses(ts: TestServer, body: Record<string, unknown>): Promise<Response> {
@@ -182,6 +184,62 @@ describe('/v1/responses', () => {
     });
   });
 
+  describe('with reasoning', () => {
+    /** Mock Lumo thinking before it answers */
+    const reasoningApi: ProtonApi = async () => {
+      const encoder = new TextEncoder();
+      const messages = [
+        { type: 'token_data', target: 'reasoning', count: 0, content: 'The user wants ' },
+        { type: 'token_data', target: 'reasoning', count: 1, content: 'the lights on.' },
+        { type: 'token_data', target: 'message', count: 0, content: 'Turning on the lights.' },
+        { type: 'done' },
+      ];
+      return new ReadableStream<Uint8Array>({
+        start(controller) {
+          for (const msg of messages) controller.enqueue(encoder.encode(formatSSEMessage(msg)));
+          controller.close();
+        },
+      });
+    };
+    let ts: TestServer;
+
+    beforeAll(async () => {
+      ts = await createTestServer('success', { protonApi: reasoningApi });
+    });
+    afterAll(async () => {
+      getServerConfig().reasoning = 'discard';
+      await ts.close();
+    });
+
+    it('keeps reasoning out of the output by default', async () => {
+      const res = await postResponses(ts, { input: 'Lights', stream: false });
+      const body = await res.json();
+
+      expect(body.output).toHaveLength(1);
+      expect(body.output[0].content[0].text).toBe('Turning on the lights.');
+    });
+
+    it('streams reasoning as a reasoning item with server.reasoning: emit', async () => {
+      getServerConfig().reasoning = 'emit';
+      const res = await postResponses(ts, { input: 'Lights', stream: true });
+      const events = parseSSEEvents(await res.text());
+
+      const reasoningDeltas = events
+        .filter(e => e.event === 'response.reasoning_summary_text.delta')
+        .map(e => (e.data as any).delta);
+      expect(reasoningDeltas.join('')).toBe('The user wants the lights on.');
+
+      const textDeltas = events
+        .filter(e => e.event === 'response.output_text.delta')
+        .map(e => (e.data as any).delta);
+      expect(textDeltas.join('')).toBe('Turning on the lights.');
+
+      const completed = events.find(e => e.event === 'response.completed')!.data as any;
+      expect(completed.response.output.map((item: any) => item.type)).toEqual(['message', 'reasoning']);
+      expect(completed.response.output[1].summary[0].text).toBe('The user wants the lights on.');
+    });
+  });
+
   describe('misroutedToolCall scenario (bounce)', () => {
     let ts: TestServer;
     const dummyTools = [{ type: 'function', function: { name: 'GetLiveContext', parameters: {} } }];
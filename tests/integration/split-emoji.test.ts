@@ -0,0 +1,118 @@
+/**
+ * Integration tests for emoji split across Lumo chunks
+ *
+ * Lumo streamed the deltas 'y. \ud83c' and '\udf89  \n\n', an emoji split between
+ * two chunks. Forwarded as is, they ended up in Home Assistant's intent-progress
+ * events (chat_log_delta) and failed with "Unable to serialize to JSON". Every
+ * streamed delta must be well-formed, and together still contain the emoji.
+ */
+
+import { describe, it, expect, beforeAll, afterAll, afterEach } from 'vitest';
+import { createTestServer, parseSSEEvents, type TestServer } from '../helpers/test-server.js';
+import { getServerConfig } from '../../src/app/config.js';
+import { formatSSEMessage } from '../../src/mock/mock-api.js';
+import type { ProtonApi } from '../../src/lumo-client/index.js';
+
+const PAYLOADS = ['y. \ud83c', '\udf89  \n\n'];
+const EXPECTED = 'y. 🎉  \n\n';
+
+/** Matches a \u escape of a surrogate that isn't part of a valid escaped pair. */
+const LONE_SURROGATE_ESCAPE = /\\ud[89ab][0-9a-f]{2}(?!\\ud[c-f][0-9a-f]{2})|(?<!\\ud[89ab][0-9a-f]{2})\\ud[c-f][0-9a-f]{2}/i;
+
+/** True if text contains a surrogate that isn't part of a valid pair. */
+function hasLoneSurrogate(text: string): boolean {
+  return /[\ud800-\udbff](?![\udc00-\udfff])|(?<![\ud800-\udbff])[\udc00-\udfff]/.test(text);
+}
+
+/** Mock Lumo sending the payloads on the given target, unencrypted like the mock API */
+function splitEmojiApi(target: 'message' | 'reasoning'): ProtonApi {
+  return async () => {
+    const encoder = new TextEncoder();
+    const messages = [
+      ...PAYLOADS.map((content, count) => ({ type: 'token_data', target, count, content })),
+      // Reasoning alone is no answer
+      ...(target === 'reasoning' ? [{ type: 'token_data', target: 'message', count: 0, content: 'Ok.' }] : []),
+      { type: 'done' },
+    ];
+    return new ReadableStream<Uint8Array>({
+      start(controller) {
+        for (const msg of messages) controller.enqueue(encoder.encode(formatSSEMessage(msg)));
+        controller.close();
+      },
+    });
+  };
+}
+
+async function post(ts: TestServer, path: string, body: Record<string, unknown>): Promise<string> {
+  const res = await fetch(`${ts.baseUrl}${path}`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(body),
+  });
+  return res.text();
+}
+
+/** Stream a request and return its deltas of one kind, checking the raw SSE on the way */
+async function streamDeltas(
+  ts: TestServer,
+  endpoint: 'chat' | 'responses',
+  kind: 'content' | 'reasoning'
+): Promise<string[]> {
+  const text = endpoint === 'chat'
+    ? await post(ts, '/v1/chat/completions', { model: 'lumo', messages: [{ role: 'user', content: 'Party?' }], stream: true })
+    : await post(ts, '/v1/responses', { input: 'Party?', stream: true });
+  expect(text).not.toMatch(LONE_SURROGATE_ESCAPE);
+
+  const events = parseSSEEvents(text).filter(e => typeof e.data === 'object');
+  if (endpoint === 'chat') {
+    const field = kind === 'content' ? 'content' : 'reasoning_content';
+    return events
+      .map(e => (e.data as any).choices?.[0]?.delta?.[field])
+      .filter((delta): delta is string => typeof delta === 'string');
+  }
+  const type = kind === 'content' ? 'response.output_text.delta' : 'response.reasoning_summary_text.delta';
+  return events.filter(e => e.event === type).map(e => (e.data as any).delta);
+}
+
+describe('emoji split across Lumo chunks', () => {
+  const servers: Partial<Record<'message' | 'reasoning', TestServer>> = {};
+
+  beforeAll(async () => {
+    servers.message = await createTestServer('success', { protonApi: splitEmojiApi('message') });
+    servers.reasoning = await createTestServer('success', { protonApi: splitEmojiApi('reasoning') });
+  });
+  afterEach(() => {
+    getServerConfig().flushIntervalMs = 0;
+    getServerConfig().reasoning = 'discard';
+  });
+  afterAll(async () => {
+    await servers.message?.close();
+    await servers.reasoning?.close();
+  });
+
+  for (const endpoint of ['chat', 'responses'] as const) {
+    describe(endpoint === 'chat' ? '/v1/chat/completions' : '/v1/responses', () => {
+      it('sends the emoji whole in the next delta', async () => {
+        const deltas = await streamDeltas(servers.message!, endpoint, 'content');
+
+        expect(deltas).toEqual(['y. ', '🎉  \n\n']);
+      });
+
+      it('reconstructs the emoji with coalescing', async () => {
+        getServerConfig().flushIntervalMs = 50;
+        const deltas = await streamDeltas(servers.message!, endpoint, 'content');
+
+        for (const delta of deltas) expect(hasLoneSurrogate(delta)).toBe(false);
+        expect(deltas.join('')).toBe(EXPECTED);
+      });
+
+      it('keeps reasoning deltas whole too', async () => {
+        getServerConfig().reasoning = 'emit';
+        const deltas = await streamDeltas(servers.reasoning!, endpoint, 'reasoning');
+
+        for (const delta of deltas) expect(hasLoneSurrogate(delta)).toBe(false);
+        expect(deltas.join('')).toBe(EXPECTED);
+      });
+    });
+  }
+});
@@ -0,0 +1,52 @@
+/**
+ * Unit tests for CitationFormatter
+ *
+ * Tests rewriting web search citations for voice and plain text,
+ * including citations split across chunks.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { CitationFormatter, formatCitations } from '../../src/api/citation-formatter.js';
+
+/** Feed chunks through a formatter and return everything it emitted */
+function stream(chunks: string[]): string {
+  const formatter = new CitationFormatter();
+  return chunks.map(chunk => formatter.push(chunk)).join('') + formatter.flush();
+}
+
+describe('formatCitations', () => {
+  it('rewrites markdown links to their label', () => {
+    expect(formatCitations('According to [the forecast](https://example.com/weather), rain.'))
+      .toBe('According to the forecast, rain.');
+  });
+
+  it('drops reference markers and numbered links', () => {
+    expect(formatCitations('Rain tomorrow [1](https://a.example) and Friday [2, 3].'))
+      .toBe('Rain tomorrow and Friday.');
+    expect(formatCitations('Sunny [[1]](https://a.example)[^2]【3†source】.')).toBe('Sunny.');
+  });
+
+  it('leaves ordinary brackets alone', () => {
+    expect(formatCitations('Set the mode to [eco].')).toBe('Set the mode to [eco].');
+  });
+});
+
+describe('CitationFormatter', () => {
+  it('formats citations split across chunks', () => {
+    expect(stream(['Rain tomorrow [', '1](https://a.', 'example) and', ' Friday [2', '].']))
+      .toBe('Rain tomorrow and Friday.');
+    expect(stream(['See [the ', 'forecast]', '(https://example.com)', '.'])).toBe('See the forecast.');
+  });
+
+  it('holds back only possible citations', () => {
+    const formatter = new CitationFormatter();
+    expect(formatter.push('Hello ')).toBe('Hello ');
+    expect(formatter.push('world [')).toBe('world');
+    expect(formatter.push('not a link]\n')).toBe(' [not a link]\n');
+    expect(formatter.flush()).toBe('');
+  });
+
+  it('emits a dangling bracket at end of stream', () => {
+    expect(stream(['Values [a, b'])).toBe('Values [a, b');
+  });
+});
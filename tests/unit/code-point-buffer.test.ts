@@ -0,0 +1,93 @@
+/**
+ * Unit tests for CodePointBuffer
+ *
+ * Tests that streamed text is only emitted as whole code points.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { CodePointBuffer } from '../../src/api/code-point-buffer.js';
+import { createStreamingToolProcessor } from '../../src/api/tools/streaming-processor.js';
+
+/** True if text contains a surrogate that isn't part of a valid pair. */
+function hasLoneSurrogate(text: string): boolean {
+  return /[\ud800-\udbff](?![\udc00-\udfff])|(?<![\ud800-\udbff])[\udc00-\udfff]/.test(text);
+}
+
+/** Split text into single UTF-16 code units, the worst case for chunking. */
+function codeUnits(text: string): string[] {
+  return Array.from({ length: text.length }, (_, i) => text[i]);
+}
+
+describe('CodePointBuffer', () => {
+  it('passes through text without surrogates unchanged', () => {
+    const buffer = new CodePointBuffer();
+    expect(buffer.push('hello ')).toBe('hello ');
+    expect(buffer.push('world')).toBe('world');
+    expect(buffer.flush()).toBe('');
+  });
+
+  it('holds back a trailing high surrogate until the low surrogate arrives', () => {
+    const buffer = new CodePointBuffer();
+    expect(buffer.push('party \ud83c')).toBe('party ');
+    expect(buffer.push('\udf89!')).toBe('🎉!');
+  });
+
+  it('emits only whole code points when fed an emoji one code unit at a time', () => {
+    const buffer = new CodePointBuffer();
+    const input = 'Done 🎉👍🏽 ok';
+    const emitted = codeUnits(input).map(unit => buffer.push(unit));
+    emitted.push(buffer.flush());
+
+    for (const piece of emitted) {
+      expect(hasLoneSurrogate(piece)).toBe(false);
+      expect(() => JSON.parse(JSON.stringify(piece))).not.toThrow();
+    }
+    expect(emitted.join('')).toBe(input);
+  });
+
+  it('keeps valid pairs inside a chunk', () => {
+    const buffer = new CodePointBuffer();
+    expect(buffer.push('a🎉b')).toBe('a🎉b');
+  });
+
+  it('replaces an orphaned low surrogate', () => {
+    const buffer = new CodePointBuffer();
+    expect(buffer.push('a\udf89b')).toBe('a�b');
+  });
+
+  it('replaces a high surrogate followed by a non-surrogate', () => {
+    const buffer = new CodePointBuffer();
+    expect(buffer.push('\ud83c')).toBe('');
+    expect(buffer.push('x')).toBe('�x');
+  });
+
+  it('replaces a dangling high surrogate at end of stream', () => {
+    const buffer = new CodePointBuffer();
+    expect(buffer.push('end \ud83c')).toBe('end ');
+    expect(buffer.flush()).toBe('�');
+    expect(buffer.flush()).toBe('');
+  });
+});
+
+describe('createStreamingToolProcessor with split surrogates', () => {
+  for (const hasCustomTools of [false, true]) {
+    it(`never emits a lone surrogate (custom tools: ${hasCustomTools})`, () => {
+      const deltas: string[] = [];
+      const processor = createStreamingToolProcessor(hasCustomTools, {
+        emitTextDelta(text) { deltas.push(text); },
+        emitToolCall() { /* not used */ },
+      });
+
+      const input = 'Happy birthday 🎉🎂 from Lumo 👋';
+      for (const unit of codeUnits(input)) {
+        processor.onChunk(unit);
+      }
+      processor.finalize();
+
+      for (const delta of deltas) {
+        expect(hasLoneSurrogate(delta)).toBe(false);
+      }
+      expect(deltas.join('')).toBe(input);
+    });
+  }
+});
@@ -1,11 +1,11 @@
 /**
  * Unit tests for FallbackStore (in-memory conversation store)
  *
- * Tests in-memory conversation management, LRU eviction,
+ * Tests in-memory conversation management, LRU and idle eviction,
  * message deduplication, and Turn conversion.
  */
 
-import { describe, it, expect, beforeEach } from 'vitest';
+import { describe, it, expect, beforeEach, afterEach, vi } from 'vitest';
 import { FallbackStore } from '../../src/conversations/fallback/store.js';
 
 let store: FallbackStore;
@@ -205,6 +205,37 @@ describe('FallbackStore', () => {
   });
 
 
+  describe('idle eviction', () => {
+    afterEach(() => {
+      vi.useRealTimers();
+    });
+
+    it('evicts conversations idle longer than idleTtlMinutes', () => {
+      vi.useFakeTimers();
+      store.getOrCreate('conv-old');
+      vi.advanceTimersByTime(60 * 60_000);
+      store.getOrCreate('conv-recent');
+      vi.advanceTimersByTime(61 * 60_000);
+
+      // Default idleTtlMinutes is 120: conv-old is idle for 121 minutes, conv-recent for 61
+      store.getOrCreate('conv-new');
+      expect(store.has('conv-old')).toBe(false);
+      expect(store.has('conv-recent')).toBe(true);
+      expect(store.has('conv-new')).toBe(true);
+    });
+
+    it('keeps conversations that are accessed again', () => {
+      vi.useFakeTimers();
+      store.getOrCreate('conv-1');
+      vi.advanceTimersByTime(100 * 60_000);
+      store.get('conv-1');
+      vi.advanceTimersByTime(100 * 60_000);
+
+      store.getOrCreate('conv-2');
+      expect(store.has('conv-1')).toBe(true);
+    });
+  });
+
   describe('setTitle', () => {
     it('updates title and marks dirty', () => {
       store.getOrCreate('conv-1');
@@ -0,0 +1,71 @@
+/**
+ * Unit tests for DeltaCoalescer
+ *
+ * Tests collecting tiny streamed deltas into larger pieces at word and
+ * sentence boundaries, without splitting surrogate pairs.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { DeltaCoalescer } from '../../src/api/delta-coalescer.js';
+import { toWellFormed } from '../../src/api/well-formed.js';
+
+describe('DeltaCoalescer', () => {
+  it('holds deltas within the interval', () => {
+    const coalescer = new DeltaCoalescer(50, 0);
+
+    expect(coalescer.push('Tur', 10)).toBe('');
+    expect(coalescer.push('ning ', 20)).toBe('');
+    expect(coalescer.holding).toBe(true);
+    expect(coalescer.flush()).toBe('Turning ');
+  });
+
+  it('releases up to the last word boundary once the interval passed', () => {
+    const coalescer = new DeltaCoalescer(50, 0);
+
+    coalescer.push('Turning on', 10);
+    expect(coalescer.push(' the li', 60)).toBe('Turning on the ');
+    expect(coalescer.push('ghts', 70)).toBe('');
+    expect(coalescer.flush()).toBe('lights');
+  });
+
+  it('keeps a word without boundary until one arrives', () => {
+    const coalescer = new DeltaCoalescer(50, 0);
+
+    expect(coalescer.push('Supercalifragilistic', 100)).toBe('');
+    expect(coalescer.push('expialidocious!', 200)).toBe('');
+    expect(coalescer.push(' Yes', 300)).toBe('Supercalifragilisticexpialidocious! ');
+  });
+
+  it('releases a finished sentence right away', () => {
+    const coalescer = new DeltaCoalescer(50, 0);
+
+    coalescer.push('The lights', 1);
+    expect(coalescer.push(' are on. ', 2)).toBe('The lights are on. ');
+    expect(coalescer.push('Anything', 3)).toBe('');
+    expect(coalescer.push(' else?\n', 4)).toBe('Anything else?\n');
+    expect(coalescer.holding).toBe(false);
+  });
+
+  it('takes all held text but half a surrogate pair', () => {
+    const coalescer = new DeltaCoalescer(50, 0);
+
+    coalescer.push('Party \ud83c', 10);
+    expect(coalescer.take(60)).toBe('Party ');
+    expect(coalescer.push('\udf89', 70)).toBe('');
+    expect(coalescer.take(120)).toBe('🎉');
+  });
+
+  it('never splits an emoji at a word boundary', () => {
+    const coalescer = new DeltaCoalescer(50, 0);
+    const chunks = ['Done 🎉', ' and \ud83d', '\ude00', ' bye'];
+    const times = [10, 60, 120, 180];
+
+    const emitted = chunks.map((chunk, i) => coalescer.push(chunk, times[i]));
+    emitted.push(coalescer.flush());
+
+    expect(emitted.join('')).toBe(chunks.join(''));
+    for (const piece of emitted) {
+      expect(piece).toBe(toWellFormed(piece));
+    }
+  });
+});
@@ -0,0 +1,86 @@
+/**
+ * Unit tests for InlineReasoningFilter
+ *
+ * Tests splitting reasoning between markers off from streamed answers,
+ * including markers split across chunks.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { InlineReasoningFilter } from '../../src/lumo-client/inline-reasoning-filter.js';
+
+const MARKERS: Array<[string, string]> = [['<think>', '</think>'], ['<thinking>', '</thinking>']];
+
+/** Feed chunks through a filter and return everything it emitted */
+function stream(filter: InlineReasoningFilter, chunks: string[]): { text: string; reasoning: string } {
+  let text = '';
+  let reasoning = '';
+  for (const part of [...chunks.map(chunk => filter.push(chunk)), filter.flush()]) {
+    text += part.text;
+    reasoning += part.reasoning;
+  }
+  return { text, reasoning };
+}
+
+describe('InlineReasoningFilter', () => {
+  it('passes answers without markers unchanged', () => {
+    const filter = new InlineReasoningFilter(MARKERS);
+
+    expect(stream(filter, ['  The lights ', 'are < 50% on.'])).toEqual({ text: '  The lights are < 50% on.', reasoning: '' });
+  });
+
+  it('splits off reasoning within one chunk', () => {
+    const filter = new InlineReasoningFilter(MARKERS);
+
+    expect(stream(filter, ['<think>User wants light.</think>\n\nTurning on the lights.'])).toEqual({
+      text: 'Turning on the lights.',
+      reasoning: 'User wants light.',
+    });
+  });
+
+  it('catches markers split across chunks', () => {
+    const filter = new InlineReasoningFilter(MARKERS);
+
+    expect(filter.push('<thi')).toEqual({ text: '', reasoning: '' });
+    expect(filter.push('nk>Lights')).toEqual({ text: '', reasoning: 'Lights' });
+    expect(filter.push(' off?</th')).toEqual({ text: '', reasoning: ' off?' });
+    expect(filter.push('ink')).toEqual({ text: '', reasoning: '' });
+    expect(filter.push('>Done.')).toEqual({ text: 'Done.', reasoning: '' });
+  });
+
+  it('catches markers split over single characters', () => {
+    const filter = new InlineReasoningFilter(MARKERS);
+    const response = 'Sure. <thinking>kitchen or living room?</thinking> Which room?';
+
+    expect(stream(filter, response.split(''))).toEqual({
+      text: 'Sure.  Which room?',
+      reasoning: 'kitchen or living room?',
+    });
+  });
+
+  it('never emits reasoning that is not closed', () => {
+    const filter = new InlineReasoningFilter(MARKERS);
+
+    expect(stream(filter, ['Hm. <think>the user', ' seems </thi'])).toEqual({
+      text: 'Hm. ',
+      reasoning: 'the user seems </thi',
+    });
+  });
+
+  it('emits a held back partial marker that turned out not to be one', () => {
+    const filter = new InlineReasoningFilter(MARKERS);
+
+    expect(filter.push('Use <th')).toEqual({ text: 'Use ', reasoning: '' });
+    expect(filter.push('ead>')).toEqual({ text: '<thead>', reasoning: '' });
+    expect(filter.push('<thin')).toEqual({ text: '', reasoning: '' });
+    expect(filter.flush()).toEqual({ text: '<thin', reasoning: '' });
+  });
+
+  it('handles several reasoning blocks', () => {
+    const filter = new InlineReasoningFilter(MARKERS);
+
+    expect(stream(filter, ['<think>a</think>One', ' <think>b</th', 'ink>two'])).toEqual({
+      text: 'One two',
+      reasoning: 'ab',
+    });
+  });
+});
@@ -0,0 +1,77 @@
+/**
+ * Unit tests for LumoClient backpressure
+ *
+ * Tests that Lumo's stream is only read while the consumer keeps up, and that
+ * waiting for the consumer doesn't count as a stalled stream.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { LumoClient } from '../../src/lumo-client/index.js';
+import { formatSSEMessage, delay } from '../../src/mock/mock-api.js';
+import type { ProtonApi } from '../../src/lumo-client/types.js';
+
+/** Mock Lumo producing a token per read only, counting the reads */
+function pullApi(tokens: string[]): ProtonApi & { reads: () => number } {
+  let reads = 0;
+  const api: ProtonApi = async () => {
+    const encoder = new TextEncoder();
+    return new ReadableStream<Uint8Array>({
+      pull(controller) {
+        const i = reads++;
+        if (i < tokens.length) {
+          controller.enqueue(encoder.encode(
+            formatSSEMessage({ type: 'token_data', target: 'message', count: i, content: tokens[i] })
+          ));
+        } else {
+          controller.enqueue(encoder.encode(formatSSEMessage({ type: 'done' })));
+          controller.close();
+        }
+      },
+    }, { highWaterMark: 0 });
+  };
+  return Object.assign(api, { reads: () => reads });
+}
+
+describe('LumoClient backpressure', () => {
+  it('pauses reading while the consumer is behind', async () => {
+    const api = pullApi(['a', 'b', 'c']);
+    const client = new LumoClient(api, { enableEncryption: false });
+    let behind = true;
+    let release!: () => void;
+    let waiting = false;
+
+    const chunks: string[] = [];
+    const pending = client.chat('Hello', chunk => chunks.push(chunk), {
+      // Fall behind after the first chunk
+      waitForConsumer: () => chunks.length === 0 || !behind ? Promise.resolve() : new Promise(resolve => {
+        waiting = true;
+        release = resolve;
+      }),
+    });
+
+    await delay(50);
+    expect(waiting).toBe(true);
+    expect(chunks).toEqual(['a']);
+    expect(api.reads()).toBe(1);
+
+    behind = false;
+    release();
+    const result = await pending;
+    expect(result.message.content).toBe('abc');
+    expect(chunks).toEqual(['a', 'b', 'c']);
+  });
+
+  it('does not count waiting for the consumer as a stall', async () => {
+    const api = pullApi(['a', 'b']);
+    const client = new LumoClient(api, { enableEncryption: false });
+
+    const result = await client.chat('Hello', undefined, {
+      idleTimeoutMs: 20,
+      truncationNote: ' (cut off)',
+      waitForConsumer: () => delay(40),
+    });
+
+    expect(result.truncated).toBeUndefined();
+    expect(result.message.content).toBe('ab');
+  });
+});
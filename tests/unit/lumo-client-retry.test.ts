@@ -0,0 +1,189 @@
+/**
+ * Unit tests for LumoClient retries
+ *
+ * Tests retrying 5xx responses and connection resets with backoff,
+ * not retrying once output (answer or reasoning) was streamed, dropping rejected
+ * sampling parameters, and the non-streaming fallback for streams failing before
+ * the first token.
+ */
+
+import { describe, it, expect, vi, beforeAll } from 'vitest';
+import { LumoClient } from '../../src/lumo-client/index.js';
+import { createMockProtonApi, formatSSEMessage } from '../../src/mock/mock-api.js';
+import { getRetryConfig } from '../../src/app/config.js';
+import type { ProtonApi } from '../../src/lumo-client/types.js';
+
+function httpError(status: number): Error {
+  return Object.assign(new Error(`API error: ${status}`), { status });
+}
+
+function connectionReset(): Error {
+  return new TypeError('fetch failed', { cause: Object.assign(new Error('socket hang up'), { code: 'ECONNRESET' }) });
+}
+
+/** Unencrypted Lumo stream of the given messages, failing with error once they're read if set */
+function sseStream(messages: Record<string, unknown>[], error?: Error): ReadableStream<Uint8Array> {
+  const encoder = new TextEncoder();
+  return new ReadableStream<Uint8Array>({
+    start(controller) {
+      for (const msg of messages) controller.enqueue(encoder.encode(formatSSEMessage(msg)));
+      if (!error) controller.close();
+    },
+    pull(controller) {
+      if (error) controller.error(error);
+    },
+  });
+}
+
+/** ProtonApi throwing the given errors on the first calls, then answering like the success mock */
+function failingApi(...errors: Error[]): ProtonApi & { calls: number } {
+  const success = createMockProtonApi('success');
+  const state = { calls: 0 };
+  const api: ProtonApi = async (options) => {
+    const error = errors[state.calls++];
+    if (error) throw error;
+    return success(options);
+  };
+  return Object.defineProperty(api, 'calls', { get: () => state.calls }) as ProtonApi & { calls: number };
+}
+
+describe('LumoClient retries', () => {
+  beforeAll(() => {
+    getRetryConfig().maxRetries = 2;
+    getRetryConfig().baseDelayMs = 0;
+  });
+
+  it('retries 5xx responses', async () => {
+    const api = failingApi(httpError(502), httpError(503));
+    const client = new LumoClient(api, { enableEncryption: false });
+
+    const result = await client.chat('Hello');
+
+    expect(api.calls).toBe(3);
+    expect(result.message.content).not.toBe('');
+  });
+
+  it('retries connection resets', async () => {
+    const api = failingApi(connectionReset());
+    const client = new LumoClient(api, { enableEncryption: false });
+
+    await client.chat('Hello');
+    expect(api.calls).toBe(2);
+  });
+
+  it('gives up after maxRetries', async () => {
+    const api = failingApi(httpError(500), httpError(500), httpError(500));
+    const client = new LumoClient(api, { enableEncryption: false });
+
+    await expect(client.chat('Hello')).rejects.toMatchObject({ status: 500 });
+    expect(api.calls).toBe(3);
+  });
+
+  it('does not retry client errors', async () => {
+    const api = failingApi(httpError(429));
+    const client = new LumoClient(api, { enableEncryption: false });
+
+    await expect(client.chat('Hello')).rejects.toMatchObject({ status: 429 });
+    expect(api.calls).toBe(1);
+  });
+
+  it('does not retry once output was streamed', async () => {
+    const encoder = new TextEncoder();
+    const api = vi.fn<ProtonApi>(async () => new ReadableStream<Uint8Array>({
+      start(controller) {
+        controller.enqueue(encoder.encode(
+          formatSSEMessage({ type: 'token_data', target: 'message', count: 0, content: 'Hello' })
+        ));
+      },
+      // Erroring in start() would drop the queued token, fail once it was read
+      pull(controller) {
+        controller.error(Object.assign(new Error('terminated'), { code: 'ECONNRESET' }));
+      },
+    }));
+    const client = new LumoClient(api, { enableEncryption: false });
+    const chunks: string[] = [];
+
+    await expect(client.chat('Hello', chunk => chunks.push(chunk))).rejects.toThrow('terminated');
+    expect(api).toHaveBeenCalledTimes(1);
+    expect(chunks).toEqual(['Hello']);
+  });
+
+  it('does not retry once reasoning was streamed', async () => {
+    const api = vi.fn<ProtonApi>(async () => sseStream(
+      [{ type: 'token_data', target: 'reasoning', count: 0, content: 'Lights?' }],
+      Object.assign(new Error('terminated'), { code: 'ECONNRESET' })
+    ));
+    const client = new LumoClient(api, { enableEncryption: false });
+    const reasoning: string[] = [];
+
+    await expect(client.chat('Hello', () => {}, { onReasoning: text => reasoning.push(text) })).rejects.toThrow('terminated');
+    expect(api).toHaveBeenCalledTimes(1);
+    expect(reasoning).toEqual(['Lights?']);
+  });
+
+  it('passes reasoning on once in the non-streaming fallback', async () => {
+    const api = vi.fn<ProtonApi>(async () => api.mock.calls.length === 1
+      ? sseStream([], new Error('stream broke'))
+      : sseStream([
+        { type: 'token_data', target: 'reasoning', count: 0, content: 'Lights' },
+        { type: 'token_data', target: 'reasoning', count: 1, content: '?' },
+        { type: 'token_data', target: 'message', count: 0, content: 'On.' },
+        { type: 'done' },
+      ]));
+    const client = new LumoClient(api, { enableEncryption: false });
+    const chunks: string[] = [];
+    const reasoning: string[] = [];
+
+    await client.chat('Hello', chunk => chunks.push(chunk), { onReasoning: text => reasoning.push(text) });
+
+    expect(api).toHaveBeenCalledTimes(2);
+    expect(reasoning).toEqual(['Lights?']);
+    expect(chunks).toEqual(['On.']);
+  });
+
+  it('falls back to non-streaming when the stream fails before the first token', async () => {
+    const success = createMockProtonApi('success');
+    const api = vi.fn<ProtonApi>(async (options) => api.mock.calls.length === 1
+      ? new ReadableStream<Uint8Array>({ start: controller => controller.error(new Error('stream broke')) })
+      : success(options));
+    const client = new LumoClient(api, { enableEncryption: false });
+    const chunks: string[] = [];
+
+    const result = await client.chat('Hello', chunk => chunks.push(chunk));
+
+    expect(api).toHaveBeenCalledTimes(2);
+    expect(chunks).toEqual([result.message.content]);
+  });
+
+  it('does not fall back to non-streaming when disabled', async () => {
+    getRetryConfig().streamFallback = false;
+    try {
+      const api = vi.fn<ProtonApi>(async () => new ReadableStream<Uint8Array>({
+        start: controller => controller.error(new Error('stream broke')),
+      }));
+      const client = new LumoClient(api, { enableEncryption: false });
+
+      await expect(client.chat('Hello')).rejects.toThrow('stream broke');
+      expect(api).toHaveBeenCalledTimes(1);
+    } finally {
+      getRetryConfig().streamFallback = true;
+    }
+  });
+
+  it('retries without sampling parameters Lumo rejects', async () => {
+    const api = failingApi(httpError(400));
+    // Copy the payloads, the client reuses the same request object
+    const prompts: Record<string, unknown>[] = [];
+    const client = new LumoClient(async (options) => {
+      prompts.push(structuredClone((options.data as { Prompt: Record<string, unknown> }).Prompt));
+      return api(options);
+    }, { enableEncryption: false });
+
+    await client.chat('Hello', undefined, { temperature: 0.7, topP: 0.9 });
+
+    expect(prompts).toHaveLength(2);
+    expect(prompts[0]).toMatchObject({ temperature: 0.7, top_p: 0.9 });
+    expect(prompts[1]).not.toHaveProperty('temperature');
+    expect(prompts[1]).not.toHaveProperty('top_p');
+  });
+});
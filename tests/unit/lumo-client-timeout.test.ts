@@ -0,0 +1,68 @@
+/**
+ * Unit tests for LumoClient stream idle timeout
+ *
+ * Tests that a stalled stream is closed with the partial response and a
+ * truncation note, instead of hanging or failing.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { LumoClient } from '../../src/lumo-client/index.js';
+import { formatSSEMessage, delay } from '../../src/mock/mock-api.js';
+import type { ProtonApi } from '../../src/lumo-client/types.js';
+
+const NOTE = ' (cut off)';
+
+/** Mock Lumo sending the given tokens 10ms apart, then stalling (or finishing if `finish`) */
+function tokenApi(tokens: string[], finish = false): ProtonApi & { cancelled: () => boolean } {
+  let cancelled = false;
+  const api: ProtonApi = async () => {
+    const encoder = new TextEncoder();
+    return new ReadableStream<Uint8Array>({
+      async start(controller) {
+        for (let i = 0; i < tokens.length; i++) {
+          controller.enqueue(encoder.encode(
+            formatSSEMessage({ type: 'token_data', target: 'message', count: i, content: tokens[i] })
+          ));
+          await delay(10);
+        }
+        if (finish) {
+          controller.enqueue(encoder.encode(formatSSEMessage({ type: 'done' })));
+          controller.close();
+        }
+      },
+      cancel() {
+        cancelled = true;
+      },
+    });
+  };
+  return Object.assign(api, { cancelled: () => cancelled });
+}
+
+describe('LumoClient idle timeout', () => {
+  it('keeps the partial response of a stalled stream and appends the note', async () => {
+    const api = tokenApi(['Turning ', 'on ']);
+    const client = new LumoClient(api, { enableEncryption: false });
+    const chunks: string[] = [];
+
+    const result = await client.chat('Hello', chunk => chunks.push(chunk), {
+      idleTimeoutMs: 100,
+      truncationNote: NOTE,
+    });
+
+    expect(result.truncated).toBe(true);
+    expect(result.message.content).toBe(`Turning on ${NOTE}`);
+    expect(chunks).toEqual(['Turning ', 'on ', NOTE]);
+    expect(api.cancelled()).toBe(true);
+  });
+
+  it('does not time out streams that keep sending', async () => {
+    const api = tokenApi(['a', 'b', 'c', 'd', 'e', 'f'], true);
+    const client = new LumoClient(api, { enableEncryption: false });
+
+    // The whole stream takes longer than the timeout, the gaps between chunks don't
+    const result = await client.chat('Hello', undefined, { idleTimeoutMs: 40, truncationNote: NOTE });
+
+    expect(result.truncated).toBeUndefined();
+    expect(result.message.content).toBe('abcdef');
+  });
+});
@@ -55,6 +55,23 @@ describe('RequestQueue', () => {
     expect(queue.getSize()).toBe(0);
   });
 
+  it('drops a queued request when its signal aborts', async () => {
+    const queue = new RequestQueue(1);
+    let resolve!: () => void;
+    const blocker = new Promise<void>(r => { resolve = r; });
+    queue.add(() => blocker);
+
+    const controller = new AbortController();
+    let ran = false;
+    const queued = queue.add(async () => { ran = true; }, controller.signal);
+    controller.abort(new Error('Client closed the connection'));
+
+    await expect(queued).rejects.toThrow();
+    resolve();
+    await queue.waitForIdle();
+    expect(ran).toBe(false);
+  });
+
   it('waitForIdle resolves when queue is empty', async () => {
     const queue = new RequestQueue(1);
 
@@ -0,0 +1,75 @@
+/**
+ * Unit tests for log redaction
+ */
+
+import { describe, it, expect } from 'vitest';
+import { redact, redactString, REDACTED } from '../../src/app/redact.js';
+
+const TOKEN = 'abcdefghijklmnopqrstuvwxyz012345';
+
+describe('redactString', () => {
+  it('masks tokens, auth cookies and email addresses', () => {
+    expect(redactString(`Authorization: Bearer ${TOKEN}`)).toBe(`Authorization: Bearer ${REDACTED}`);
+    expect(redactString(`AUTH-${TOKEN}=${TOKEN}; Path=/`)).toBe(`AUTH-${REDACTED}; Path=/`);
+    expect(redactString(`uid ${TOKEN} refreshed`)).toBe(`uid ${REDACTED} refreshed`);
+    expect(redactString('Logged in as jane.doe+lumo@proton.me')).toBe('Logged in as [email]');
+  });
+
+  it('leaves ordinary text alone', () => {
+    expect(redactString('Lumo request failed, retrying')).toBe('Lumo request failed, retrying');
+  });
+});
+
+describe('redact', () => {
+  it('replaces values of secret keys', () => {
+    expect(redact({
+      accessToken: TOKEN,
+      refresh_token: 'short',
+      uid: 'abcd1234...',
+      keyPassword: 'hunter2',
+      setCookieHeaders: ['Session-Id=x'],
+      tokens: { accessToken: TOKEN },
+    })).toEqual({
+      accessToken: REDACTED,
+      refresh_token: REDACTED,
+      uid: REDACTED,
+      keyPassword: REDACTED,
+      setCookieHeaders: REDACTED,
+      tokens: REDACTED,
+    });
+  });
+
+  it('keeps numbers under token-like keys', () => {
+    expect(redact({ promptTokens: 12, completionTokens: 34 })).toEqual({ promptTokens: 12, completionTokens: 34 });
+  });
+
+  it('hides content unless messageContent is enabled', () => {
+    const record = { item: { type: 'function_call_output', call_id: 'call_1', output: '{"state":"home"}' } };
+
+    expect(redact(record)).toEqual({
+      item: { type: 'function_call_output', call_id: 'call_1', output: '[redacted 16 chars]' },
+    });
+    expect(redact(record, { messageContent: true })).toEqual(record);
+  });
+
+  it('redacts nested strings and errors', () => {
+    const error = new Error(`Refresh failed for ${TOKEN}`);
+    const result = redact({ error, attempts: [`user@example.com`] }) as { error: Error; attempts: string[] };
+
+    expect(result.error).toBeInstanceOf(Error);
+    expect(result.error.message).toBe(`Refresh failed for ${REDACTED}`);
+    expect(result.error.stack).not.toContain(TOKEN);
+    expect(result.attempts).toEqual(['[email]']);
+    // The original is left untouched
+    expect(error.message).toContain(TOKEN);
+  });
+
+  it('handles circular references', () => {
+    const record: Record<string, unknown> = { name: 'loop' };
+    record.self = record;
+
+    const result = redact(record) as Record<string, unknown>;
+    expect(result.name).toBe('loop');
+    expect(result.self).toBe(result);
+  });
+});
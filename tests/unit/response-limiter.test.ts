@@ -0,0 +1,54 @@
+/**
+ * Unit tests for ResponseLimiter
+ *
+ * Tests cutting streamed responses off at a sentence boundary once they exceed max tokens.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { ResponseLimiter } from '../../src/api/response-limiter.js';
+
+/** Feed chunks through a limiter and return everything it emitted */
+function stream(limiter: ResponseLimiter, chunks: string[]): string {
+  return chunks.map(chunk => limiter.push(chunk)).join('') + limiter.flush();
+}
+
+describe('ResponseLimiter', () => {
+  it('passes responses within the limit unchanged', () => {
+    const limiter = new ResponseLimiter(100, '…');
+
+    expect(stream(limiter, ['The lights ', 'are on. ', 'Anything else?'])).toBe('The lights are on. Anything else?');
+    expect(limiter.truncated).toBe(false);
+  });
+
+  it('emits whole sentences only', () => {
+    const limiter = new ResponseLimiter(100, '…');
+
+    expect(limiter.push('The lights ')).toBe('');
+    expect(limiter.push('are on. Any')).toBe('The lights are on. ');
+    expect(limiter.flush()).toBe('Any');
+  });
+
+  it('cuts off at the last sentence that fits', () => {
+    // 10 tokens, ~40 characters
+    const limiter = new ResponseLimiter(10, '…');
+
+    const output = stream(limiter, ['It is 21 degrees. ', 'The sky is clear. ', 'Tomorrow brings rain and wind. ', 'More.']);
+
+    expect(output).toBe('It is 21 degrees. The sky is clear. …');
+    expect(limiter.truncated).toBe(true);
+    expect(limiter.push('Ignored. ')).toBe('');
+  });
+
+  it('cuts a first sentence that is too long at a word boundary', () => {
+    const limiter = new ResponseLimiter(4, '…');
+
+    expect(stream(limiter, ['This sentence is ', 'far too long to fit'])).toBe('This sentence…');
+    expect(limiter.truncated).toBe(true);
+  });
+
+  it('does not cut an emoji in half', () => {
+    const limiter = new ResponseLimiter(1, '…');
+
+    expect(stream(limiter, ['abc🎉def'])).toBe('abc…');
+  });
+});
@@ -1,17 +1,36 @@
 /**
  * Unit tests for shared route utilities
  *
- * Tests ID generators, accumulating tool processor, and persistence helpers.
+ * Tests ID generators, accumulating tool processor, persistence helpers, history, model and
+ * sampling, web search, language and max tokens selection, delta coalescing, SSE keep-alive and backpressure.
  */
 
-import { describe, it, expect, vi } from 'vitest';
+import { describe, it, expect, vi, beforeAll, afterEach } from 'vitest';
 import {
   generateResponseId,
   generateItemId,
   generateFunctionCallId,
   generateChatCompletionId,
   persistAssistantTurn,
+  resolveModel,
+  resolveSampling,
+  resolveWebSearch,
+  resolveLanguage,
+  resolveMaxTokens,
+  resolveStop,
+  withCitationFormatting,
+  withStopSequences,
+  withDeltaCoalescing,
+  trimTurnsToTokenBudget,
+  persistAndBuildTurns,
+  startSSEKeepAlive,
+  waitForDrain,
 } from '../../src/api/routes/shared.js';
+import { FallbackStore } from '../../src/conversations/fallback/store.js';
+import { Role } from '../../src/lumo-client/index.js';
+import { getServerConfig } from '../../src/app/config.js';
+import { EventEmitter } from 'events';
+import type { Response } from 'express';
 import { generateCallId, extractToolNameFromCallId } from '../../src/api/tools/call-id.js';
 import { createAccumulatingToolProcessor } from '../../src/api/tools/streaming-processor.js';
 import type { EndpointDependencies } from '../../src/api/types.js';
@@ -90,6 +109,36 @@ describe('createAccumulatingToolProcessor', () => {
     expect(processor.toolCallsEmitted).toEqual([]);
     expect(getAccumulatedText()).toBe('Just plain text');
   });
+
+  it('emits repeated identical tool calls once', () => {
+    const { processor } = createAccumulatingToolProcessor(true);
+    const call = (args: string) => '\n```json\n{"name":"add_item","arguments":' + args + '}\n```\n';
+
+    processor.onChunk(call('{"item":"milk","list":"shopping"}'));
+    processor.onChunk(call('{"list":"shopping","item":"milk"}'));
+    processor.onChunk(call('{"item":"bread","list":"shopping"}'));
+    processor.finalize();
+
+    expect(processor.toolCallsEmitted.map(tc => tc.function.arguments)).toEqual([
+      '{"item":"milk","list":"shopping"}',
+      '{"item":"bread","list":"shopping"}',
+    ]);
+  });
+
+  it('keeps repeated tool calls when dedup is disabled', () => {
+    getServerConfig().customTools.dedupWindowSeconds = 0;
+    try {
+      const { processor } = createAccumulatingToolProcessor(true);
+      const call = '\n```json\n{"name":"add_item","arguments":{"item":"milk"}}\n```\n';
+
+      processor.onChunk(call + call);
+      processor.finalize();
+
+      expect(processor.toolCallsEmitted).toHaveLength(2);
+    } finally {
+      getServerConfig().customTools.dedupWindowSeconds = 30;
+    }
+  });
 });
 
 describe('persistAssistantTurn', () => {
@@ -203,3 +252,399 @@ describe('persistAssistantTurn', () => {
     expect(deps.persistedMessages).toEqual([]);
   });
 });
+
+describe('resolveModel', () => {
+  beforeAll(() => {
+    getServerConfig().models = ['lumo-large'];
+  });
+
+  it('uses the default model when none is requested', () => {
+    expect(resolveModel(undefined)).toEqual({ name: 'lumo', upstream: undefined });
+  });
+
+  it('leaves the choice to Lumo when the default model is requested', () => {
+    expect(resolveModel('lumo')).toEqual({ name: 'lumo', upstream: undefined });
+  });
+
+  it('forwards a configured model upstream', () => {
+    expect(resolveModel('lumo-large')).toEqual({ name: 'lumo-large', upstream: 'lumo-large' });
+  });
+
+  it('falls back to the default model for unknown models', () => {
+    expect(resolveModel('gpt-4o')).toEqual({ name: 'lumo', upstream: undefined });
+  });
+});
+
+describe('resolveSampling', () => {
+  afterEach(() => {
+    getServerConfig().sampling = { temperature: null, topP: null };
+  });
+
+  it('leaves the choice to Lumo by default', () => {
+    expect(resolveSampling({})).toEqual({ temperature: undefined, topP: undefined });
+  });
+
+  it('uses request values over configured ones', () => {
+    getServerConfig().sampling = { temperature: 0.5, topP: 0.9 };
+    expect(resolveSampling({})).toEqual({ temperature: 0.5, topP: 0.9 });
+    expect(resolveSampling({ temperature: 1.2, top_p: 0.3 })).toEqual({ temperature: 1.2, topP: 0.3 });
+  });
+
+  it('clamps out-of-range values', () => {
+    expect(resolveSampling({ temperature: 5, top_p: -1 })).toEqual({ temperature: 2, topP: 0 });
+  });
+
+  it('ignores invalid values', () => {
+    expect(resolveSampling({ temperature: '0.7', top_p: NaN })).toEqual({ temperature: undefined, topP: undefined });
+  });
+});
+
+describe('resolveMaxTokens', () => {
+  afterEach(() => {
+    getServerConfig().maxTokens = { default: null, cap: null, note: '…' };
+  });
+
+  it('has no limit by default', () => {
+    expect(resolveMaxTokens(undefined)).toBeUndefined();
+  });
+
+  it('uses the request value over the configured default', () => {
+    getServerConfig().maxTokens.default = 200;
+    expect(resolveMaxTokens(undefined)).toBe(200);
+    expect(resolveMaxTokens(50)).toBe(50);
+  });
+
+  it('bounds request values by the cap', () => {
+    getServerConfig().maxTokens.cap = 100;
+    expect(resolveMaxTokens(500)).toBe(100);
+    expect(resolveMaxTokens(undefined)).toBe(100);
+  });
+
+  it('ignores invalid values', () => {
+    expect(resolveMaxTokens(-5)).toBeUndefined();
+    expect(resolveMaxTokens('100')).toBeUndefined();
+  });
+});
+
+describe('resolveStop', () => {
+  afterEach(() => {
+    getServerConfig().stop = [];
+  });
+
+  it('uses the request value over the configured default', () => {
+    expect(resolveStop(undefined)).toEqual([]);
+    getServerConfig().stop = ['###'];
+    expect(resolveStop(undefined)).toEqual(['###']);
+    expect(resolveStop('\n\n')).toEqual(['\n\n']);
+    expect(resolveStop(['END', ''])).toEqual(['END']);
+  });
+
+  it('keeps at most 4 and ignores invalid values', () => {
+    expect(resolveStop(['a', 'b', 'c', 'd', 'e'])).toEqual(['a', 'b', 'c', 'd']);
+    getServerConfig().stop = ['###'];
+    expect(resolveStop([1, 2])).toEqual(['###']);
+  });
+});
+
+describe('withStopSequences', () => {
+  it('ends the response before the first stop sequence', () => {
+    const emitted: string[] = [];
+    const stopped = withStopSequences(['END'], text => emitted.push(text));
+
+    stopped.emit('The answer E');
+    stopped.emit('ND and more');
+    stopped.flush();
+
+    expect(emitted.join('')).toBe('The answer ');
+  });
+
+  it('passes text through without stop sequences', () => {
+    const emitted: string[] = [];
+    const stopped = withStopSequences([], text => emitted.push(text));
+
+    stopped.emit('END');
+    expect(emitted).toEqual(['END']);
+  });
+});
+
+describe('withDeltaCoalescing', () => {
+  it('passes deltas through when disabled', () => {
+    const emitted: string[] = [];
+    const coalesced = withDeltaCoalescing(0, new AbortController().signal, text => emitted.push(text));
+
+    coalesced.emit('a');
+    coalesced.emit('b');
+    expect(emitted).toEqual(['a', 'b']);
+  });
+
+  it('flushes held text after the interval', () => {
+    vi.useFakeTimers();
+    try {
+      const emitted: string[] = [];
+      const coalesced = withDeltaCoalescing(50, new AbortController().signal, text => emitted.push(text));
+
+      coalesced.emit('Hel');
+      coalesced.emit('lo');
+      expect(emitted).toEqual([]);
+
+      vi.advanceTimersByTime(50);
+      expect(emitted).toEqual(['Hello']);
+    } finally {
+      vi.useRealTimers();
+    }
+  });
+
+  it('flushes everything at end of stream', () => {
+    vi.useFakeTimers();
+    try {
+      const emitted: string[] = [];
+      const coalesced = withDeltaCoalescing(50, new AbortController().signal, text => emitted.push(text));
+
+      coalesced.emit('Bye');
+      coalesced.flush();
+      expect(emitted).toEqual(['Bye']);
+
+      vi.advanceTimersByTime(100);
+      expect(emitted).toEqual(['Bye']);
+    } finally {
+      vi.useRealTimers();
+    }
+  });
+
+  it('drops the timer when the client disconnects', () => {
+    vi.useFakeTimers();
+    try {
+      const emitted: string[] = [];
+      const controller = new AbortController();
+      const coalesced = withDeltaCoalescing(50, controller.signal, text => emitted.push(text));
+
+      coalesced.emit('Hel');
+      controller.abort();
+      vi.advanceTimersByTime(100);
+      expect(emitted).toEqual([]);
+    } finally {
+      vi.useRealTimers();
+    }
+  });
+});
+
+describe('resolveWebSearch', () => {
+  afterEach(() => {
+    getServerConfig().enableWebSearch = false;
+  });
+
+  it('uses the configured default', () => {
+    expect(resolveWebSearch({}, undefined)).toBe(false);
+    getServerConfig().enableWebSearch = true;
+    expect(resolveWebSearch({}, undefined)).toBe(true);
+  });
+
+  it('is enabled by a built-in web search tool or web_search_options', () => {
+    expect(resolveWebSearch({ tools: [{ type: 'web_search_preview' }] }, undefined)).toBe(true);
+    expect(resolveWebSearch({ web_search_options: {} }, undefined)).toBe(true);
+  });
+
+  it('follows metadata.web_search over everything else', () => {
+    getServerConfig().enableWebSearch = true;
+    expect(resolveWebSearch({ metadata: { web_search: 'false' }, tools: [{ type: 'web_search' }] }, undefined)).toBe(false);
+    getServerConfig().enableWebSearch = false;
+    expect(resolveWebSearch({ metadata: { web_search: 'TRUE' } }, undefined)).toBe(true);
+  });
+});
+
+describe('resolveLanguage', () => {
+  afterEach(() => {
+    getServerConfig().language = '';
+  });
+
+  it('lets Lumo pick without a language anywhere', () => {
+    expect(resolveLanguage({}, undefined, undefined)).toBeUndefined();
+  });
+
+  it('uses the configured default', () => {
+    getServerConfig().language = 'nl';
+    expect(resolveLanguage({}, undefined, undefined)).toBe('nl');
+  });
+
+  it('prefers metadata.language, then the first Accept-Language entry', () => {
+    getServerConfig().language = 'nl';
+    expect(resolveLanguage({}, 'de-de,de;q=0.9,en;q=0.8', undefined)).toBe('de-DE');
+    expect(resolveLanguage({ metadata: { language: 'fr' } }, 'de-DE', undefined)).toBe('fr');
+  });
+
+  it('skips invalid tags and wildcards', () => {
+    getServerConfig().language = 'nl';
+    expect(resolveLanguage({ metadata: { language: 'not a language' } }, '*', undefined)).toBe('nl');
+  });
+});
+
+describe('withCitationFormatting', () => {
+  it('formats citations only with web search', () => {
+    const emitted: string[] = [];
+    const plain = withCitationFormatting(false, t => emitted.push(t));
+    plain.emit('See [1].');
+    expect(emitted).toEqual(['See [1].']);
+
+    emitted.length = 0;
+    const formatted = withCitationFormatting(true, t => emitted.push(t));
+    formatted.emit('Sunny [');
+    formatted.emit('1].');
+    formatted.flush();
+    expect(emitted.join('')).toBe('Sunny.');
+  });
+});
+
+describe('trimTurnsToTokenBudget', () => {
+  // 40 characters = 10 estimated tokens each
+  const turn = (role: Role, c: string) => ({ role, content: c.repeat(40) });
+  const turns = [turn(Role.User, 'a'), turn(Role.Assistant, 'b'), turn(Role.User, 'c'), turn(Role.Assistant, 'd'), turn(Role.User, 'e')];
+
+  it('keeps everything within budget or without limit', () => {
+    expect(trimTurnsToTokenBudget(turns, 50)).toEqual(turns);
+    expect(trimTurnsToTokenBudget(turns, 0)).toEqual(turns);
+  });
+
+  it('drops the oldest turns and starts with a user turn', () => {
+    // 40 tokens fit b..e, but b answers a dropped question
+    expect(trimTurnsToTokenBudget(turns, 40)).toEqual(turns.slice(2));
+  });
+
+  it('always keeps the last turn', () => {
+    expect(trimTurnsToTokenBudget(turns, 1)).toEqual(turns.slice(4));
+  });
+});
+
+describe('persistAndBuildTurns', () => {
+  function createDeps(): EndpointDependencies {
+    return { queue: {} as any, lumoClient: {} as any, conversationStore: new FallbackStore() };
+  }
+
+  it('returns the full chat log when the client sends it', () => {
+    const deps = createDeps();
+    persistAndBuildTurns(deps, 'conv-1', [{ role: Role.User, content: 'Hi' }]);
+    deps.conversationStore!.appendAssistantResponse('conv-1', { content: 'Hello!' });
+
+    const turns = [
+      { role: Role.User, content: 'Hi' },
+      { role: Role.Assistant, content: 'Hello!' },
+      { role: Role.User, content: 'How are you?' },
+    ];
+    expect(persistAndBuildTurns(deps, 'conv-1', turns)).toEqual(turns);
+  });
+
+  it('re-sends stored history when the client only sends new messages', () => {
+    const deps = createDeps();
+    persistAndBuildTurns(deps, 'conv-1', [{ role: Role.User, content: 'My name is Alex' }]);
+    deps.conversationStore!.appendAssistantResponse('conv-1', { content: 'Hi Alex!' });
+
+    const result = persistAndBuildTurns(deps, 'conv-1', [{ role: Role.User, content: 'What is my name?' }]);
+    expect(result.map(t => t.content)).toEqual(['My name is Alex', 'Hi Alex!', 'What is my name?']);
+  });
+
+  it('passes stateless requests through', () => {
+    const deps = createDeps();
+    const turns = [{ role: Role.User, content: 'Hi' }];
+    expect(persistAndBuildTurns(deps, undefined, turns)).toEqual(turns);
+  });
+});
+
+describe('startSSEKeepAlive', () => {
+  function createMockResponse() {
+    const res = Object.assign(new EventEmitter(), {
+      writableEnded: false,
+      write: vi.fn(),
+    });
+    return res;
+  }
+
+  it('writes ping comments until stopped', () => {
+    vi.useFakeTimers();
+    try {
+      const res = createMockResponse();
+      const stop = startSSEKeepAlive(res as unknown as Response, 1000);
+
+      vi.advanceTimersByTime(2500);
+      expect(res.write).toHaveBeenCalledTimes(2);
+      expect(res.write).toHaveBeenCalledWith(': ping\n\n');
+
+      stop();
+      vi.advanceTimersByTime(5000);
+      expect(res.write).toHaveBeenCalledTimes(2);
+    } finally {
+      vi.useRealTimers();
+    }
+  });
+
+  it('stops when the response closes', () => {
+    vi.useFakeTimers();
+    try {
+      const res = createMockResponse();
+      startSSEKeepAlive(res as unknown as Response, 1000);
+
+      res.emit('close');
+      vi.advanceTimersByTime(5000);
+      expect(res.write).not.toHaveBeenCalled();
+    } finally {
+      vi.useRealTimers();
+    }
+  });
+
+  it('is disabled with a zero interval', () => {
+    vi.useFakeTimers();
+    try {
+      const res = createMockResponse();
+      startSSEKeepAlive(res as unknown as Response, 0);
+
+      vi.advanceTimersByTime(5000);
+      expect(res.write).not.toHaveBeenCalled();
+    } finally {
+      vi.useRealTimers();
+    }
+  });
+});
+
+describe('waitForDrain', () => {
+  function createMockResponse(buffered: number) {
+    return Object.assign(new EventEmitter(), {
+      writableNeedDrain: buffered > 0,
+      writableLength: buffered,
+      writableEnded: false,
+    });
+  }
+
+  /** Whether the promise is still pending after pending callbacks ran */
+  async function isPending(promise: Promise<void>): Promise<boolean> {
+    let settled = false;
+    promise.then(() => { settled = true; });
+    await new Promise(resolve => setImmediate(resolve));
+    return !settled;
+  }
+
+  it('resolves right away below the limit', async () => {
+    const res = createMockResponse(1000);
+    const wait = waitForDrain(res as unknown as Response, new AbortController().signal, 4096);
+    expect(await isPending(wait())).toBe(false);
+  });
+
+  it('waits for drain once the limit is reached', async () => {
+    const res = createMockResponse(8192);
+    const wait = waitForDrain(res as unknown as Response, new AbortController().signal, 4096);
+
+    const waiting = wait();
+    expect(await isPending(waiting)).toBe(true);
+    res.emit('drain');
+    expect(await isPending(waiting)).toBe(false);
+    expect(res.listenerCount('drain')).toBe(0);
+    expect(res.listenerCount('close')).toBe(0);
+  });
+
+  it('stops waiting when the client disconnects', async () => {
+    const res = createMockResponse(8192);
+    const controller = new AbortController();
+    const waiting = waitForDrain(res as unknown as Response, controller.signal, 4096)();
+
+    controller.abort();
+    expect(await isPending(waiting)).toBe(false);
+    expect(res.listenerCount('drain')).toBe(0);
+  });
+});
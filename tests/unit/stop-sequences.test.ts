@@ -0,0 +1,49 @@
+/**
+ * Unit tests for StopSequenceMatcher
+ *
+ * Tests trimming streamed responses at the first stop sequence,
+ * including stop sequences split across chunks.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { StopSequenceMatcher } from '../../src/api/stop-sequences.js';
+
+/** Feed chunks through a matcher and return everything it emitted */
+function stream(matcher: StopSequenceMatcher, chunks: string[]): string {
+  return chunks.map(chunk => matcher.push(chunk)).join('') + matcher.flush();
+}
+
+describe('StopSequenceMatcher', () => {
+  it('passes responses without stop sequences unchanged', () => {
+    const matcher = new StopSequenceMatcher(['END']);
+
+    expect(stream(matcher, ['The lights ', 'are on.'])).toBe('The lights are on.');
+    expect(matcher.stopped).toBe(false);
+  });
+
+  it('trims at the first stop sequence', () => {
+    const matcher = new StopSequenceMatcher(['###', '\n\n']);
+
+    expect(stream(matcher, ['Answer: 42\n\nExplanation ### more'])).toBe('Answer: 42');
+    expect(matcher.stopped).toBe(true);
+    expect(matcher.push('Ignored.')).toBe('');
+  });
+
+  it('catches stop sequences split across chunks', () => {
+    const matcher = new StopSequenceMatcher(['<END>']);
+
+    expect(matcher.push('Done <E')).toBe('Done ');
+    expect(matcher.push('N')).toBe('');
+    expect(matcher.push('D> rest')).toBe('');
+    expect(matcher.stopped).toBe(true);
+  });
+
+  it('releases held back text that turns out not to be a stop sequence', () => {
+    const matcher = new StopSequenceMatcher(['<END>']);
+
+    expect(matcher.push('a <E')).toBe('a ');
+    expect(matcher.push('dge>')).toBe('<Edge>');
+    expect(matcher.push(' <EN')).toBe(' ');
+    expect(matcher.flush()).toBe('<EN');
+  });
+});
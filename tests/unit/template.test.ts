@@ -1,13 +1,14 @@
 /**
  * Unit tests for instructions module
  *
- * Tests template interpolation and replace patterns.
+ * Tests template interpolation, replace patterns, the system prompt and the reply language.
  */
 
-import { describe, it, expect } from 'vitest';
+import { describe, it, expect, beforeEach } from 'vitest';
 import { sanitizeInstructions } from '../../src/lumo-client/instructions.js';
 import { interpolateTemplate } from '../../src/app/template.js';
-import { applyReplacePatterns } from '../../src/api/instructions.js'
+import { applyReplacePatterns, buildInstructions } from '../../src/api/instructions.js'
+import { getServerInstructionsConfig } from '../../src/app/config.js';
 
 describe('interpolateTemplate', () => {
   describe('variable substitution', () => {
@@ -336,3 +337,42 @@ describe('sanitizeInstructions', () => {
     expect(sanitizeInstructions(input)).toBe('array: [1, 2, 3]');
   });
 });
+
+describe('buildInstructions system prompt', () => {
+  it('adds nothing when no system prompt is configured', () => {
+    getServerInstructionsConfig().systemPrompt = '';
+    expect(buildInstructions(undefined, 'Be nice.')).toBe('Be nice.');
+  });
+
+  it('prepends the system prompt to the instructions', () => {
+    getServerInstructionsConfig().systemPrompt = 'You are a cat.';
+    expect(buildInstructions(undefined, 'Be nice.')).toBe('You are a cat.\n\nBe nice.');
+  });
+
+  it('substitutes user, date and time', () => {
+    getServerInstructionsConfig().systemPrompt = 'User: {{user}}. Date: {{date}}. Time: {{time}}.';
+    const result = buildInstructions(undefined, 'Be nice.', { user: 'alice' });
+    expect(result).toMatch(/^User: alice\. Date: \d{4}-\d{2}-\d{2}\. Time: \d{2}:\d{2}\.\n\nBe nice\.$/);
+  });
+});
+
+describe('buildInstructions language', () => {
+  beforeEach(() => {
+    getServerInstructionsConfig().systemPrompt = '';
+    getServerInstructionsConfig().forLanguage = 'Always reply in {{language}}.';
+  });
+
+  it('adds nothing without a language', () => {
+    expect(buildInstructions(undefined, 'Be nice.')).toBe('Be nice.');
+  });
+
+  it('appends the reply language by name', () => {
+    expect(buildInstructions(undefined, 'Be nice.', { language: 'nl' })).toBe('Be nice.\n\nAlways reply in Dutch.');
+  });
+
+  it('substitutes language in the system prompt', () => {
+    getServerInstructionsConfig().systemPrompt = 'You speak {{language}}.';
+    getServerInstructionsConfig().forLanguage = '';
+    expect(buildInstructions(undefined, 'Be nice.', { language: 'de-DE' })).toBe('You speak German (Germany).\n\nBe nice.');
+  });
+});
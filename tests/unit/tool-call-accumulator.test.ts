@@ -0,0 +1,104 @@
+/**
+ * Unit tests for ToolCallAccumulator
+ *
+ * Tests accumulating tool calls whose arguments are streamed in fragments,
+ * directly and through NativeToolCallProcessor with fragmented SSE chunks.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { ToolCallAccumulator, isToolCallDelta } from '../../src/api/tools/tool-call-accumulator.js';
+import { NativeToolCallProcessor } from '../../src/api/tools/native-tool-call-processor.js';
+
+describe('isToolCallDelta', () => {
+  it('detects deltas with string arguments and an index or id', () => {
+    expect(isToolCallDelta({ index: 0, arguments: '{"q' })).toBe(true);
+    expect(isToolCallDelta({ id: 'call_1', function: { arguments: '' } })).toBe(true);
+  });
+
+  it('rejects complete tool calls', () => {
+    expect(isToolCallDelta({ name: 'web_search', arguments: { query: 'x' } })).toBe(false);
+    expect(isToolCallDelta({ name: 'web_search', arguments: '{}' })).toBe(false);
+  });
+});
+
+describe('ToolCallAccumulator', () => {
+  it('parses arguments only once complete', () => {
+    const accumulator = new ToolCallAccumulator();
+
+    expect(accumulator.feed({ index: 0, id: 'call_1', name: 'web_search', arguments: '' })).toBeNull();
+    expect(accumulator.feed({ index: 0, arguments: '{"query":"wea' })).toBeNull();
+    expect(accumulator.feed({ index: 0, arguments: 'ther in {Paris}' })).toBeNull();
+    expect(accumulator.feed({ index: 0, arguments: '"}' })).toEqual({
+      name: 'web_search',
+      arguments: { query: 'weather in {Paris}' },
+    });
+    expect(accumulator.getIncomplete()).toEqual([]);
+  });
+
+  it('keeps interleaved tool calls apart by index', () => {
+    const accumulator = new ToolCallAccumulator();
+
+    accumulator.feed({ index: 0, function: { name: 'weather', arguments: '{"city":' } });
+    accumulator.feed({ index: 1, function: { name: 'stock', arguments: '{"symbol":"PR' } });
+    expect(accumulator.feed({ index: 1, function: { arguments: 'TN"}' } })).toEqual({
+      name: 'stock',
+      arguments: { symbol: 'PRTN' },
+    });
+    expect(accumulator.feed({ index: 0, function: { arguments: '"Paris"}' } })).toEqual({
+      name: 'weather',
+      arguments: { city: 'Paris' },
+    });
+  });
+
+  it('keys on id when there is no index', () => {
+    const accumulator = new ToolCallAccumulator();
+
+    accumulator.feed({ id: 'call_a', name: 'weather', arguments: '{"city":"Ber' });
+    accumulator.feed({ id: 'call_b', name: 'weather', arguments: '{"city":"Rome"}' });
+    expect(accumulator.feed({ id: 'call_a', arguments: 'lin"}' })).toEqual({
+      name: 'weather',
+      arguments: { city: 'Berlin' },
+    });
+  });
+
+  it('reports incomplete tool calls', () => {
+    const accumulator = new ToolCallAccumulator();
+
+    accumulator.feed({ index: 0, name: 'weather', arguments: '{"city":"Par' });
+    expect(accumulator.getIncomplete()).toEqual([{ name: 'weather', arguments: '{"city":"Par' }]);
+  });
+});
+
+describe('NativeToolCallProcessor with streamed arguments', () => {
+  it('accumulates argument deltas split over SSE chunks', () => {
+    const processor = new NativeToolCallProcessor();
+    const stream = [
+      '{"index":0,"id":"call_1","name":"web_search","arguments":""}',
+      '{"index":0,"arguments":"{\\"query\\":',
+      '\\"lumo\\"}"}',
+    ].join('');
+
+    // Feed in small fragments, cutting through the JSON anywhere
+    for (let i = 0; i < stream.length; i += 7) {
+      processor.feedToolCall(stream.slice(i, i + 7));
+    }
+    processor.finalize();
+
+    expect(processor.getResult().toolCall).toEqual({
+      name: 'web_search',
+      arguments: { query: 'lumo' },
+    });
+  });
+
+  it('parses stringified arguments of complete tool calls', () => {
+    const processor = new NativeToolCallProcessor();
+
+    processor.feedToolCall('{"name":"weather","arguments":"{\\"city\\":\\"Paris\\"}"}');
+    processor.finalize();
+
+    expect(processor.getResult().toolCall).toEqual({
+      name: 'weather',
+      arguments: { city: 'Paris' },
+    });
+  });
+});
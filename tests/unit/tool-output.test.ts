@@ -0,0 +1,106 @@
+/**
+ * Unit tests for tool output normalization
+ *
+ * Uses Home Assistant intent response shapes seen in issue reports:
+ * empty speech, missing data, and empty success/targets lists.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { normalizeToolOutput, summarizeIntentResponse } from '../../src/api/tools/tool-output.js';
+import { convertToolMessage } from '../../src/api/message-converter.js';
+
+// HassTurnOn as returned to the conversation agent
+const turnOnOutput = JSON.stringify({
+  speech: {},
+  response_type: 'action_done',
+  data: {
+    targets: [],
+    success: [{ name: 'Kitchen Light', type: 'entity', id: 'light.kitchen' }],
+    failed: [],
+  },
+});
+
+// HassListAddItem: nothing to report in success/targets
+const listAddItemOutput = JSON.stringify({
+  speech: {},
+  response_type: 'action_done',
+  data: { targets: [], success: [], failed: [] },
+});
+
+describe('normalizeToolOutput', () => {
+  it('adds a summary when speech is empty', () => {
+    const result = JSON.parse(normalizeToolOutput(turnOnOutput) as string);
+    expect(result.summary).toBe('Done: Kitchen Light.');
+    expect(result.data.success).toHaveLength(1);
+  });
+
+  it('confirms actions with empty success and targets', () => {
+    const result = JSON.parse(normalizeToolOutput(listAddItemOutput) as string);
+    expect(result.summary).toBe('Done.');
+  });
+
+  it('handles missing data and missing speech', () => {
+    expect(normalizeToolOutput({ speech: {}, response_type: 'action_done' })).toMatchObject({ summary: 'Done.' });
+    expect(normalizeToolOutput({ response_type: 'action_done', data: null })).toMatchObject({ summary: 'Done.' });
+  });
+
+  it('keeps outputs that have speech', () => {
+    const output = JSON.stringify({
+      speech: { plain: { speech: 'Turned on the light', extra_data: null } },
+      response_type: 'action_done',
+      data: { targets: [], success: [], failed: [] },
+    });
+    expect(normalizeToolOutput(output)).toBe(output);
+  });
+
+  it('keeps non-intent outputs', () => {
+    expect(normalizeToolOutput('Search results here')).toBe('Search results here');
+    expect(normalizeToolOutput('{"temperature": 21}')).toBe('{"temperature": 21}');
+    expect(normalizeToolOutput(undefined)).toBeUndefined();
+    expect(normalizeToolOutput('{"speech": ')).toBe('{"speech": ');
+  });
+});
+
+describe('summarizeIntentResponse', () => {
+  it('reports failed targets', () => {
+    expect(summarizeIntentResponse({
+      response_type: 'action_done',
+      data: { success: [{ name: 'Kitchen' }], failed: [{ name: 'Garage' }] },
+    })).toBe('Done for Kitchen. Failed for Garage.');
+  });
+
+  it('reports errors with their code', () => {
+    expect(summarizeIntentResponse({
+      speech: {},
+      response_type: 'error',
+      data: { code: 'no_valid_targets' },
+    })).toBe('Failed (no_valid_targets).');
+  });
+
+  it('skips malformed targets', () => {
+    expect(summarizeIntentResponse({
+      response_type: 'action_done',
+      data: { success: [null, 'light', { id: 'light.kitchen' }, { name: '  ' }] },
+    })).toBe('Done.');
+  });
+
+  it('leaves query answers alone', () => {
+    expect(summarizeIntentResponse({ speech: {}, response_type: 'query_answer', data: {} })).toBeUndefined();
+  });
+});
+
+describe('convertToolMessage with intent responses', () => {
+  it('normalizes function_call_output with empty speech', () => {
+    const result = convertToolMessage({ type: 'function_call_output', call_id: 'call_1', output: listAddItemOutput });
+    const content = (result as { content: string }).content;
+    const parsed = JSON.parse(content.match(/```json\n(.*)\n```/s)![1]);
+    expect(JSON.parse(parsed.output).summary).toBe('Done.');
+  });
+
+  it('normalizes role: tool messages with empty speech', () => {
+    const result = convertToolMessage({ role: 'tool', tool_call_id: 'call_2', content: turnOnOutput });
+    const content = (result as { content: string }).content;
+    const parsed = JSON.parse(content.match(/```json\n(.*)\n```/s)![1]);
+    expect(JSON.parse(parsed.output).summary).toBe('Done: Kitchen Light.');
+  });
+});
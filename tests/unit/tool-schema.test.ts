@@ -0,0 +1,109 @@
+/**
+ * Unit tests for tool schema translation
+ *
+ * Tests normalizing client tool specs into function definitions and
+ * mapping Lumo's tool calls back onto them (names and argument coercion).
+ */
+
+import { describe, it, expect } from 'vitest';
+import { toFunctionDefinitions, coerceValue, ToolSchemas } from '../../src/api/tools/schema.js';
+import type { OpenAITool } from '../../src/api/types.js';
+
+// Home Assistant style tool (flat Responses API format)
+const hassTurnOn = {
+  type: 'function',
+  name: 'HassTurnOn',
+  description: 'Turns on a device',
+  parameters: {
+    type: 'object',
+    properties: {
+      name: { type: 'string' },
+      domain: { type: 'array', items: { type: 'string', enum: ['light', 'switch'] } },
+      brightness: { type: 'integer' },
+      area: { type: 'string' },
+    },
+    required: ['name'],
+  },
+  strict: false,
+} as unknown as OpenAITool;
+
+const getWeather: OpenAITool = {
+  type: 'function',
+  function: {
+    name: 'get_weather',
+    parameters: {
+      type: 'object',
+      properties: { unit: { anyOf: [{ type: 'string', enum: ['C', 'F'] }, { type: 'null' }] } },
+    },
+  },
+};
+
+describe('toFunctionDefinitions', () => {
+  it('normalizes flat and nested tool specs', () => {
+    const [hass, weather] = toFunctionDefinitions([hassTurnOn, getWeather]);
+    expect(hass).toEqual({
+      name: 'HassTurnOn',
+      description: 'Turns on a device',
+      parameters: (hassTurnOn as unknown as { parameters: unknown }).parameters,
+    });
+    expect(weather).toEqual({ name: 'get_weather', parameters: getWeather.function.parameters });
+  });
+
+  it('skips tools without a name', () => {
+    expect(toFunctionDefinitions([{ type: 'function' } as unknown as OpenAITool])).toEqual([]);
+  });
+});
+
+describe('coerceValue', () => {
+  it('coerces stringified numbers and booleans', () => {
+    expect(coerceValue('50', { type: 'integer' })).toBe(50);
+    expect(coerceValue('0.5', { type: 'number' })).toBe(0.5);
+    expect(coerceValue('TRUE', { type: 'boolean' })).toBe(true);
+    expect(coerceValue(12, { type: 'string' })).toBe('12');
+  });
+
+  it('leaves values it cannot coerce unchanged', () => {
+    expect(coerceValue('bright', { type: 'integer' })).toBe('bright');
+    expect(coerceValue('2.5', { type: 'integer' })).toBe('2.5');
+  });
+
+  it('matches enums case-insensitively', () => {
+    expect(coerceValue('Light', { type: 'string', enum: ['light', 'switch'] })).toBe('light');
+    expect(coerceValue('fan', { type: 'string', enum: ['light', 'switch'] })).toBe('fan');
+  });
+
+  it('wraps single values for arrays', () => {
+    expect(coerceValue('light', { type: 'array', items: { type: 'string' } })).toEqual(['light']);
+  });
+
+  it('picks the matching anyOf variant', () => {
+    const schema = { anyOf: [{ type: 'string', enum: ['C', 'F'] }, { type: 'null' }] };
+    expect(coerceValue('f', schema)).toBe('F');
+    expect(coerceValue(null, schema)).toBeNull();
+  });
+});
+
+describe('ToolSchemas', () => {
+  const schemas = new ToolSchemas([hassTurnOn, getWeather]);
+
+  it('maps names and arguments onto the declared tool', () => {
+    const result = schemas.map({
+      name: 'hassturnon',
+      arguments: { name: 'Kitchen', domain: 'Light', brightness: '50', area: null },
+    });
+    expect(result).toEqual({
+      name: 'HassTurnOn',
+      arguments: { name: 'Kitchen', domain: ['light'], brightness: 50 },
+    });
+  });
+
+  it('drops empty optional arguments but keeps required ones', () => {
+    const result = schemas.map({ name: 'HassTurnOn', arguments: { name: '', area: '' } });
+    expect(result.arguments).toEqual({ name: '' });
+  });
+
+  it('passes calls to undeclared tools through', () => {
+    const call = { name: 'other_tool', arguments: { x: '1' } };
+    expect(schemas.map(call)).toBe(call);
+  });
+});
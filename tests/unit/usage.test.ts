@@ -0,0 +1,52 @@
+/**
+ * Unit tests for token usage tracking
+ *
+ * Tests daily and per-conversation totals of UsageTracker.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { UsageTracker } from '../../src/app/usage.js';
+
+const reported = { promptTokens: 100, completionTokens: 20, estimated: false };
+const estimated = { promptTokens: 50, completionTokens: 10, estimated: true };
+
+describe('UsageTracker', () => {
+  it('sums daily totals', () => {
+    const tracker = new UsageTracker();
+    const now = new Date(2026, 2, 14, 10, 0);
+    tracker.record(reported, 'conv-1', now);
+    tracker.record(reported, undefined, now);
+
+    expect(tracker.getSummary(now).today).toEqual({
+      date: '2026-03-14',
+      requests: 2,
+      promptTokens: 200,
+      completionTokens: 40,
+      totalTokens: 240,
+      estimated: false,
+    });
+  });
+
+  it('keeps days apart and reports an empty today', () => {
+    const tracker = new UsageTracker();
+    tracker.record(reported, 'conv-1', new Date(2026, 2, 14, 23, 59));
+    tracker.record(reported, 'conv-1', new Date(2026, 2, 15, 0, 1));
+
+    const summary = tracker.getSummary(new Date(2026, 2, 16));
+    expect(summary.days.map(d => d.date)).toEqual(['2026-03-14', '2026-03-15']);
+    expect(summary.today).toMatchObject({ date: '2026-03-16', requests: 0, totalTokens: 0 });
+  });
+
+  it('tracks conversations and marks estimated totals', () => {
+    const tracker = new UsageTracker();
+    tracker.record(reported, 'conv-1');
+    tracker.record(estimated, 'conv-1');
+    tracker.record(reported, 'conv-2');
+
+    const { conversations } = tracker.getSummary();
+    expect(conversations).toEqual([
+      { conversationId: 'conv-1', requests: 2, promptTokens: 150, completionTokens: 30, totalTokens: 180, estimated: true },
+      { conversationId: 'conv-2', requests: 1, promptTokens: 100, completionTokens: 20, totalTokens: 120, estimated: false },
+    ]);
+  });
+});
@@ -0,0 +1,45 @@
+/**
+ * Unit tests for Utf8StreamDecoder
+ *
+ * Tests that multibyte characters split across chunks are decoded whole.
+ */
+
+import { describe, it, expect } from 'vitest';
+import { Utf8StreamDecoder } from '../../src/lumo-client/utf8-stream-decoder.js';
+
+const encoder = new TextEncoder();
+
+describe('Utf8StreamDecoder', () => {
+  it('decodes complete chunks as is', () => {
+    const decoder = new Utf8StreamDecoder();
+    expect(decoder.decode('message', encoder.encode('héllo 🎉'))).toBe('héllo 🎉');
+    expect(decoder.flush()).toEqual([]);
+  });
+
+  it('holds back a character split across chunks until it is complete', () => {
+    const decoder = new Utf8StreamDecoder();
+    const bytes = encoder.encode('ok 🎉');
+    const emitted = Array.from(bytes, byte => decoder.decode('message', Uint8Array.of(byte)));
+
+    expect(emitted.join('')).toBe('ok 🎉');
+    expect(emitted).not.toContain('�');
+    // The 4 emoji bytes only produce output with the last one
+    expect(emitted.slice(-4)).toEqual(['', '', '', '🎉']);
+  });
+
+  it('keeps streams separate', () => {
+    const decoder = new Utf8StreamDecoder();
+    const euro = encoder.encode('€');
+    expect(decoder.decode('title', euro.slice(0, 1))).toBe('');
+    expect(decoder.decode('message', encoder.encode('hi'))).toBe('hi');
+    expect(decoder.decode('title', euro.slice(1))).toBe('€');
+  });
+
+  it('flushes an incomplete trailing sequence as U+FFFD', () => {
+    const decoder = new Utf8StreamDecoder();
+    const bytes = encoder.encode('x🎉');
+    expect(decoder.decode('tool_call', bytes.slice(0, 3))).toBe('x');
+    expect(decoder.flush()).toEqual([['tool_call', '�']]);
+    expect(decoder.flush()).toEqual([]);
+  });
+});
@@ -0,0 +1,86 @@
+/**
+ * Unit tests for well-formed API output
+ *
+ * Payloads with lone surrogates, as Home Assistant received them before
+ * failing with "Unable to serialize to JSON".
+ */
+
+import { describe, it, expect } from 'vitest';
+import { toWellFormed, stringifyWellFormed } from '../../src/api/well-formed.js';
+
+/** Matches a \u escape of a surrogate that isn't part of a valid escaped pair. */
+const LONE_SURROGATE_ESCAPE = /\\ud[89ab][0-9a-f]{2}(?!\\ud[c-f][0-9a-f]{2})|(?<!\\ud[89ab][0-9a-f]{2})\\ud[c-f][0-9a-f]{2}/i;
+
+describe('toWellFormed', () => {
+  it('leaves well-formed text unchanged', () => {
+    expect(toWellFormed('plain text')).toBe('plain text');
+    expect(toWellFormed('party 🎉 time')).toBe('party 🎉 time');
+  });
+
+  it('replaces a lone high surrogate', () => {
+    expect(toWellFormed('\ud83c')).toBe('�');
+    expect(toWellFormed('a\ud83cb')).toBe('a�b');
+  });
+
+  it('replaces a lone low surrogate', () => {
+    expect(toWellFormed('\udf89')).toBe('�');
+  });
+
+  it('replaces reversed surrogates individually', () => {
+    expect(toWellFormed('\udf89\ud83c')).toBe('��');
+  });
+});
+
+describe('stringifyWellFormed', () => {
+  it('sanitizes content deltas split mid-emoji', () => {
+    for (const content of ['\ud83c', '\udf89']) {
+      const chunk = {
+        object: 'chat.completion.chunk',
+        choices: [{ index: 0, delta: { content }, finish_reason: null }],
+      };
+      const json = stringifyWellFormed(chunk);
+      expect(json).not.toMatch(LONE_SURROGATE_ESCAPE);
+      expect(JSON.parse(json).choices[0].delta.content).toBe('�');
+    }
+  });
+
+  it('sanitizes function_call_output and tool call arguments', () => {
+    const item = {
+      type: 'function_call_output',
+      call_id: 'call_1',
+      output: '{"response":"Lights on \ud83d"}',
+    };
+    const toolCall = {
+      type: 'function',
+      function: { name: 'HassTurnOn', arguments: '{"name":"\udca1 lamp"}' },
+    };
+    for (const payload of [item, toolCall]) {
+      const json = stringifyWellFormed(payload);
+      expect(json).not.toMatch(LONE_SURROGATE_ESCAPE);
+    }
+    expect(JSON.parse(stringifyWellFormed(item)).output).toBe('{"response":"Lights on �"}');
+  });
+
+  it('sanitizes tool call arguments before they are encoded as a JSON string', () => {
+    // Arguments are JSON inside JSON: the inner escape must not survive either
+    const args = stringifyWellFormed({ name: '\udca1 lamp' });
+    expect(args).not.toMatch(LONE_SURROGATE_ESCAPE);
+    expect(JSON.parse(args)).toEqual({ name: '� lamp' });
+  });
+
+  it('sanitizes object keys', () => {
+    const json = stringifyWellFormed({ arguments: { '\ud83c room': 'kitchen', ok: ['\udf89'] } });
+    expect(json).not.toMatch(LONE_SURROGATE_ESCAPE);
+    expect(JSON.parse(json)).toEqual({ arguments: { '� room': 'kitchen', ok: ['�'] } });
+  });
+
+  it('keeps valid emoji intact', () => {
+    const json = stringifyWellFormed({ delta: 'done 🎉' });
+    expect(JSON.parse(json)).toEqual({ delta: 'done 🎉' });
+  });
+
+  it('matches JSON.stringify for well-formed values', () => {
+    const value = { a: [1, 'two', { three: true, four: null }], b: 'x"y\n' };
+    expect(stringifyWellFormed(value)).toBe(JSON.stringify(value));
+  });
+});